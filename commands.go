@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/usein-abilev/chlang/frontend"
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	"github.com/usein-abilev/chlang/targets/vm"
+)
+
+func runCommand(args []string) int {
+	flags := newFlagSet("run")
+	debug := flags.Bool("debug", false, "print VM call frames and the final stack")
+	compile := addCompileFlags(flags)
+	file, code := parseFlags(flags, args)
+	if file == "" {
+		return code
+	}
+
+	module, code := loadModule(file, compile)
+	if module == nil {
+		return code
+	}
+	return execute(module, &vm.VMOptions{Debug: *debug})
+}
+
+func checkCommand(args []string) int {
+	flags := newFlagSet("check")
+	compile := addCompileFlags(flags)
+	file, code := parseFlags(flags, args)
+	if file == "" {
+		return code
+	}
+
+	packages, code := compileFile(file, compile)
+	if packages == nil {
+		return code
+	}
+	return exitOK
+}
+
+func buildCommand(args []string) int {
+	flags := newFlagSet("build")
+	output := flags.String("o", "", "output bytecode file")
+	compile := addCompileFlags(flags)
+	file, code := parseFlags(flags, args)
+	if file == "" {
+		return code
+	}
+	if *output == "" {
+		fmt.Fprintln(os.Stderr, "chlang build: missing output file, use -o <out.chbc>")
+		return exitUsage
+	}
+
+	packages, code := compileFile(file, compile)
+	if packages == nil {
+		return code
+	}
+	module, code := generateModule(packages)
+	if module == nil {
+		return code
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chlang: %s\n", err)
+		return exitFailure
+	}
+	if _, err := module.WriteTo(out); err != nil {
+		out.Close()
+		fmt.Fprintf(os.Stderr, "chlang: %s: %s\n", *output, err)
+		return exitFailure
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "chlang: %s\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func disasmCommand(args []string) int {
+	flags := newFlagSet("disasm")
+	compile := addCompileFlags(flags)
+	file, code := parseFlags(flags, args)
+	if file == "" {
+		return code
+	}
+
+	module, code := loadModule(file, compile)
+	if module == nil {
+		return code
+	}
+	module.Print()
+	return exitOK
+}
+
+// compileFlags are the flags shared by all commands that compile a source file
+type compileFlags struct {
+	dumpAST bool
+	verbose bool
+	root    string
+}
+
+func addCompileFlags(flags *flag.FlagSet) *compileFlags {
+	f := &compileFlags{}
+	flags.BoolVar(&f.dumpAST, "ast", false, "print the AST of the compiled program")
+	flags.BoolVar(&f.verbose, "v", false, "print compilation stages, warnings and the symbol table")
+	flags.StringVar(&f.root, "root", "", "project directory the imports are resolved from (default: the directory of the file)")
+	return f
+}
+
+// compileFile runs the frontend stages on the source file and the packages imported by it.
+// On failure it reports errors to stderr and returns nil packages with the exit code of the failed stage.
+func compileFile(path string, f *compileFlags) ([]*frontend.Package, int) {
+	opts := &frontend.Options{Root: f.root, Warnings: os.Stderr}
+	if f.verbose {
+		opts.Logger = log.New(os.Stderr, "", 0)
+	}
+	packages, diagnostics, err := frontend.Build(path, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chlang: %s\n", err)
+		return nil, exitFailure
+	}
+	if len(diagnostics) > 0 {
+		code := exitSemanticError
+		for _, diagnostic := range diagnostics {
+			switch diagnostic.(type) {
+			case *errors.SyntaxError:
+				code = exitSyntaxError
+			case *errors.InternalError:
+				code = exitInternalError
+			}
+			diagnostic.Write(os.Stderr)
+		}
+		return nil, code
+	}
+	if f.dumpAST {
+		for _, pkg := range packages {
+			ast.PrintAST(pkg.Program)
+		}
+	}
+	return packages, exitOK
+}
+
+// generateModule compiles the packages in the dependency order, every package is linked into the packages importing it.
+// The module of the entry package is returned. Panics raised by the generator are reported as internal compiler errors.
+func generateModule(packages []*frontend.Package) (module *vm.FunctionObject, code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			(&errors.InternalError{Message: fmt.Sprint(r)}).Write(os.Stderr)
+			module, code = nil, exitInternalError
+		}
+	}()
+	generators := make(map[*frontend.Package]*vm.RVMGenerator, len(packages))
+	for _, pkg := range packages {
+		generator := vm.NewRVMGenerator(pkg.Program)
+		for _, imported := range pkg.Imports {
+			generator.Link(imported.Name, generators[imported])
+		}
+		module = generator.Generate()
+		generators[pkg] = generator
+	}
+	return module, exitOK
+}
+
+// loadModule reads a compiled bytecode file or compiles the source file to a module
+func loadModule(path string, f *compileFlags) (*vm.FunctionObject, int) {
+	if filepath.Ext(path) != vm.BytecodeExtension {
+		packages, code := compileFile(path, f)
+		if packages == nil {
+			return nil, code
+		}
+		return generateModule(packages)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chlang: %s\n", err)
+		return nil, exitFailure
+	}
+	defer file.Close()
+	module, err := vm.LoadModule(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chlang: %s: %s\n", path, err)
+		return nil, exitFailure
+	}
+	return module, exitOK
+}
+
+// execute runs the module in a new VM. Panics raised by the VM are reported as runtime errors.
+func execute(module *vm.FunctionObject, opts *vm.VMOptions) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mruntime error:\033[0m %v\n", r)
+			code = exitRuntimeError
+		}
+	}()
+	vm.NewVM(module, opts).Run()
+	return exitOK
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 )
 
 func PrintAST(program *Program) {
@@ -11,11 +12,30 @@ func PrintAST(program *Program) {
 func (p *Program) PrintTree(level int) {
 	printIndent(level)
 	fmt.Println("Program")
+	if p.Package != nil {
+		p.Package.PrintTree(level + 1)
+	}
+	for _, imports := range p.Imports {
+		imports.PrintTree(level + 1)
+	}
 	for _, statement := range p.Statements {
 		statement.PrintTree(level + 1)
 	}
 }
 
+func (p *PackageStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Printf("PackageStatement: %s\n", p.Name.Value)
+}
+
+func (p *ImportStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("ImportStatement")
+	for _, path := range p.Paths {
+		path.PrintTree(level + 1)
+	}
+}
+
 func (p *BadStatement) PrintTree(level int) {
 	printIndent(level)
 	fmt.Println("BadStatement: (!)")
@@ -123,6 +143,12 @@ func (ft *FunctionType) PrintTree(level int) {
 		}
 	}
 
+	if ft.Spread != nil {
+		printIndent(level + 1)
+		fmt.Println("Spread:")
+		ft.Spread.PrintTree(level + 2)
+	}
+
 	if ft.ReturnType != nil {
 		printIndent(level + 1)
 		fmt.Println("ReturnType:")
@@ -210,6 +236,104 @@ func (ie *IfExpression) PrintTree(level int) {
 	}
 }
 
+func (te *TupleExpression) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("TupleExpression")
+	for _, element := range te.Elements {
+		element.PrintTree(level + 1)
+	}
+}
+
+func (tt *TupleType) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("TupleType")
+	for _, t := range tt.Types {
+		t.PrintTree(level + 1)
+	}
+}
+
+func (td *TupleDeclarationStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("TupleDeclarationStatement")
+	for _, name := range td.Names {
+		name.PrintTree(level + 1)
+	}
+	printIndent(level + 1)
+	fmt.Println("Value:")
+	td.Value.PrintTree(level + 2)
+}
+
+func (fe *FunctionExpression) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("FunctionExpression")
+	fe.Signature.PrintTree(level + 1)
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	fe.Body.PrintTree(level + 2)
+}
+
+func (te *TryExpression) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("TryExpression")
+	te.Expression.PrintTree(level + 1)
+}
+
+func (st *StringTemplate) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("StringTemplate")
+	for _, part := range st.Parts {
+		part.PrintTree(level + 1)
+	}
+}
+
+func (se *SpreadExpression) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("SpreadExpression")
+	se.Expression.PrintTree(level + 1)
+}
+
+func (gt *GenericType) PrintTree(level int) {
+	printIndent(level)
+	if gt.Package != nil {
+		fmt.Printf("GenericType: %s.%s\n", gt.Package.Value, gt.Name.Value)
+	} else {
+		fmt.Printf("GenericType: %s\n", gt.Name.Value)
+	}
+	for _, arg := range gt.Args {
+		arg.PrintTree(level + 1)
+	}
+}
+
+func (we *WhenExpression) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("WhenExpression")
+
+	printIndent(level + 1)
+	fmt.Println("Subject:")
+	we.Subject.PrintTree(level + 2)
+
+	for _, arm := range we.Arms {
+		arm.PrintTree(level + 1)
+	}
+}
+
+func (arm *WhenArm) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("WhenArm")
+
+	printIndent(level + 1)
+	if arm.Pattern == nil {
+		fmt.Println("Pattern: else")
+	} else {
+		fmt.Println("Pattern:")
+		arm.Pattern.PrintTree(level + 2)
+	}
+
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	arm.Body.PrintTree(level + 2)
+}
+
 func (ie *RangeExpr) PrintTree(level int) {
 	printIndent(level)
 	fmt.Println("RangeExpr")
@@ -284,6 +408,7 @@ func (p *TypeDeclarationStatement) PrintTree(level int) {
 func (p *StructDeclarationStatement) PrintTree(level int) {
 	printIndent(level)
 	fmt.Printf("StructDeclarationStatement: %s\n", p.Name.Value)
+	printTypeParams(p.TypeParams, level+1)
 	if p.Body != nil {
 		printIndent(level + 1)
 		fmt.Println("Spec:")
@@ -291,6 +416,26 @@ func (p *StructDeclarationStatement) PrintTree(level int) {
 	}
 }
 
+func (p *EnumDeclarationStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Printf("EnumDeclarationStatement: %s\n", p.Name.Value)
+	for _, variant := range p.Variants {
+		variant.PrintTree(level + 1)
+	}
+}
+
+func (v *EnumVariant) PrintTree(level int) {
+	printIndent(level)
+	fmt.Printf("EnumVariant: %s\n", v.Name.Value)
+	if len(v.Payload) > 0 {
+		printIndent(level + 1)
+		fmt.Println("Payload:")
+		for _, payload := range v.Payload {
+			payload.PrintTree(level + 2)
+		}
+	}
+}
+
 func (p *ConstDeclarationStatement) PrintTree(level int) {
 	printIndent(level)
 	fmt.Printf("ConstDeclaration: %s\n", p.Name.Value)
@@ -351,6 +496,79 @@ func (f *ForRangeStatement) PrintTree(level int) {
 	}
 }
 
+func (f *ForInStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("ForInStatement")
+
+	if f.Index != nil {
+		printIndent(level + 1)
+		fmt.Printf("Index: %s\n", f.Index.Value)
+	}
+	printIndent(level + 1)
+	fmt.Printf("Value: %s\n", f.Value.Value)
+
+	printIndent(level + 1)
+	fmt.Println("Iterable:")
+	f.Iterable.PrintTree(level + 2)
+
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	f.Body.PrintTree(level + 2)
+}
+
+func (f *ForStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("ForStatement")
+
+	if f.Init != nil {
+		printIndent(level + 1)
+		fmt.Println("Init:")
+		f.Init.PrintTree(level + 2)
+	}
+	if f.Condition != nil {
+		printIndent(level + 1)
+		fmt.Println("Condition:")
+		f.Condition.PrintTree(level + 2)
+	}
+	if f.Post != nil {
+		printIndent(level + 1)
+		fmt.Println("Post:")
+		f.Post.PrintTree(level + 2)
+	}
+
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	f.Body.PrintTree(level + 2)
+}
+
+func (s *IncDecStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Printf("IncDecStatement: %s\n", s.Operator.Literal)
+	s.Target.PrintTree(level + 1)
+}
+
+func (ws *WhileStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("WhileStatement")
+
+	printIndent(level + 1)
+	fmt.Println("Condition:")
+	ws.Condition.PrintTree(level + 2)
+
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	ws.Body.PrintTree(level + 2)
+}
+
+func (ls *LoopStatement) PrintTree(level int) {
+	printIndent(level)
+	fmt.Println("LoopStatement")
+
+	printIndent(level + 1)
+	fmt.Println("Body:")
+	ls.Body.PrintTree(level + 2)
+}
+
 func (bs *BreakStatement) PrintTree(level int) {
 	printIndent(level)
 	fmt.Println("BreakStatement")
@@ -396,7 +614,12 @@ func (fds *FuncDeclarationStatement) PrintTree(level int) {
 
 func (sign *FunctionSignature) PrintTree(level int) {
 	printIndent(level)
-	fmt.Printf("FunctionSignature: %s\n", sign.Name.Value)
+	if sign.Name != nil {
+		fmt.Printf("FunctionSignature: %s\n", sign.Name.Value)
+	} else {
+		fmt.Println("FunctionSignature: <anonymous>")
+	}
+	printTypeParams(sign.TypeParams, level+1)
 
 	if sign.SelfArg != nil {
 		printIndent(level + 1)
@@ -419,9 +642,37 @@ func (sign *FunctionSignature) PrintTree(level int) {
 	}
 }
 
+func printTypeParams(params []*TypeParam, level int) {
+	if len(params) == 0 {
+		return
+	}
+	printIndent(level)
+	fmt.Println("TypeParams:")
+	for _, param := range params {
+		param.PrintTree(level + 1)
+	}
+}
+
+func (tp *TypeParam) PrintTree(level int) {
+	printIndent(level)
+	bounds := make([]string, 0, len(tp.Bounds))
+	for _, bound := range tp.Bounds {
+		bounds = append(bounds, bound.Value)
+	}
+	if len(bounds) > 0 {
+		fmt.Printf("TypeParam: %s: %s\n", tp.Name.Value, strings.Join(bounds, " + "))
+	} else {
+		fmt.Printf("TypeParam: %s\n", tp.Name.Value)
+	}
+}
+
 func (fa *FuncArgument) PrintTree(level int) {
 	printIndent(level)
-	fmt.Printf("FuncArgument: %s, Type:\n", fa.Name.Value)
+	if fa.Spread {
+		fmt.Printf("FuncArgument: ...%s, Type:\n", fa.Name.Value)
+	} else {
+		fmt.Printf("FuncArgument: %s, Type:\n", fa.Name.Value)
+	}
 	if fa.Type != nil {
 		fa.Type.PrintTree(level + 1)
 	}
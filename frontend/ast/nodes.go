@@ -66,15 +66,28 @@ type (
 	}
 
 	// Types nodes
+	// Type with type arguments, e.g. Option<i32>, Result<i32, string>
+	GenericType struct {
+		Span    *token.Span
+		Package *Identifier // nil if the type is not imported: geometry.Pair<i32>
+		Name    *Identifier
+		Args    []Expression
+	}
+	// Several results of the function: fn f() -> i32, f64
+	TupleType struct {
+		Span  *token.Span
+		Types []Expression
+	}
 	ArrayType struct {
 		Span *token.Span
 		Type Expression
 		Size Expression
 	}
 	StructField struct {
-		Span  *token.Span
-		Name  *Identifier
-		Value Expression // value or type
+		Span   *token.Span
+		Name   *Identifier
+		Value  Expression // value or type
+		Public bool       // field of the struct declaration is declared with 'pub'
 	}
 	StructType struct {
 		Span   *token.Span
@@ -83,12 +96,20 @@ type (
 	FunctionType struct {
 		Span       *token.Span
 		Args       []Expression
+		Spread     Expression // element type of the variadic arguments: fn(string, ...i32), nil if none
 		ReturnType Expression
 	}
 	FuncArgument struct {
-		Name *Identifier
-		Type Expression
-		Ref  bool // is reference type (&self or self)
+		Name   *Identifier
+		Type   Expression
+		Ref    bool // is reference type (&self or self)
+		Spread bool // is variadic argument (...args: i32[])
+	}
+	// Type parameter of the generic function or struct with its trait bounds: T, T: Display + Debug
+	TypeParam struct {
+		Span   *token.Span
+		Name   *Identifier
+		Bounds []*Identifier
 	}
 
 	// Statements
@@ -106,6 +127,38 @@ type (
 		Body       *BlockStatement
 		Range      *RangeExpr
 	}
+	// Array iteration: for x in arr { ... } or for (idx, x) in arr { ... }
+	ForInStatement struct {
+		Span     *token.Span
+		Index    *Identifier // nil if the index is not bound
+		Value    *Identifier
+		Iterable Expression
+		Body     *BlockStatement
+	}
+	// C-style loop: for i = 0; i < n; i++ { ... }, every clause is optional
+	ForStatement struct {
+		Span      *token.Span
+		Init      Statement
+		Condition Expression
+		Post      Statement
+		Body      *BlockStatement
+	}
+	// Increment or decrement statement: x++, x--
+	IncDecStatement struct {
+		Span     *token.Span
+		Operator *token.Token
+		Target   Expression
+		Assign   *AssignExpression // the equivalent 'x += 1' assignment, it is checked and compiled instead of the statement
+	}
+	WhileStatement struct {
+		Span      *token.Span
+		Condition Expression
+		Body      *BlockStatement
+	}
+	LoopStatement struct {
+		Span *token.Span
+		Body *BlockStatement
+	}
 	BreakStatement struct {
 		Span *token.Span
 	}
@@ -123,9 +176,11 @@ type (
 		Inclusive bool
 	}
 	InitStructExpression struct {
-		Name   *Identifier
-		Span   *token.Span
-		Fields []*StructField
+		Package *Identifier // nil if the struct is not imported: geometry.Point { x: 1, y: 2 }
+		Name    *Identifier
+		Span    *token.Span
+		Fields  []*StructField
+		Type    NodeLiteralType // type of the initialized struct
 	}
 	ArrayExpression struct {
 		Span     *token.Span
@@ -137,9 +192,11 @@ type (
 		Index Expression
 	}
 	MemberExpression struct {
-		Span   *token.Span
-		Left   Expression
-		Member *Identifier
+		Span     *token.Span
+		Left     Expression
+		Member   *Identifier
+		LeftType NodeLiteralType // type of the left expression
+		Symbol   NodeSymbolRef   // method symbol if the member is a method
 	}
 	UnaryExpression struct {
 		Span     *token.Span
@@ -164,44 +221,116 @@ type (
 		ThenBlock *BlockStatement
 		ElseBlock Statement // block statement or if expression
 	}
+	// Arm of the when expression: 'pattern -> body', the pattern is nil for the 'else' arm.
+	// Patterns are literals '1', ranges '1..=5' (RangeExpr) and enum variants 'Color.Red' or 'Shape.Circle(r)'
+	WhenArm struct {
+		Span    *token.Span
+		Pattern Expression
+		Body    *BlockStatement // an expression body is wrapped into a block with a single expression statement
+	}
+	// Comma separated values: 'return a, b', 'let a, b = 1, 2' or the left side of the parallel assignment 'a, b = b, a'
+	TupleExpression struct {
+		Span     *token.Span
+		Elements []Expression
+	}
+	// Anonymous function: fn(x: i32) -> i32 { x + k }, it captures the variables of the enclosing functions
+	FunctionExpression struct {
+		Span      *token.Span
+		Signature *FunctionSignature // the name is nil
+		Body      *BlockStatement
+		Symbol    NodeSymbolRef
+	}
+	// Postfix '?' operator: unwraps the Ok (Some) value or returns the Err (None) value from the enclosing function
+	TryExpression struct {
+		Span       *token.Span
+		Expression Expression
+	}
+	StringTemplate struct { // "x = $x, sum = ${a + b}"
+		Span  *token.Span
+		Parts []Expression // string literals of the text and the embedded expressions
+	}
+	SpreadExpression struct { // the array passed as the variadic arguments: f(1, ...arr)
+		Span       *token.Span
+		Expression Expression
+	}
+	WhenExpression struct {
+		Span        *token.Span
+		Subject     Expression
+		Arms        []*WhenArm
+		SubjectType NodeLiteralType // type of the matched value
+	}
 	CallExpression struct {
 		Span     *token.Span
-		Function Expression // identifier or member expression
+		Function Expression // identifier, member expression or any expression of the function type
 		Args     []Expression
+		Symbol   NodeSymbolRef     // the called function, set by the checker
+		TypeArgs []NodeLiteralType // inferred type arguments of the generic function, set by the checker
+
+		// target type of the numeric conversion 'f64(x)', set by the checker
+		Conversion NodeLiteralType
 	}
 	ExpressionStatement struct {
 		Span       *token.Span
 		Expression Expression
+		Type       NodeLiteralType // type of the expression
 	}
 )
 
 // Declarations
 type (
-	TypeDeclarationStatement struct {
+	// Package declaration 'package geometry', the files of the package directory declare the same package
+	PackageStatement struct {
 		Span *token.Span
 		Name *Identifier
-		Spec Expression // type specification: StructType, FunctionType, ArrayType, Identifier
+	}
+	// Import of the packages by their paths relative to the project root: import "log", "shapes/geometry"
+	ImportStatement struct {
+		Span  *token.Span
+		Paths []*StringLiteral
+	}
+	TypeDeclarationStatement struct {
+		Span   *token.Span
+		Name   *Identifier
+		Spec   Expression // type specification: StructType, FunctionType, ArrayType, Identifier
+		Public bool
 	}
 	StructDeclarationStatement struct {
-		Span *token.Span
-		Name *Identifier
-		Body *StructType
+		Span       *token.Span
+		Name       *Identifier
+		TypeParams []*TypeParam
+		Body       *StructType
+		Public     bool
+	}
+	// Enum variant: a unit variant 'Red' or a variant with payload 'Circle(f64)'
+	EnumVariant struct {
+		Span    *token.Span
+		Name    *Identifier
+		Payload []Expression // types of the payload values
+	}
+	EnumDeclarationStatement struct {
+		Span     *token.Span
+		Name     *Identifier
+		Variants []*EnumVariant
+		Public   bool
 	}
 	TraitDeclarationStatement struct {
 		Span               *token.Span
 		Name               *Identifier
 		MethodSignatures   []*FunctionSignature
 		MethodDeclarations []*FuncDeclarationStatement
+		Public             bool
 	}
 	ImplStatement struct {
 		Span     *token.Span                 // span of the impl block
 		Receiver *Identifier                 // type that implements the trait
-		Traits   []*Identifier               // traits that are implemented
+		Traits   []Expression                // traits that are implemented: Shape or geometry.Shape
 		Methods  []*FuncDeclarationStatement // methods that are implemented
+		Type     NodeLiteralType             // type of the receiver
 	}
 	FunctionSignature struct {
 		Span       *token.Span
 		Name       *Identifier
+		TypeParams []*TypeParam
 		SelfArg    *FuncArgument
 		Args       []*FuncArgument
 		ReturnType Expression
@@ -211,6 +340,7 @@ type (
 		Signature *FunctionSignature
 		Body      *BlockStatement
 		Symbol    NodeSymbolRef
+		Public    bool
 	}
 	ConstDeclarationStatement struct {
 		Span       *token.Span
@@ -219,6 +349,15 @@ type (
 		Type       Expression
 		Value      Expression
 		Symbol     NodeSymbolRef
+		Public     bool
+	}
+	// Destructuring declaration: let a, b, c = f()
+	TupleDeclarationStatement struct {
+		Span     *token.Span
+		LetToken *token.Token
+		Names    []*Identifier
+		Value    Expression
+		Symbols  []NodeSymbolRef
 	}
 	VarDeclarationStatement struct {
 		Span     *token.Span
@@ -235,6 +374,7 @@ func (ArrayType) Node()    {}
 func (FunctionType) Node() {}
 func (StructField) Node()  {}
 func (StructType) Node()   {}
+func (TypeParam) Node()    {}
 
 func (RangeExpr) Node()                  {}
 func (Identifier) Node()                 {}
@@ -252,14 +392,33 @@ func (AssignExpression) Node()           {}
 func (CallExpression) Node()             {}
 func (BlockStatement) Node()             {}
 func (IfExpression) Node()               {}
+func (WhenArm) Node()                    {}
+func (TryExpression) Node()              {}
+func (SpreadExpression) Node()           {}
+func (StringTemplate) Node()             {}
+func (FunctionExpression) Node()         {}
+func (TupleExpression) Node()            {}
+func (TupleType) Node()                  {}
+func (TupleDeclarationStatement) Node()  {}
+func (GenericType) Node()                {}
+func (WhenExpression) Node()             {}
 func (ExpressionStatement) Node()        {}
 func (ReturnStatement) Node()            {}
 func (ForRangeStatement) Node()          {}
+func (ForStatement) Node()               {}
+func (ForInStatement) Node()             {}
+func (IncDecStatement) Node()            {}
+func (WhileStatement) Node()             {}
+func (LoopStatement) Node()              {}
 func (BreakStatement) Node()             {}
 func (ContinueStatement) Node()          {}
 func (ImplStatement) Node()              {}
+func (PackageStatement) Node()           {}
+func (ImportStatement) Node()            {}
 func (TypeDeclarationStatement) Node()   {}
 func (StructDeclarationStatement) Node() {}
+func (EnumVariant) Node()                {}
+func (EnumDeclarationStatement) Node()   {}
 func (TraitDeclarationStatement) Node()  {}
 func (VarDeclarationStatement) Node()    {}
 func (ConstDeclarationStatement) Node()  {}
@@ -274,6 +433,9 @@ func (e *StructField) GetSpan() *token.Span {
 func (e *StructType) GetSpan() *token.Span {
 	return e.Span
 }
+func (e *TypeParam) GetSpan() *token.Span {
+	return e.Span
+}
 func (e *FunctionType) GetSpan() *token.Span {
 	return e.Span
 }
@@ -322,6 +484,36 @@ func (e *BlockStatement) GetSpan() *token.Span {
 func (e *IfExpression) GetSpan() *token.Span {
 	return e.Span
 }
+func (e *TupleExpression) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *TupleType) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *TupleDeclarationStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *FunctionExpression) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *TryExpression) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *SpreadExpression) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *StringTemplate) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *GenericType) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *WhenArm) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *WhenExpression) GetSpan() *token.Span {
+	return e.Span
+}
 func (e *ExpressionStatement) GetSpan() *token.Span {
 	return e.Span
 }
@@ -331,6 +523,21 @@ func (e *ReturnStatement) GetSpan() *token.Span {
 func (e *ForRangeStatement) GetSpan() *token.Span {
 	return e.Span
 }
+func (e *ForInStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *ForStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *IncDecStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *WhileStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *LoopStatement) GetSpan() *token.Span {
+	return e.Span
+}
 func (e *BreakStatement) GetSpan() *token.Span {
 	return e.Span
 }
@@ -346,6 +553,12 @@ func (e *TypeDeclarationStatement) GetSpan() *token.Span {
 func (e *StructDeclarationStatement) GetSpan() *token.Span {
 	return e.Span
 }
+func (e *EnumVariant) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *EnumDeclarationStatement) GetSpan() *token.Span {
+	return e.Span
+}
 func (e *TraitDeclarationStatement) GetSpan() *token.Span {
 	return e.Span
 }
@@ -358,6 +571,12 @@ func (e *VarDeclarationStatement) GetSpan() *token.Span {
 func (e *ConstDeclarationStatement) GetSpan() *token.Span {
 	return e.Span
 }
+func (e *PackageStatement) GetSpan() *token.Span {
+	return e.Span
+}
+func (e *ImportStatement) GetSpan() *token.Span {
+	return e.Span
+}
 func (e *FuncDeclarationStatement) GetSpan() *token.Span {
 	return e.Span
 }
@@ -384,5 +603,7 @@ func IsLiteralASTNode(node Node) bool {
 
 // Program is the root node of the AST
 type Program struct {
+	Package    *PackageStatement // nil if the file doesn't declare the package
+	Imports    []*ImportStatement
 	Statements []Statement
 }
@@ -3,7 +3,6 @@ package ast
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	compilerError "github.com/usein-abilev/chlang/frontend/errors"
@@ -21,6 +20,10 @@ type Parser struct {
 	// current token
 	current            *chToken.Token
 	functionScopeLevel int
+
+	// struct literals are not allowed in conditions, because in 'if x {' the brace opens the block.
+	// Delimited expressions (parentheses, brackets) allow them again: 'if (P { x: 1 }).x > 0 {'
+	noStructLiteral bool
 }
 
 // Init creates a new AST builder/parser
@@ -33,23 +36,136 @@ func Init(lexer *scanner.Scanner) *Parser {
 	return parser
 }
 
+// bailout is raised to abort parsing after an unrecoverable syntax error
+type bailout struct{}
+
 // TODO: The best approach will be to use a some sort of a state machine to reduce the stack memory consumption
 // But for now, we are using a recursive descent parser
-func (p *Parser) Parse() (*Program, *[]error) {
-	program := &Program{Statements: make([]Statement, 0)}
-	for p.current.Type != chToken.EOF {
-		if p.current.Type == chToken.ILLEGAL {
-			log.Fatalf("Illegal token: %s", p.current)
-			break
+func (p *Parser) Parse() (program *Program, errors *[]error) {
+	program = &Program{Statements: make([]Statement, 0)}
+	errors = &p.errors
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
 		}
+	}()
 
+	p.checkIllegal()
+	p.parseHeader(program)
+	for p.current.Type != chToken.EOF {
+		if p.current.Type == chToken.RIGHT_BRACE {
+			p.reportError(&compilerError.SyntaxError{
+				Position:  p.current.Position,
+				ErrorLine: p.lexer.GetLineByPosition(p.current.Position),
+				Message:   "unexpected '}', there is no block to close",
+			})
+			panic(bailout{})
+		}
 		statement := p.parseStatement()
 		if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
 	}
 
-	return program, &p.errors
+	return program, errors
+}
+
+// parseHeader parses the package declaration and the imports preceding the other statements of the file
+func (p *Parser) parseHeader(program *Program) {
+	p.skipWhile(chToken.NEW_LINE)
+	if p.current.Type == chToken.PACKAGE {
+		program.Package = p.parsePackageStatement()
+	}
+	for {
+		for p.current.Type == chToken.SEMICOLON || p.current.Type == chToken.NEW_LINE {
+			p.next()
+		}
+		if p.current.Type != chToken.IMPORT {
+			return
+		}
+		program.Imports = append(program.Imports, p.parseImportStatement())
+	}
+}
+
+// Parses the package declaration: package geometry
+func (p *Parser) parsePackageStatement() *PackageStatement {
+	packageToken := p.consume(chToken.PACKAGE)
+	name := p.parseIdentifier()
+	p.expectOneOf(chToken.SEMICOLON, chToken.NEW_LINE, chToken.EOF)
+	return &PackageStatement{
+		Span: &chToken.Span{Start: packageToken.Position, End: p.current.Position},
+		Name: name,
+	}
+}
+
+// Parses the import of the packages: import "log", "shapes/geometry"
+func (p *Parser) parseImportStatement() *ImportStatement {
+	importToken := p.consume(chToken.IMPORT)
+	stmt := &ImportStatement{}
+	for {
+		pathToken := p.consume(chToken.STRING_LITERAL)
+		stmt.Paths = append(stmt.Paths, &StringLiteral{
+			Value: pathToken.Literal,
+			Span:  &chToken.Span{Start: pathToken.Position, End: p.current.Position},
+		})
+		if p.current.Type != chToken.COMMA {
+			break
+		}
+		p.consume(chToken.COMMA)
+	}
+	p.expectOneOf(chToken.SEMICOLON, chToken.NEW_LINE, chToken.EOF)
+	stmt.Span = &chToken.Span{Start: importToken.Position, End: p.current.Position}
+	return stmt
+}
+
+// Parses the declaration exported from the package: pub fn, pub const, pub struct, pub enum, pub trait or pub type
+func (p *Parser) parsePublicDeclaration() Statement {
+	pubToken := p.consume(chToken.PUB)
+	if p.functionScopeLevel > 0 {
+		p.reportError(&compilerError.SyntaxError{
+			Position:  pubToken.Position,
+			ErrorLine: p.lexer.GetLineByPosition(pubToken.Position),
+			Message:   "only top-level declarations can be exported",
+			Help:      "remove 'pub' or move the declaration out of the function",
+		})
+	}
+
+	switch p.current.Type {
+	case chToken.FUNCTION:
+		decl := p.parseFunStatement()
+		decl.Public = true
+		return decl
+	case chToken.CONST:
+		decl := p.parseConstStatement()
+		decl.Public = true
+		return decl
+	case chToken.TYPE:
+		decl := p.parseTypeStatement()
+		decl.Public = true
+		return decl
+	case chToken.STRUCT:
+		decl := p.parseStructStatement()
+		decl.Public = true
+		return decl
+	case chToken.ENUM:
+		decl := p.parseEnumStatement()
+		decl.Public = true
+		return decl
+	case chToken.TRAIT:
+		decl := p.parseTraitStatement()
+		decl.Public = true
+		return decl
+	}
+	p.reportError(&compilerError.SyntaxError{
+		Position:  p.current.Position,
+		ErrorLine: p.lexer.GetLineByPosition(p.current.Position),
+		Message:   fmt.Sprintf("expected declaration after 'pub', but got '%s'", p.current.Literal),
+		Help:      "only functions, constants, structs, enums, traits and types can be exported",
+	})
+	p.nextStatement()
+	return &BadStatement{}
 }
 
 // Parses a statement until a semicolon or a right brace or an end of line
@@ -58,6 +174,18 @@ func (p *Parser) parseStatement() Statement {
 	case chToken.SEMICOLON, chToken.NEW_LINE:
 		p.consume(p.current.Type)
 		return nil
+	case chToken.PACKAGE, chToken.IMPORT:
+		keyword := p.consume(p.current.Type)
+		p.reportError(&compilerError.SyntaxError{
+			Position:  keyword.Position,
+			ErrorLine: p.lexer.GetLineByPosition(keyword.Position),
+			Message:   fmt.Sprintf("unexpected '%s', it must precede the other statements of the file", keyword.Literal),
+			Help:      "declare the package first and then import the packages",
+		})
+		p.nextStatement()
+		return &BadStatement{}
+	case chToken.PUB:
+		return p.parsePublicDeclaration()
 	case chToken.VAR:
 		return p.parseVarStatement()
 	case chToken.CONST:
@@ -66,6 +194,8 @@ func (p *Parser) parseStatement() Statement {
 		return p.parseTypeStatement()
 	case chToken.STRUCT:
 		return p.parseStructStatement()
+	case chToken.ENUM:
+		return p.parseEnumStatement()
 	case chToken.TRAIT:
 		return p.parseTraitStatement()
 	case chToken.IMPL:
@@ -76,33 +206,29 @@ func (p *Parser) parseStatement() Statement {
 			return &BadStatement{}
 		}
 		return &ExpressionStatement{Expression: expr, Span: expr.Span}
-	case chToken.FOR:
-		// parsing for-range statement: for i in 1..10 { ... }
-		forToken := p.consume(chToken.FOR)
-
-		p.expect(chToken.IDENTIFIER)
-		identifier := p.parseIdentifier()
-
-		p.consume(chToken.IN)
-
-		rangeNode := &RangeExpr{Inclusive: false, Span: &chToken.Span{Start: p.current.Position}}
-		rangeNode.Start = p.parseExpression()
-		p.expectOneOf(chToken.DOT_DOT, chToken.DOT_DOT_EQUAL)
-		operator := p.consume(p.current.Type)
-		if operator.Type == chToken.DOT_DOT_EQUAL {
-			rangeNode.Inclusive = true
+	case chToken.WHEN:
+		expr := p.parseWhenExpression()
+		return &ExpressionStatement{Expression: expr, Span: expr.Span}
+	case chToken.WHILE:
+		// parsing while statement: while i < 10 { ... }
+		whileToken := p.consume(chToken.WHILE)
+		condition := p.parseExpressionWithStructLiterals(false)
+		block := p.parseBlockStatement()
+		return &WhileStatement{
+			Span:      &chToken.Span{Start: whileToken.Position, End: p.current.Position},
+			Condition: condition,
+			Body:      block,
 		}
-		rangeNode.Span.End = p.current.Position
-		rangeNode.End = p.parseExpression()
-
+	case chToken.LOOP:
+		// parsing infinite loop statement: loop { ... }
+		loopToken := p.consume(chToken.LOOP)
 		block := p.parseBlockStatement()
-
-		return &ForRangeStatement{
-			Span:       &chToken.Span{Start: forToken.Position, End: p.current.Position},
-			Identifier: identifier,
-			Range:      rangeNode,
-			Body:       block,
+		return &LoopStatement{
+			Span: &chToken.Span{Start: loopToken.Position, End: p.current.Position},
+			Body: block,
 		}
+	case chToken.FOR:
+		return p.parseForStatement()
 	case chToken.BREAK:
 		breakToken := p.consume(chToken.BREAK)
 		return &BreakStatement{Span: &chToken.Span{
@@ -116,32 +242,16 @@ func (p *Parser) parseStatement() Statement {
 			End:   p.current.Position,
 		}}
 	case chToken.RETURN:
-		p.consume(chToken.RETURN)
-		if p.functionScopeLevel == 0 {
-			p.reportError(&compilerError.SyntaxError{
-				Position:  p.current.Position,
-				ErrorLine: p.lexer.GetLineByPosition(p.current.Position),
-				Message:   "return statement outside of function",
-				Help:      "return statement can only be used inside a block statement: functions, if statements, loops etc.",
-			})
-			p.nextStatement()
-			return &BadStatement{}
-		}
-		spanStart := p.current.Position
-		if p.current.Type == chToken.SEMICOLON || p.current.Type == chToken.NEW_LINE {
-			return &ReturnStatement{Span: &chToken.Span{Start: spanStart, End: p.current.Position}}
-		}
-		expr := p.parseExpression()
-		if p.current.Type == chToken.SEMICOLON {
-			p.consume(p.current.Type)
-		}
-		return &ReturnStatement{Expression: expr, Span: &chToken.Span{Start: spanStart, End: p.current.Position}}
-	case chToken.FUNCTION:
-		return p.parseFunStatement()
+		return p.parseReturnStatement(true)
 	case chToken.LEFT_BRACE:
 		return p.parseBlockStatement()
+	case chToken.FUNCTION:
+		if p.peek().Type == chToken.IDENTIFIER {
+			return p.parseFunStatement()
+		}
+		fallthrough // anonymous function, e.g. 'fn() { ... }()'
 	default:
-		expr := p.parseExpression()
+		stmt := p.parseSimpleStatement()
 		ok := p.expectOneOf(
 			chToken.SEMICOLON,
 			chToken.NEW_LINE,
@@ -152,14 +262,208 @@ func (p *Parser) parseStatement() Statement {
 			p.nextStatement()
 			return &BadStatement{}
 		}
+		return stmt
+	}
+}
+
+// Parses the return statement, 'return a, b' returns several values if the multiple is set
+func (p *Parser) parseReturnStatement(multiple bool) Statement {
+	p.consume(chToken.RETURN)
+	if p.functionScopeLevel == 0 {
+		p.reportError(&compilerError.SyntaxError{
+			Position:  p.current.Position,
+			ErrorLine: p.lexer.GetLineByPosition(p.current.Position),
+			Message:   "return statement outside of function",
+			Help:      "return statement can only be used inside a block statement: functions, if statements, loops etc.",
+		})
+		p.nextStatement()
+		return &BadStatement{}
+	}
+	spanStart := p.current.Position
+	if p.current.Type == chToken.SEMICOLON || p.current.Type == chToken.NEW_LINE {
+		return &ReturnStatement{Span: &chToken.Span{Start: spanStart, End: p.current.Position}}
+	}
+	var expr Expression
+	if multiple {
+		expr = p.parseTupleExpression()
+	} else {
+		expr = p.parseExpression()
+	}
+	if p.current.Type == chToken.SEMICOLON {
+		p.consume(p.current.Type)
+	}
+	return &ReturnStatement{Expression: expr, Span: &chToken.Span{Start: spanStart, End: p.current.Position}}
+}
+
+// parseTupleExpression parses the comma separated expressions 'a, b, c', a single expression is returned as is
+func (p *Parser) parseTupleExpression() Expression {
+	first := p.parseExpression()
+	if first == nil || p.current.Type != chToken.COMMA {
+		return first
+	}
+	tuple := &TupleExpression{Elements: []Expression{first}}
+	for p.current.Type == chToken.COMMA {
+		p.consume(chToken.COMMA)
+		tuple.Elements = append(tuple.Elements, p.parseExpression())
+	}
+	tuple.Span = &chToken.Span{Start: first.GetSpan().Start, End: p.current.Position}
+	return tuple
+}
+
+// Parses the parallel assignment 'a, b = b, a', all values of the right side are evaluated before the assignment
+func (p *Parser) parseTupleAssignment(first Expression) Expression {
+	left := &TupleExpression{Elements: []Expression{first}}
+	for p.current.Type == chToken.COMMA {
+		p.consume(chToken.COMMA)
+		// the assignment operator is parsed right after the primary expression, so the targets are parsed as primaries
+		left.Elements = append(left.Elements, p.processPrimary(p.parsePrimary()))
+	}
+	left.Span = &chToken.Span{Start: first.GetSpan().Start, End: p.current.Position}
+	operator := p.consume(chToken.ASSIGN)
+	right := p.parseTupleExpression()
+	return &AssignExpression{
+		Span:     &chToken.Span{Start: left.Span.Start, End: p.current.Position},
+		Operator: operator,
+		Left:     left,
+		Right:    right,
+	}
+}
+
+// Parses an expression statement or an increment/decrement statement: x = 1, f(), x++
+func (p *Parser) parseSimpleStatement() Statement {
+	expr := p.parseExpression()
+	if expr == nil {
 		return &ExpressionStatement{Expression: expr}
 	}
+	if p.current.Type == chToken.COMMA {
+		assign := p.parseTupleAssignment(expr)
+		return &ExpressionStatement{Expression: assign, Span: assign.GetSpan()}
+	}
+	if p.current.Type != chToken.INCREMENT && p.current.Type != chToken.DECREMENT {
+		return &ExpressionStatement{Expression: expr, Span: expr.GetSpan()}
+	}
+
+	op := p.consume(p.current.Type)
+	span := &chToken.Span{Start: expr.GetSpan().Start, End: p.current.Position}
+	assignOp := &chToken.Token{Type: chToken.PLUS_ASSIGN, Literal: "+=", Position: op.Position}
+	if op.Type == chToken.DECREMENT {
+		assignOp = &chToken.Token{Type: chToken.MINUS_ASSIGN, Literal: "-=", Position: op.Position}
+	}
+	return &IncDecStatement{
+		Span:     span,
+		Operator: op,
+		Target:   expr,
+		Assign: &AssignExpression{
+			Span:     span,
+			Operator: assignOp,
+			Left:     expr,
+			Right:    &IntLiteral{Span: &chToken.Span{Start: op.Position, End: p.current.Position}, Value: "1", Base: 10},
+		},
+	}
+}
+
+// Parses for-range loop 'for i in 1..10 { ... }', array loop 'for (idx, x) in arr { ... }'
+// or C-style loop 'for i = 0; i < 10; i++ { ... }'
+func (p *Parser) parseForStatement() Statement {
+	forToken := p.consume(chToken.FOR)
+	if p.current.Type == chToken.LEFT_PAREN {
+		p.consume(chToken.LEFT_PAREN)
+		index := p.parseIdentifier()
+		p.consume(chToken.COMMA)
+		value := p.parseIdentifier()
+		p.consume(chToken.RIGHT_PAREN)
+		p.consume(chToken.IN)
+		return &ForInStatement{
+			Index:    index,
+			Value:    value,
+			Iterable: p.parseExpressionWithStructLiterals(false),
+			Body:     p.parseBlockStatement(),
+			Span:     &chToken.Span{Start: forToken.Position, End: p.current.Position},
+		}
+	}
+	if p.current.Type == chToken.IDENTIFIER && p.peek().Type == chToken.IN {
+		return p.parseForInStatement(forToken)
+	}
+
+	stmt := &ForStatement{}
+
+	// the init clause declares the loop variables: 'i = 0' is the same as 'let i = 0'
+	switch p.current.Type {
+	case chToken.SEMICOLON:
+	case chToken.VAR:
+		stmt.Init = p.parseVarStatement()
+	default:
+		if p.current.Type == chToken.IDENTIFIER && p.peek().Type == chToken.ASSIGN {
+			name := p.parseIdentifier()
+			p.consume(chToken.ASSIGN)
+			stmt.Init = &VarDeclarationStatement{
+				Span:  &chToken.Span{Start: name.Span.Start},
+				Name:  name,
+				Value: p.parseExpressionWithStructLiterals(false),
+			}
+			stmt.Init.(*VarDeclarationStatement).Span.End = p.current.Position
+		} else {
+			stmt.Init = p.parseSimpleStatement()
+		}
+	}
+	p.consume(chToken.SEMICOLON)
+
+	if p.current.Type != chToken.SEMICOLON {
+		stmt.Condition = p.parseExpressionWithStructLiterals(false)
+	}
+	p.consume(chToken.SEMICOLON)
+
+	if p.current.Type != chToken.LEFT_BRACE {
+		previous := p.noStructLiteral
+		p.noStructLiteral = true
+		stmt.Post = p.parseSimpleStatement()
+		p.noStructLiteral = previous
+	}
+
+	stmt.Body = p.parseBlockStatement()
+	stmt.Span = &chToken.Span{Start: forToken.Position, End: p.current.Position}
+	return stmt
+}
+
+// Parses 'for x in arr { ... }' or 'for i in 1..10 { ... }' if the iterable is followed by a range operator
+func (p *Parser) parseForInStatement(forToken *chToken.Token) Statement {
+	identifier := p.parseIdentifier()
+
+	p.consume(chToken.IN)
+
+	rangeStart := p.current.Position
+	iterable := p.parseExpressionWithStructLiterals(false)
+	if p.current.Type != chToken.DOT_DOT && p.current.Type != chToken.DOT_DOT_EQUAL {
+		return &ForInStatement{
+			Value:    identifier,
+			Iterable: iterable,
+			Body:     p.parseBlockStatement(),
+			Span:     &chToken.Span{Start: forToken.Position, End: p.current.Position},
+		}
+	}
+
+	rangeNode := &RangeExpr{Inclusive: false, Span: &chToken.Span{Start: rangeStart}, Start: iterable}
+	operator := p.consume(p.current.Type)
+	if operator.Type == chToken.DOT_DOT_EQUAL {
+		rangeNode.Inclusive = true
+	}
+	rangeNode.Span.End = p.current.Position
+	rangeNode.End = p.parseExpressionWithStructLiterals(false)
+
+	block := p.parseBlockStatement()
+
+	return &ForRangeStatement{
+		Span:       &chToken.Span{Start: forToken.Position, End: p.current.Position},
+		Identifier: identifier,
+		Range:      rangeNode,
+		Body:       block,
+	}
 }
 
 func (p *Parser) parseIfExpression() *IfExpression {
 	startPos := p.current.Position
 	p.consume(chToken.IF)
-	condition := p.parseExpression()
+	condition := p.parseExpressionWithStructLiterals(false)
 	thenBlock := p.parseBlockStatement()
 	var elseBlock Statement
 	if p.current.Type == chToken.ELSE {
@@ -182,6 +486,84 @@ func (p *Parser) parseIfExpression() *IfExpression {
 	}
 }
 
+// Parses 'when x { 0 -> a, 1..=5 -> b, Shape.Circle(r) -> { ... }, else -> c }', arms are separated by commas or new lines
+func (p *Parser) parseWhenExpression() *WhenExpression {
+	whenToken := p.consume(chToken.WHEN)
+	expr := &WhenExpression{
+		Subject: p.parseExpressionWithStructLiterals(false),
+		Arms:    make([]*WhenArm, 0),
+	}
+
+	p.consume(chToken.LEFT_BRACE)
+	p.skipWhile(chToken.NEW_LINE)
+	for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
+		arm := &WhenArm{Span: &chToken.Span{Start: p.current.Position}}
+		if p.current.Type == chToken.ELSE {
+			p.consume(chToken.ELSE)
+		} else {
+			arm.Pattern = p.parseWhenPattern()
+		}
+		p.consume(chToken.ARROW)
+		arm.Body = p.parseWhenArmBody()
+		arm.Span.End = p.current.Position
+		expr.Arms = append(expr.Arms, arm)
+
+		if !p.expectOneOf(chToken.COMMA, chToken.NEW_LINE, chToken.RIGHT_BRACE) {
+			break
+		}
+		if p.current.Type == chToken.COMMA {
+			p.consume(chToken.COMMA)
+		}
+		p.skipWhile(chToken.NEW_LINE)
+	}
+	p.consume(chToken.RIGHT_BRACE)
+
+	expr.Span = &chToken.Span{Start: whenToken.Position, End: p.current.Position}
+	return expr
+}
+
+// Parses the pattern of the when arm, the range operator makes a range pattern: 1..=5
+func (p *Parser) parseWhenPattern() Expression {
+	start := p.current.Position
+	pattern := p.parseExpressionWithStructLiterals(false)
+	if p.current.Type != chToken.DOT_DOT && p.current.Type != chToken.DOT_DOT_EQUAL {
+		return pattern
+	}
+	operator := p.consume(p.current.Type)
+	rangeNode := &RangeExpr{
+		Start:     pattern,
+		End:       p.parseExpressionWithStructLiterals(false),
+		Inclusive: operator.Type == chToken.DOT_DOT_EQUAL,
+	}
+	rangeNode.Span = &chToken.Span{Start: start, End: p.current.Position}
+	return rangeNode
+}
+
+// Parses the body of the when arm: a block, a control flow statement or an expression
+func (p *Parser) parseWhenArmBody() *BlockStatement {
+	p.skipWhile(chToken.NEW_LINE)
+	switch p.current.Type {
+	case chToken.LEFT_BRACE:
+		return p.parseBlockStatement()
+	case chToken.RETURN, chToken.BREAK, chToken.CONTINUE:
+		var statement Statement
+		if p.current.Type == chToken.RETURN {
+			statement = p.parseReturnStatement(false) // the comma separates the arms
+		} else {
+			statement = p.parseStatement()
+		}
+		return &BlockStatement{Span: statement.GetSpan(), Statements: []Statement{statement}}
+	}
+	expr := p.parseExpressionWithStructLiterals(true)
+	if expr == nil {
+		return &BlockStatement{Statements: []Statement{}}
+	}
+	return &BlockStatement{
+		Span:       expr.GetSpan(),
+		Statements: []Statement{&ExpressionStatement{Expression: expr, Span: expr.GetSpan()}},
+	}
+}
+
 func (p *Parser) parseFunStatement() *FuncDeclarationStatement {
 	signature := p.parseFunSignature()
 	return p.createFunctionBySignature(signature)
@@ -206,19 +588,82 @@ func (p *Parser) createFunctionBySignature(signature *FunctionSignature) *FuncDe
 	return funcDeclaration
 }
 
-// Parses function signature 'fn name(arg1: type, arg2: type) -> return_type'
+// Parses the anonymous function 'fn(x: i32) -> i32 { x + k }'.
+// The last expression of the body is the result of the function, if the function has a return type.
+func (p *Parser) parseFunctionExpression() *FunctionExpression {
+	funToken := p.consume(chToken.FUNCTION)
+	signature := p.parseFunSignatureRest(funToken, nil)
+	body := p.createFunctionBySignature(signature).Body
+	if count := len(body.Statements); signature.ReturnType != nil && count > 0 {
+		if last, ok := body.Statements[count-1].(*ExpressionStatement); ok {
+			body.Statements[count-1] = &ReturnStatement{Span: last.GetSpan(), Expression: last.Expression}
+		}
+	}
+	return &FunctionExpression{
+		Span:      &chToken.Span{Start: funToken.Position, End: p.current.Position},
+		Signature: signature,
+		Body:      body,
+	}
+}
+
+// Parses function signature 'fn name<T>(arg1: type, arg2: type) -> return_type', the type parameters are optional
 func (p *Parser) parseFunSignature() *FunctionSignature {
 	funToken := p.consume(chToken.FUNCTION)
 	identifier := p.parseIdentifier()
+	var typeParams []*TypeParam
+	if p.current.Type == chToken.LESS {
+		typeParams = p.parseTypeParams()
+	}
+	signature := p.parseFunSignatureRest(funToken, identifier)
+	signature.TypeParams = typeParams
+	return signature
+}
+
+// Parses type parameters of the generic function or struct '<T, U: Display + Debug>'
+func (p *Parser) parseTypeParams() []*TypeParam {
+	p.consume(chToken.LESS)
+	params := make([]*TypeParam, 0)
+	for {
+		param := &TypeParam{Name: p.parseIdentifier()}
+		if p.current.Type == chToken.COLON {
+			p.consume(chToken.COLON)
+			param.Bounds = append(param.Bounds, p.parseIdentifier())
+			for p.current.Type == chToken.PLUS {
+				p.consume(chToken.PLUS)
+				param.Bounds = append(param.Bounds, p.parseIdentifier())
+			}
+		}
+		param.Span = &chToken.Span{Start: param.Name.Span.Start, End: p.current.Position}
+		params = append(params, param)
+		if p.current.Type != chToken.COMMA {
+			break
+		}
+		p.consume(chToken.COMMA)
+	}
+	p.consume(chToken.GREATER)
+	return params
+}
+
+// Parses the parameters and the return type of the function, the name is nil for anonymous functions
+func (p *Parser) parseFunSignatureRest(funToken *chToken.Token, identifier *Identifier) *FunctionSignature {
 	p.consume(chToken.LEFT_PAREN)
 	params := p.parseFnParameters()
 	p.consume(chToken.RIGHT_PAREN)
 
-	var returnType *Identifier
+	var returnType Expression
 	if p.current.Type == chToken.ARROW {
 		p.consume(chToken.ARROW)
-		p.expect(chToken.IDENTIFIER)
-		returnType = p.parseIdentifier()
+		returnType = p.parseTypeSpec()
+		if returnType != nil && p.current.Type == chToken.COMMA {
+			// several results: fn f() -> i32, f64
+			tuple := &TupleType{Types: []Expression{returnType}}
+			for p.current.Type == chToken.COMMA {
+				p.consume(chToken.COMMA)
+				tuple.Types = append(tuple.Types, p.parseTypeSpec())
+			}
+			tuple.Span = &chToken.Span{Start: returnType.GetSpan().Start, End: p.current.Position}
+			returnType = tuple
+		}
 	}
 
 	signature := &FunctionSignature{
@@ -230,6 +675,12 @@ func (p *Parser) parseFunSignature() *FunctionSignature {
 		Args: params,
 	}
 
+	// the first 'self' or '&self' parameter is a method receiver
+	if len(params) > 0 && params[0].Name.Value == "self" {
+		signature.SelfArg = params[0]
+		signature.Args = params[1:]
+	}
+
 	if returnType != nil {
 		signature.ReturnType = returnType
 	}
@@ -239,7 +690,7 @@ func (p *Parser) parseFunSignature() *FunctionSignature {
 
 func (p *Parser) parseFnParameters() []*FuncArgument {
 	params := make([]*FuncArgument, 0)
-	for p.current.Type != chToken.RIGHT_PAREN {
+	for p.current.Type != chToken.RIGHT_PAREN && p.current.Type != chToken.EOF {
 		self := false
 
 		// parse self argument if it is a method
@@ -248,6 +699,13 @@ func (p *Parser) parseFnParameters() []*FuncArgument {
 			self = true
 		}
 
+		// variadic argument: ...args: i32[]
+		spread := false
+		if p.current.Type == chToken.ELLIPSIS {
+			p.consume(chToken.ELLIPSIS)
+			spread = true
+		}
+
 		// parse function name
 		identifier := p.parseIdentifier()
 
@@ -255,14 +713,14 @@ func (p *Parser) parseFnParameters() []*FuncArgument {
 		var idType Expression
 		if p.current.Type == chToken.COLON {
 			p.consume(chToken.COLON)
-			p.expect(chToken.IDENTIFIER)
-			idType = p.parseIdentifier()
+			idType = p.parseTypeSpec()
 		}
 
 		arg := &FuncArgument{
-			Name: identifier,
-			Type: idType,
-			Ref:  self,
+			Name:   identifier,
+			Type:   idType,
+			Ref:    self,
+			Spread: spread,
 		}
 		params = append(params, arg)
 		if p.current.Type == chToken.COMMA {
@@ -276,7 +734,7 @@ func (p *Parser) parseConstStatement() *ConstDeclarationStatement {
 	constToken := p.consume(chToken.CONST)
 	identifier := p.parseIdentifier()
 
-	var varType *Identifier
+	var varType Expression
 	if p.current.Type == chToken.COLON {
 		p.consume(chToken.COLON)
 		varType = p.parseIdentifier()
@@ -284,7 +742,7 @@ func (p *Parser) parseConstStatement() *ConstDeclarationStatement {
 
 	p.consume(chToken.ASSIGN)
 	expression := p.parseExpression()
-	p.expectOneOf(chToken.SEMICOLON, chToken.NEW_LINE)
+	p.expectOneOf(chToken.SEMICOLON, chToken.NEW_LINE, chToken.EOF)
 
 	return &ConstDeclarationStatement{
 		ConstToken: constToken,
@@ -316,8 +774,13 @@ func (p *Parser) parseTypeStatement() *TypeDeclarationStatement {
 func (p *Parser) parseStructStatement() *StructDeclarationStatement {
 	structToken := p.consume(chToken.STRUCT)
 	name := p.parseIdentifier()
+	var typeParams []*TypeParam
+	if p.current.Type == chToken.LESS {
+		typeParams = p.parseTypeParams()
+	}
 	stmt := &StructDeclarationStatement{
-		Name: name,
+		Name:       name,
+		TypeParams: typeParams,
 		Span: &chToken.Span{
 			Start: structToken.Position,
 		},
@@ -330,14 +793,20 @@ func (p *Parser) parseStructStatement() *StructDeclarationStatement {
 	if p.current.Type == chToken.LEFT_BRACE {
 		structFieldStart := p.consume(chToken.LEFT_BRACE)
 		stmt.Body.Span.Start = structFieldStart.Position
-		for p.current.Type != chToken.RIGHT_BRACE || p.current.Type == chToken.EOF {
+		for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
 			p.skipWhile(chToken.NEW_LINE)
+			public := false
+			if p.current.Type == chToken.PUB {
+				p.consume(chToken.PUB)
+				public = true
+			}
 			id := p.parseIdentifier()
 			p.consume(chToken.COLON)
 			ty := p.parseTypeSpec()
 			field := &StructField{
-				Name:  id,
-				Value: ty,
+				Name:   id,
+				Value:  ty,
+				Public: public,
 			}
 			stmt.Body.Fields = append(stmt.Body.Fields, field)
 			if p.current.Type == chToken.COMMA {
@@ -353,6 +822,50 @@ func (p *Parser) parseStructStatement() *StructDeclarationStatement {
 	return stmt
 }
 
+// Parses enum declaration 'enum Shape { Point, Circle(f64), Rect(f64, f64) }'
+func (p *Parser) parseEnumStatement() *EnumDeclarationStatement {
+	enumToken := p.consume(chToken.ENUM)
+	stmt := &EnumDeclarationStatement{
+		Name:     p.parseIdentifier(),
+		Variants: make([]*EnumVariant, 0),
+	}
+
+	p.consume(chToken.LEFT_BRACE)
+	p.skipWhile(chToken.NEW_LINE)
+	for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
+		name := p.parseIdentifier()
+		variant := &EnumVariant{Name: name}
+		if p.current.Type == chToken.LEFT_PAREN {
+			p.consume(chToken.LEFT_PAREN)
+			for p.current.Type != chToken.RIGHT_PAREN && p.current.Type != chToken.EOF {
+				variant.Payload = append(variant.Payload, p.parseTypeSpec())
+				if p.current.Type != chToken.COMMA {
+					break
+				}
+				p.consume(chToken.COMMA)
+			}
+			p.consume(chToken.RIGHT_PAREN)
+		}
+		variant.Span = &chToken.Span{Start: name.Span.Start, End: p.current.Position}
+		stmt.Variants = append(stmt.Variants, variant)
+
+		if !p.expectOneOf(chToken.COMMA, chToken.NEW_LINE, chToken.RIGHT_BRACE) {
+			break
+		}
+		if p.current.Type == chToken.COMMA {
+			p.consume(chToken.COMMA)
+		}
+		p.skipWhile(chToken.NEW_LINE)
+	}
+	p.consume(chToken.RIGHT_BRACE)
+
+	stmt.Span = &chToken.Span{
+		Start: enumToken.Position,
+		End:   p.current.Position,
+	}
+	return stmt
+}
+
 func (p *Parser) parseTraitStatement() *TraitDeclarationStatement {
 	traitToken := p.consume(chToken.TRAIT)
 	trait := &TraitDeclarationStatement{
@@ -363,7 +876,7 @@ func (p *Parser) parseTraitStatement() *TraitDeclarationStatement {
 
 	p.consume(chToken.LEFT_BRACE)
 	p.skipWhile(chToken.NEW_LINE)
-	for p.current.Type != chToken.RIGHT_BRACE {
+	for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
 		sign := p.parseFunSignature()
 		if p.current.Type == chToken.LEFT_BRACE {
 			method := p.createFunctionBySignature(sign)
@@ -395,8 +908,18 @@ func (p *Parser) parseImplStatement() *ImplStatement {
 	if p.current.Type == chToken.BY {
 		p.consume(chToken.BY)
 		for p.current.Type != chToken.LEFT_BRACE {
-			id := p.parseIdentifier()
-			impl.Traits = append(impl.Traits, id)
+			var trait Expression = p.parseIdentifier()
+			if p.current.Type == chToken.DOT {
+				// trait of the imported package: geometry.Shape
+				pkg := trait.(*Identifier)
+				p.consume(chToken.DOT)
+				trait = &MemberExpression{
+					Left:   pkg,
+					Member: p.parseIdentifier(),
+					Span:   &chToken.Span{Start: pkg.Span.Start, End: p.current.Position},
+				}
+			}
+			impl.Traits = append(impl.Traits, trait)
 			ok := p.expectOneOf(chToken.COMMA, chToken.IDENTIFIER, chToken.LEFT_BRACE)
 			if !ok {
 				break
@@ -408,9 +931,15 @@ func (p *Parser) parseImplStatement() *ImplStatement {
 	}
 
 	p.consume(chToken.LEFT_BRACE)
-	for p.current.Type != chToken.RIGHT_BRACE {
-		p.skipWhile(chToken.NEW_LINE)
+	p.skipWhile(chToken.NEW_LINE)
+	for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
+		public := false
+		if p.current.Type == chToken.PUB {
+			p.consume(chToken.PUB)
+			public = true
+		}
 		method := p.parseFunStatement()
+		method.Public = public
 		impl.Methods = append(impl.Methods, method)
 
 		if p.current.Type == chToken.SEMICOLON {
@@ -427,9 +956,12 @@ func (p *Parser) parseImplStatement() *ImplStatement {
 	return impl
 }
 
-func (p *Parser) parseVarStatement() *VarDeclarationStatement {
+func (p *Parser) parseVarStatement() Statement {
 	letToken := p.consume(chToken.VAR)
 	identifier := p.parseIdentifier()
+	if p.current.Type == chToken.COMMA {
+		return p.parseTupleDeclaration(letToken, identifier)
+	}
 
 	var varType Expression
 	if p.current.Type == chToken.COLON {
@@ -455,11 +987,25 @@ func (p *Parser) parseVarStatement() *VarDeclarationStatement {
 	}
 }
 
+// Parses the destructuring declaration 'let a, b, c = f()', the value is a call of the function with several results or values 'let a, b = 1, 2'
+func (p *Parser) parseTupleDeclaration(letToken *chToken.Token, first *Identifier) *TupleDeclarationStatement {
+	stmt := &TupleDeclarationStatement{LetToken: letToken, Names: []*Identifier{first}}
+	for p.current.Type == chToken.COMMA {
+		p.consume(chToken.COMMA)
+		stmt.Names = append(stmt.Names, p.parseIdentifier())
+	}
+	p.consume(chToken.ASSIGN)
+	stmt.Value = p.parseTupleExpression()
+	p.expectOneOf(chToken.SEMICOLON, chToken.NEW_LINE, chToken.EOF)
+	stmt.Span = &chToken.Span{Start: letToken.Position, End: p.current.Position}
+	return stmt
+}
+
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Statements: make([]Statement, 0)}
 	if p.current.Type == chToken.LEFT_BRACE {
 		p.consume(chToken.LEFT_BRACE)
-		for p.current.Type != chToken.RIGHT_BRACE {
+		for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
 			statement := p.parseStatement()
 			if statement != nil {
 				block.Statements = append(block.Statements, statement)
@@ -488,8 +1034,25 @@ func (p *Parser) parseIdentifier() *Identifier {
 func (p *Parser) parseCallExpression(left Expression) *CallExpression {
 	p.consume(chToken.LEFT_PAREN)
 	args := make([]Expression, 0)
-	for p.current.Type != chToken.RIGHT_PAREN {
-		arg := p.parseExpression()
+	for p.current.Type != chToken.RIGHT_PAREN && p.current.Type != chToken.EOF {
+		var arg Expression
+		if p.current.Type == chToken.ELLIPSIS {
+			spread := p.consume(chToken.ELLIPSIS)
+			value := p.parseExpressionWithStructLiterals(true)
+			if value == nil {
+				// the missing expression is already reported: 'f(...)'
+				panic(bailout{})
+			}
+			arg = &SpreadExpression{
+				Span:       &chToken.Span{Start: spread.Position, End: value.GetSpan().End},
+				Expression: value,
+			}
+		} else {
+			arg = p.parseExpressionWithStructLiterals(true)
+			if arg == nil {
+				panic(bailout{})
+			}
+		}
 		p.skipWhile(chToken.NEW_LINE)
 		args = append(args, arg)
 		if p.current.Type == chToken.COMMA {
@@ -511,6 +1074,14 @@ func (p *Parser) parseExpression() Expression {
 	return p.parseBinaryExpression(0)
 }
 
+// parseExpressionWithStructLiterals parses the expression with struct literals allowed or not (see Parser.noStructLiteral)
+func (p *Parser) parseExpressionWithStructLiterals(allowed bool) Expression {
+	previous := p.noStructLiteral
+	p.noStructLiteral = !allowed
+	defer func() { p.noStructLiteral = previous }()
+	return p.parseExpression()
+}
+
 // Pratt parser for binary expressions
 func (p *Parser) parseBinaryExpression(min int) Expression {
 	spanStart := p.current.Position
@@ -566,7 +1137,7 @@ func (p *Parser) processPrimary(primary Expression) Expression {
 		return p.processPrimary(p.parseCallExpression(primary))
 	case chToken.LEFT_BRACKET:
 		p.consume(chToken.LEFT_BRACKET)
-		index := p.parseExpression()
+		index := p.parseExpressionWithStructLiterals(true)
 		p.consume(chToken.RIGHT_BRACKET)
 		return p.processPrimary(&IndexExpression{
 			Span: &chToken.Span{
@@ -576,6 +1147,12 @@ func (p *Parser) processPrimary(primary Expression) Expression {
 			Left:  primary,
 			Index: index,
 		})
+	case chToken.QUESTION:
+		p.consume(chToken.QUESTION)
+		return p.processPrimary(&TryExpression{
+			Span:       &chToken.Span{Start: spanStart, End: p.current.Position},
+			Expression: primary,
+		})
 	}
 
 	return primary
@@ -593,6 +1170,10 @@ func (p *Parser) parsePrimary() Expression {
 		}}
 	case chToken.IF:
 		return p.parseIfExpression()
+	case chToken.FUNCTION:
+		return p.parseFunctionExpression()
+	case chToken.WHEN:
+		return p.parseWhenExpression()
 	case chToken.INT_LITERAL:
 		token := p.consume(chToken.INT_LITERAL)
 		intBase := 10
@@ -603,8 +1184,9 @@ func (p *Parser) parsePrimary() Expression {
 		literal, suffix, err := parseNumberLiteralSuffix(token.Literal)
 		if err != nil {
 			position := chToken.TokenPosition{
-				Row:    token.Position.Row,
-				Column: token.Position.Column + len(literal) - 1,
+				Row:      token.Position.Row,
+				Column:   token.Position.Column + len(literal) - 1,
+				Filename: token.Position.Filename,
 			}
 			p.reportError(&compilerError.SyntaxError{
 				Position:  position,
@@ -629,8 +1211,9 @@ func (p *Parser) parsePrimary() Expression {
 		literal, suffix, err := parseNumberLiteralSuffix(token.Literal)
 		if err != nil {
 			position := chToken.TokenPosition{
-				Row:    token.Position.Row,
-				Column: token.Position.Column + len(literal) - 1,
+				Row:      token.Position.Row,
+				Column:   token.Position.Column + len(literal) - 1,
+				Filename: token.Position.Filename,
 			}
 			p.reportError(&compilerError.SyntaxError{
 				Position:  position,
@@ -654,33 +1237,22 @@ func (p *Parser) parsePrimary() Expression {
 			Start: startExprPos,
 			End:   p.current.Position,
 		}}
+	case chToken.STRING_TEMPLATE:
+		return p.parseStringTemplate()
 	case chToken.IDENTIFIER:
 		ident := p.parseIdentifier()
-		if p.current.Type == chToken.LEFT_BRACE { // struct initialization
-			p.consume(chToken.LEFT_BRACE)
-			fields := make([]*StructField, 0)
-			for p.current.Type != chToken.RIGHT_BRACE {
-				p.skipWhile(chToken.NEW_LINE)
-				id := p.parseIdentifier()
-				p.consume(chToken.COLON)
-				expr := p.parseExpression()
-				fields = append(fields, &StructField{Name: id, Value: expr})
-				if p.current.Type == chToken.COMMA {
-					p.consume(chToken.COMMA)
-				}
-				p.skipWhile(chToken.NEW_LINE)
-			}
-			p.consume(chToken.RIGHT_BRACE)
-			return &InitStructExpression{
-				Name:   ident,
-				Span:   &chToken.Span{Start: ident.Span.Start, End: p.current.Position},
-				Fields: fields,
-			}
+		if p.current.Type == chToken.DOT && p.peek().Type == chToken.IDENTIFIER && p.peekAt(2).Type == chToken.LEFT_BRACE && !p.noStructLiteral {
+			// initialization of the imported struct: geometry.Point { x: 1, y: 2 }
+			p.consume(chToken.DOT)
+			return p.parseStructLiteral(ident, p.parseIdentifier())
+		}
+		if p.current.Type == chToken.LEFT_BRACE && !p.noStructLiteral { // struct initialization
+			return p.parseStructLiteral(nil, ident)
 		}
 		return ident
 	case chToken.LEFT_PAREN:
 		p.consume(chToken.LEFT_PAREN)
-		expression := p.parseExpression()
+		expression := p.parseExpressionWithStructLiterals(true)
 		p.consume(chToken.RIGHT_PAREN)
 		return expression
 	case chToken.LEFT_BRACKET:
@@ -688,10 +1260,10 @@ func (p *Parser) parsePrimary() Expression {
 		p.consume(chToken.LEFT_BRACKET)
 
 		p.skipWhile(chToken.NEW_LINE)
-		for p.current.Type != chToken.RIGHT_BRACKET {
-			element := p.parseExpression()
+		for p.current.Type != chToken.RIGHT_BRACKET && p.current.Type != chToken.EOF {
+			element := p.parseExpressionWithStructLiterals(true)
 			if element == nil {
-				continue
+				break // the error is reported, e.g. the spread array '[...arr]'
 			}
 			expr.Elements = append(expr.Elements, element)
 			if p.current.Type == chToken.COMMA {
@@ -706,7 +1278,8 @@ func (p *Parser) parsePrimary() Expression {
 		return expr
 	case chToken.PLUS, chToken.MINUS, chToken.BANG:
 		op := p.consume(p.current.Type)
-		expression := p.parseExpression()
+		// prefix operators bind tighter than any binary operator: -a + b is (-a) + b
+		expression := p.processPrimary(p.parsePrimary())
 		return &UnaryExpression{Operator: op, Right: expression, Span: &chToken.Span{
 			Start: startExprPos,
 			End:   p.current.Position,
@@ -714,6 +1287,10 @@ func (p *Parser) parsePrimary() Expression {
 	}
 
 	previous := p.prev()
+	if previous == nil {
+		// the source starts with a token that can't begin an expression: '}'
+		previous = p.current
+	}
 	p.reportError(&compilerError.SyntaxError{
 		Position:  previous.Position,
 		ErrorLine: p.lexer.GetLineByPosition(previous.Position),
@@ -723,6 +1300,84 @@ func (p *Parser) parsePrimary() Expression {
 	return nil
 }
 
+// parseStructLiteral parses the fields of the struct initialization 'Point { x: 1, y: 2 }',
+// the package is set if the struct is imported
+func (p *Parser) parseStructLiteral(pkg, name *Identifier) *InitStructExpression {
+	start := name.Span.Start
+	if pkg != nil {
+		start = pkg.Span.Start
+	}
+	p.consume(chToken.LEFT_BRACE)
+	fields := make([]*StructField, 0)
+	for p.current.Type != chToken.RIGHT_BRACE && p.current.Type != chToken.EOF {
+		p.skipWhile(chToken.NEW_LINE)
+		id := p.parseIdentifier()
+		p.consume(chToken.COLON)
+		expr := p.parseExpression()
+		fields = append(fields, &StructField{Name: id, Value: expr})
+		if p.current.Type == chToken.COMMA {
+			p.consume(chToken.COMMA)
+		}
+		p.skipWhile(chToken.NEW_LINE)
+	}
+	p.consume(chToken.RIGHT_BRACE)
+	return &InitStructExpression{
+		Package: pkg,
+		Name:    name,
+		Span:    &chToken.Span{Start: start, End: p.current.Position},
+		Fields:  fields,
+	}
+}
+
+// parseStringTemplate parses the string with the embedded expressions "x = $x" into the text and expression parts.
+// The text parts are the string literals quoted like the template.
+func (p *Parser) parseStringTemplate() *StringTemplate {
+	templateToken := p.consume(chToken.STRING_TEMPLATE)
+	quote := templateToken.Literal[:1]
+	template := &StringTemplate{Span: &chToken.Span{Start: templateToken.Position, End: p.current.Position}}
+	for _, part := range templateToken.Metadata.StringParts {
+		if part.Expression {
+			template.Parts = append(template.Parts, p.parseTemplateExpression(part))
+		} else if part.Text != "" {
+			template.Parts = append(template.Parts, &StringLiteral{Value: quote + part.Text + quote, Span: template.Span})
+		}
+	}
+	return template
+}
+
+// parseTemplateExpression parses the source of the expression embedded into the string template
+func (p *Parser) parseTemplateExpression(part chToken.StringPart) Expression {
+	sub := Init(p.lexer.Sub(part))
+	defer func() {
+		p.errors = append(p.errors, sub.errors...)
+	}()
+
+	sub.checkIllegal()
+	if sub.current.Type == chToken.EOF {
+		sub.reportError(&compilerError.SyntaxError{
+			Position:  part.Position,
+			ErrorLine: p.lexer.GetLineByPosition(part.Position),
+			Message:   "empty expression in the string template",
+			Help:      "embed a single expression: ${expr}",
+		})
+		panic(bailout{})
+	}
+	expression := sub.parseExpressionWithStructLiterals(true)
+	if expression == nil {
+		panic(bailout{})
+	}
+	if sub.current.Type != chToken.EOF {
+		sub.reportError(&compilerError.SyntaxError{
+			Position:  sub.current.Position,
+			ErrorLine: p.lexer.GetLineByPosition(sub.current.Position),
+			Message:   fmt.Sprintf("unexpected '%s' in the expression of the string template", sub.current.Literal),
+			Help:      "embed a single expression: ${expr}",
+		})
+		panic(bailout{})
+	}
+	return expression
+}
+
 func (p *Parser) parseTypeSpec() Expression {
 	start := p.current.Position
 	primary := p.parseTypePrimary()
@@ -749,12 +1404,82 @@ func (p *Parser) parseTypeSpec() Expression {
 func (p *Parser) parseTypePrimary() Expression {
 	switch p.current.Type {
 	case chToken.IDENTIFIER:
-		return p.parseIdentifier()
+		name := p.parseIdentifier()
+		var pkg *Identifier
+		if p.current.Type == chToken.DOT {
+			// type of the imported package: geometry.Point
+			p.consume(chToken.DOT)
+			pkg, name = name, p.parseIdentifier()
+		}
+		if p.current.Type != chToken.LESS {
+			if pkg != nil {
+				return &MemberExpression{
+					Span:   &chToken.Span{Start: pkg.Span.Start, End: p.current.Position},
+					Left:   pkg,
+					Member: name,
+				}
+			}
+			return name
+		}
+		// type arguments: Result<i32, string>
+		p.consume(chToken.LESS)
+		generic := &GenericType{Package: pkg, Name: name, Args: make([]Expression, 0)}
+		for {
+			spec := p.parseTypeSpec()
+			if spec == nil {
+				return &BadExpression{}
+			}
+			generic.Args = append(generic.Args, spec)
+			if p.current.Type != chToken.COMMA {
+				break
+			}
+			p.consume(chToken.COMMA)
+		}
+		p.consumeClosingAngle()
+		generic.Span = &chToken.Span{Start: name.Span.Start, End: p.current.Position}
+		if pkg != nil {
+			generic.Span.Start = pkg.Span.Start
+		}
+		return generic
+	case chToken.FUNCTION: // function type: fn(i32, i32) -> i32, the return type is optional
+		fnToken := p.consume(chToken.FUNCTION)
+		p.consume(chToken.LEFT_PAREN)
+
+		fnType := &FunctionType{}
+		for p.current.Type != chToken.RIGHT_PAREN && p.current.Type != chToken.EOF {
+			spread := false
+			if p.current.Type == chToken.ELLIPSIS {
+				p.consume(chToken.ELLIPSIS)
+				spread = true
+			}
+			spec := p.parseTypeSpec()
+			if spec == nil {
+				return &BadExpression{}
+			}
+			if spread {
+				// the variadic arguments are the last ones
+				fnType.Spread = spec
+				break
+			}
+			fnType.Args = append(fnType.Args, spec)
+			if p.current.Type != chToken.COMMA {
+				break
+			}
+			p.consume(chToken.COMMA)
+		}
+		p.consume(chToken.RIGHT_PAREN)
+
+		if p.current.Type == chToken.ARROW {
+			p.consume(chToken.ARROW)
+			fnType.ReturnType = p.parseTypeSpec()
+		}
+		fnType.Span = &chToken.Span{Start: fnToken.Position, End: p.current.Position}
+		return fnType
 	case chToken.LEFT_PAREN: // function or group
 		startDelimiter := p.consume(chToken.LEFT_PAREN)
 
 		var args []Expression
-		for p.current.Type != chToken.RIGHT_PAREN {
+		for p.current.Type != chToken.RIGHT_PAREN && p.current.Type != chToken.EOF {
 			spec := p.parseTypeSpec()
 			if spec == nil {
 				return &BadExpression{}
@@ -827,8 +1552,7 @@ func (p *Parser) expect(t chToken.TokenType) bool {
 			Message:   message,
 			Help:      "",
 		})
-		log.Fatalf(message)
-		return false
+		panic(bailout{})
 	}
 
 	return true
@@ -872,6 +1596,8 @@ var statementStartTokens = map[chToken.TokenType]bool{
 	chToken.VAR:        true,
 	chToken.IF:         true,
 	chToken.FOR:        true,
+	chToken.WHILE:      true,
+	chToken.LOOP:       true,
 	chToken.RETURN:     true,
 	chToken.FUNCTION:   true,
 	chToken.LEFT_BRACE: true,
@@ -914,6 +1640,18 @@ func (p *Parser) consume(t chToken.TokenType) *chToken.Token {
 	return prev
 }
 
+// consumeClosingAngle consumes '>' closing the type arguments.
+// The scanner reads '>>' of the nested type arguments 'Option<Option<i32>>' as the shift operator, so it is split in two.
+func (p *Parser) consumeClosingAngle() {
+	if p.current.Type == chToken.RIGHT_SHIFT {
+		p.current.Type = chToken.GREATER
+		p.current.Literal = ">"
+		p.current.Position.Column++
+		return
+	}
+	p.consume(chToken.GREATER)
+}
+
 func (p *Parser) prev() *chToken.Token {
 	if p.index-1 < 0 {
 		return nil
@@ -922,11 +1660,16 @@ func (p *Parser) prev() *chToken.Token {
 }
 
 func (p *Parser) peek() *chToken.Token {
-	// preload the next token
-	if p.index+1 >= len(p.tokens) {
+	return p.peekAt(1)
+}
+
+// peekAt returns the token following the current one at the given distance
+func (p *Parser) peekAt(distance int) *chToken.Token {
+	// preload the next tokens
+	for p.index+distance >= len(p.tokens) {
 		p.tokens = append(p.tokens, p.lexer.Scan())
 	}
-	return &p.tokens[p.index+1]
+	return &p.tokens[p.index+distance]
 }
 
 func (p *Parser) next() *chToken.Token {
@@ -935,5 +1678,23 @@ func (p *Parser) next() *chToken.Token {
 		p.tokens = append(p.tokens, p.lexer.Scan())
 	}
 	p.current = &p.tokens[p.index]
+	p.checkIllegal()
 	return p.current
 }
+
+// checkIllegal aborts parsing if the scanner failed to produce the current token
+func (p *Parser) checkIllegal() {
+	if p.current.Type != chToken.ILLEGAL {
+		return
+	}
+	scannerErrors := p.lexer.Errors()
+	if len(scannerErrors) == 0 {
+		p.reportError(&compilerError.SyntaxError{
+			Position:  p.current.Position,
+			ErrorLine: p.lexer.GetLineByPosition(p.current.Position),
+			Message:   fmt.Sprintf("illegal token '%s'", p.current.Literal),
+		})
+	}
+	p.errors = append(p.errors, scannerErrors...)
+	panic(bailout{})
+}
@@ -1,7 +1,11 @@
+// The frontend package runs the compiler frontend stages: scanning, parsing, type checking and AST optimization.
+// It never exits the process, all problems are returned to the caller as diagnostics.
 package frontend
 
 import (
 	"fmt"
+	"io"
+	"log"
 	"os"
 
 	"github.com/usein-abilev/chlang/frontend/ast"
@@ -9,64 +13,185 @@ import (
 	"github.com/usein-abilev/chlang/frontend/checker/env"
 	"github.com/usein-abilev/chlang/frontend/errors"
 	"github.com/usein-abilev/chlang/frontend/scanner"
+	"github.com/usein-abilev/chlang/frontend/token"
 	"github.com/usein-abilev/chlang/frontend/transformer"
 )
 
-func Build(filepath string) *ast.Program {
-	bytes := readFileBytes(filepath)
-	source := string(bytes)
-	lexer, err := scanner.New(source)
-	if err != nil {
-		panic(fmt.Sprintf("Cannot create lexer: %s", err))
-	}
-	program, parserErrors := ast.Init(lexer).Parse()
+// Options configures a compilation
+type Options struct {
+	// Filename is reported in the positions of diagnostics
+	Filename string
 
-	if len(*parserErrors) > 0 {
-		for _, err := range *parserErrors {
-			switch e := err.(type) {
-			case *errors.SyntaxError:
-				e.Write(os.Stdout)
-			default:
-				fmt.Printf("Unknown error type: %T", e)
-			}
+	// Logger receives debug output: compilation stages, warnings and the symbol table.
+	// Debug output is discarded if the logger is nil.
+	Logger *log.Logger
+
+	// Warnings receives the warnings of the checker with their positions, e.g. unreachable when arms.
+	// Warnings are discarded if the writer is nil.
+	Warnings io.Writer
+
+	// Env is the environment the program is checked against.
+	// A new environment is created if it is nil.
+	Env *env.Env
+
+	// Root is the project directory the import paths are resolved from by Build.
+	// It defaults to the directory of the entry file.
+	Root string
+}
+
+// Compile compiles the source code into a checked and optimized AST.
+// If the source contains errors, the program is nil and the errors are returned.
+// Syntax errors are returned as *errors.SyntaxError and semantic errors as *errors.SemanticError,
+// failures of the checker itself are returned as *errors.InternalError.
+func Compile(source string, opts *Options) (program *ast.Program, diagnostics []errors.CompilerError) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	defer func() {
+		for _, diagnostic := range diagnostics {
+			setDiagnosticFilename(diagnostic, opts.Filename)
 		}
-		os.Exit(1)
+	}()
+
+	opts.logf("Scanning and parsing")
+	program, diagnostics = parse(opts.Filename, source)
+	if len(diagnostics) > 0 {
+		return nil, diagnostics
+	}
+	if len(program.Imports) > 0 {
+		imports := program.Imports[0]
+		return nil, []errors.CompilerError{&errors.SemanticError{
+			Message:  fmt.Sprintf("cannot import package %s, packages are imported only when the source file is built", imports.Paths[0].Value),
+			HelpMsg:  "run or build the file to resolve its imports from the project directory",
+			Span:     imports.Span,
+			Position: imports.Span.Start,
+		}}
 	}
 
-	env := env.NewEnv()
-	checker := checker.Check(program, env)
-	checker.Env.Print()
+	symbols := opts.Env
+	if symbols == nil {
+		symbols = env.NewEnv()
+	}
+	return check(program, symbols, opts)
+}
 
-	unusedSymbols := checker.Env.GetUnusedSymbols()
-	if len(unusedSymbols) > 0 {
-		fmt.Println("[warn] Unused symbols:")
-		for _, symbol := range unusedSymbols {
-			fmt.Printf("  %s\n", symbol.GetName())
+// parse scans and parses the source code of the file
+func parse(filename, source string) (_ *ast.Program, diagnostics []errors.CompilerError) {
+	lexer, err := scanner.NewFile(filename, source)
+	if err != nil {
+		return nil, []errors.CompilerError{&errors.SyntaxError{Message: err.Error()}}
+	}
+	// the parser panics on the syntax it doesn't expect instead of reporting it
+	defer func() {
+		if r := recover(); r != nil {
+			diagnostics = []errors.CompilerError{&errors.SyntaxError{
+				Position: token.TokenPosition{Filename: filename},
+				Message:  fmt.Sprintf("cannot parse the source: %v", r),
+			}}
 		}
+	}()
+	program, parserErrors := ast.Init(lexer).Parse()
+	if len(*parserErrors) > 0 {
+		return nil, toCompilerErrors(*parserErrors)
 	}
+	return program, nil
+}
 
-	if len(checker.Errors) > 0 {
-		for _, err := range checker.Errors {
-			switch e := err.(type) {
-			case *errors.SemanticError:
-				e.Write(os.Stdout)
-			default:
-				fmt.Printf("Unknown error type: %T", e)
+// check runs the type checker and the AST optimization on the parsed program
+func check(program *ast.Program, symbols *env.Env, opts *Options) (_ *ast.Program, diagnostics []errors.CompilerError) {
+	opts.logf("Type checking")
+	// some language features are not implemented in the checker yet and panic
+	defer func() {
+		if r := recover(); r != nil {
+			diagnostics = append(diagnostics, &errors.InternalError{Message: fmt.Sprint(r)})
+		}
+	}()
+	check := checker.Check(program, symbols)
+	if opts.Logger != nil {
+		check.Env.Write(opts.Logger.Writer())
+	}
+	for _, warning := range check.Warnings {
+		if diagnostic, ok := warning.(*errors.SemanticWarning); ok {
+			setDiagnosticFilename(diagnostic, opts.Filename)
+			if opts.Warnings != nil {
+				diagnostic.Write(opts.Warnings)
 			}
+			continue
 		}
-		os.Exit(1)
+		opts.logf("[warn] %s", warning)
+	}
+	for _, symbol := range check.Env.GetUnusedSymbols() {
+		opts.logf("[warn] unused symbol '%s'", symbol.GetName())
+	}
+	if len(check.Errors) > 0 {
+		return nil, toCompilerErrors(check.Errors)
 	}
 
 	// AST optimization phase
-	program = transformer.Transform(program)
+	opts.logf("Transforming AST")
+	return transformer.Transform(program), nil
+}
 
-	return program
+func (opts *Options) logf(format string, args ...any) {
+	if opts.Logger != nil {
+		opts.Logger.Printf(format, args...)
+	}
 }
 
-func readFileBytes(path string) []byte {
-	bytes, err := os.ReadFile(path)
+// CompileReader reads the source code from the reader and compiles it
+func CompileReader(r io.Reader, opts *Options) (*ast.Program, []errors.CompilerError) {
+	bytes, err := io.ReadAll(r)
 	if err != nil {
-		panic(fmt.Sprintf("Cannot read file: %s", path))
+		return nil, []errors.CompilerError{&errors.SyntaxError{Message: fmt.Sprintf("cannot read source: %s", err)}}
+	}
+	return Compile(string(bytes), opts)
+}
+
+// CompileFile reads the source file and compiles it.
+// Filename of the options defaults to the file path.
+func CompileFile(path string, opts *Options) (*ast.Program, []errors.CompilerError, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	fileOpts := Options{}
+	if opts != nil {
+		fileOpts = *opts
+	}
+	if fileOpts.Filename == "" {
+		fileOpts.Filename = path
+	}
+	program, diagnostics := CompileReader(file, &fileOpts)
+	return program, diagnostics, nil
+}
+
+func toCompilerErrors(list []error) []errors.CompilerError {
+	result := make([]errors.CompilerError, 0, len(list))
+	for _, err := range list {
+		if e, ok := err.(errors.CompilerError); ok {
+			result = append(result, e)
+		} else {
+			result = append(result, &errors.SemanticError{Message: err.Error()})
+		}
+	}
+	return result
+}
+
+func setDiagnosticFilename(diagnostic errors.CompilerError, filename string) {
+	switch e := diagnostic.(type) {
+	case *errors.SyntaxError:
+		if e.Position.Filename == "" {
+			e.Position.Filename = filename
+		}
+	case *errors.SemanticError:
+		if e.Position.Filename == "" {
+			e.Position.Filename = filename
+		}
+	case *errors.SemanticWarning:
+		if e.Position.Filename == "" {
+			e.Position.Filename = filename
+		}
 	}
-	return bytes
 }
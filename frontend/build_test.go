@@ -0,0 +1,33 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/usein-abilev/chlang/frontend/errors"
+)
+
+func TestCompileGenericStructImpl(t *testing.T) {
+	source := `
+struct Box<T> { value: T }
+
+impl Box {
+    fn get(&self) -> i32 {
+        return 1
+    }
+}
+`
+	_, diagnostics := Compile(source, &Options{Filename: "box.chl"})
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %v", len(diagnostics), diagnostics)
+	}
+	diagnostic, ok := diagnostics[0].(*errors.SemanticError)
+	if !ok {
+		t.Fatalf("got %T, want *errors.SemanticError", diagnostics[0])
+	}
+	if want := "cannot implement methods for generic struct 'Box'"; diagnostic.Message != want {
+		t.Errorf("message %q, want %q", diagnostic.Message, want)
+	}
+	if position := diagnostic.Position; position.Filename != "box.chl" || position.Row != 4 {
+		t.Errorf("reported at %s:%d, want box.chl:4", position.Filename, position.Row)
+	}
+}
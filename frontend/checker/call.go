@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+)
+
+// resolveSpreadArgument sets the spread type of the function declaring the variadic argument '...args: i32[]',
+// the argument is the array of the passed values in the function body
+func (c *Checker) resolveSpreadArgument(functionType *env.ChlangFunctionType, arg *ast.FuncArgument, argType env.ChlangType, last bool) {
+	if !last {
+		c.reportError(fmt.Sprintf("variadic argument '%s' must be the last one", arg.Name.Value), arg.Name.Span)
+		return
+	}
+	if argType == env.SymbolTypeInvalid {
+		return
+	}
+	arrayType, ok := env.Underlying(argType).(*env.ChlangArrayType)
+	if !ok || arrayType.Length != 0 {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("variadic argument '%s' must be an array, but got '%s'", arg.Name.Value, argType),
+			HelpMsg:  fmt.Sprintf("declare the element type of the arguments: ...%s: i32[]", arg.Name.Value),
+			Span:     arg.Name.Span,
+			Position: arg.Name.Span.Start,
+		})
+		return
+	}
+	functionType.SpreadType = arrayType.ElementType
+}
+
+// checkCallArguments checks the arguments of the call against the function type and returns the type of the called function,
+// the type arguments of the generic function are inferred from the arguments. It returns nil if the arguments are invalid.
+// The extra arguments of the variadic function must have the spread type, or the array is spread instead of them: f(1, ...arr)
+func (c *Checker) checkCallArguments(e *ast.CallExpression, fnSymbol *env.EnvSymbolEntity, functionType *env.ChlangFunctionType) *env.ChlangFunctionType {
+	fixed := len(functionType.Args)
+	if len(e.Args) != fixed && (functionType.SpreadType == nil || len(e.Args) < fixed) {
+		expects := fmt.Sprintf("%d", fixed)
+		if functionType.SpreadType != nil {
+			expects = "at least " + expects
+		}
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("function '%s' expects %s arguments, but got %d", fnSymbol.Name, expects, len(e.Args)),
+			Position: e.Span.Start,
+		})
+		return nil
+	}
+
+	argTypes := make([]env.ChlangType, 0, len(e.Args))
+	for _, argExpr := range e.Args {
+		if spread, ok := argExpr.(*ast.SpreadExpression); ok {
+			argTypes = append(argTypes, c.inferExpression(spread.Expression))
+			continue
+		}
+		argExprType := c.inferExpression(argExpr)
+		if !c.checkSingleValue(argExpr, argExprType) {
+			return nil
+		}
+		argTypes = append(argTypes, argExprType)
+	}
+	if len(functionType.TypeParams) > 0 {
+		functionType = c.instantiateFunction(e, fnSymbol, functionType, argTypes)
+		if functionType == nil {
+			return nil
+		}
+	}
+
+	for idx, argExpr := range e.Args {
+		argExprType := argTypes[idx]
+		if spread, ok := argExpr.(*ast.SpreadExpression); ok {
+			if !c.checkSpreadArgument(fnSymbol, functionType, spread, argExprType, idx, len(e.Args)) {
+				return nil
+			}
+			continue
+		}
+		if idx < fixed {
+			argSymbol := functionType.Args[idx]
+			if !env.IsLeftCompatibleType(argSymbol, argExprType) {
+				c.Errors = append(c.Errors, &errors.SemanticError{
+					Message:  fmt.Sprintf("function '%s' expects argument '%s' to be '%s', but got '%s'", fnSymbol.Name, functionType.Args[idx], argSymbol, argExprType),
+					Position: e.Span.Start,
+				})
+			}
+			continue
+		}
+		if argExprType == env.SymbolTypeInvalid {
+			return nil
+		}
+		if !env.IsLeftCompatibleType(functionType.SpreadType, argExprType) {
+			c.reportError(
+				fmt.Sprintf("function '%s' expects variadic arguments of type '%s', but got '%s'", fnSymbol.Name, functionType.SpreadType, argExprType),
+				argExpr.GetSpan(),
+			)
+		}
+	}
+	return functionType
+}
+
+// checkSpreadArgument checks the array passed instead of the variadic arguments, it must be the last argument of the call
+// following the fixed ones. The index is the position of the spread argument among the count arguments of the call.
+func (c *Checker) checkSpreadArgument(fnSymbol *env.EnvSymbolEntity, functionType *env.ChlangFunctionType, spread *ast.SpreadExpression, valueType env.ChlangType, index, count int) bool {
+	if functionType.SpreadType == nil {
+		c.reportError(fmt.Sprintf("cannot spread the array into function '%s', it has no variadic arguments", fnSymbol.Name), spread.Span)
+		return false
+	}
+	if fixed := len(functionType.Args); index < fixed {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("function '%s' expects %d arguments before the spread array, but got %d", fnSymbol.Name, fixed, index),
+			HelpMsg:  "pass the fixed arguments first and spread the rest: f(a, ...rest)",
+			Span:     spread.Span,
+			Position: spread.Span.Start,
+		})
+		return false
+	}
+	if index != len(functionType.Args) || index != count-1 {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  "the spread array must be the only variadic argument",
+			HelpMsg:  "pass the fixed arguments first and spread the rest: f(a, ...rest)",
+			Span:     spread.Span,
+			Position: spread.Span.Start,
+		})
+		return false
+	}
+
+	if valueType == env.SymbolTypeInvalid {
+		return false
+	}
+	arrayType, ok := valueType.(*env.ChlangArrayType)
+	if !ok {
+		c.reportError(fmt.Sprintf("cannot spread value of type '%s', expected an array", valueType), spread.Span)
+		return false
+	}
+	// the built-in functions accept the arguments of any type
+	if functionType.IsVariadic() && !env.IsLeftCompatibleType(functionType.SpreadType, arrayType.ElementType) {
+		c.reportError(
+			fmt.Sprintf("function '%s' expects variadic arguments of type '%s', but got array '%s'", fnSymbol.Name, functionType.SpreadType, arrayType),
+			spread.Span,
+		)
+		return false
+	}
+	return true
+}
@@ -2,7 +2,6 @@ package checker
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 
 	"github.com/usein-abilev/chlang/frontend/ast"
@@ -19,6 +18,22 @@ type Checker struct {
 
 	// Current function being checked
 	function *env.EnvSymbolEntity
+
+	// Body scope of the current function, it is used to find the variables captured by anonymous functions
+	functionScope *functionScope
+
+	// Type parameters visible in the generic function or struct being checked
+	typeParams map[string]*env.ChlangTypeParam
+
+	// Type arguments of the generic function calls, their operators are verified after all bodies are checked
+	typeArgUses []typeArgUse
+
+	// Number of loops enclosing the statement being checked in the current function, 'break' and 'continue' require one
+	loopDepth int
+
+	// Type declarations of the scope being populated that are not declared yet, by their names.
+	// A type referenced before its declaration is declared on demand, see lookupType
+	pendingTypes map[string]ast.Statement
 }
 
 // Check performs semantic analysis on the AST
@@ -34,21 +49,43 @@ func Check(program *ast.Program, env *env.Env) *Checker {
 	for _, statement := range program.Statements {
 		c.visitStatement(statement)
 	}
+	c.checkTypeArgConstraints()
 
 	return c
 }
 
 // Populates symbols declarations information. This method doesn't check any types conflict
-// It stores only declaration information with no types information at all, to solve the calling each other problem
+// It stores only declaration information with no types information at all, to solve the calling each other problem.
+// The types are declared first, then the impl blocks and the function signatures, so the order of the declarations
+// doesn't matter: a type used before its declaration is declared on demand (the files of a package are joined in any order)
 func (c *Checker) populateSymbolDeclarations(declarations []ast.Statement) {
+	prevPending := c.pendingTypes
+	c.pendingTypes = make(map[string]ast.Statement)
+	defer func() { c.pendingTypes = prevPending }()
+
+	// the redeclarations are not pending, declaring them reports the error
+	redeclared := make(map[ast.Statement]bool)
+	for _, statement := range declarations {
+		if name := typeDeclarationName(statement); name != "" {
+			if _, exists := c.pendingTypes[name]; exists {
+				redeclared[statement] = true
+			} else {
+				c.pendingTypes[name] = statement
+			}
+		}
+	}
+	for _, statement := range declarations {
+		if name := typeDeclarationName(statement); name != "" {
+			if redeclared[statement] {
+				c.declareType(statement)
+			} else {
+				c.declarePendingType(name)
+			}
+		}
+	}
+
 	for _, statement := range declarations {
 		switch decl := statement.(type) {
-		case *ast.TypeDeclarationStatement:
-			c.visitTypeDeclaration(decl)
-		case *ast.StructDeclarationStatement:
-			c.visitStructDeclaration(decl)
-		case *ast.TraitDeclarationStatement:
-			c.visitTraitDeclaration(decl)
 		case *ast.ImplStatement:
 			c.visitImplDeclaration(decl)
 		case *ast.FuncDeclarationStatement:
@@ -57,34 +94,100 @@ func (c *Checker) populateSymbolDeclarations(declarations []ast.Statement) {
 	}
 }
 
+// typeDeclarationName returns the name of the type declared by the statement, or an empty string for other statements
+func typeDeclarationName(statement ast.Statement) string {
+	switch decl := statement.(type) {
+	case *ast.TypeDeclarationStatement:
+		return decl.Name.Value
+	case *ast.StructDeclarationStatement:
+		return decl.Name.Value
+	case *ast.EnumDeclarationStatement:
+		return decl.Name.Value
+	case *ast.TraitDeclarationStatement:
+		return decl.Name.Value
+	}
+	return ""
+}
+
+func (c *Checker) declareType(statement ast.Statement) {
+	switch decl := statement.(type) {
+	case *ast.TypeDeclarationStatement:
+		c.visitTypeDeclaration(decl)
+	case *ast.StructDeclarationStatement:
+		c.visitStructDeclaration(decl)
+	case *ast.EnumDeclarationStatement:
+		c.visitEnumDeclaration(decl)
+	case *ast.TraitDeclarationStatement:
+		c.visitTraitDeclaration(decl)
+	}
+}
+
+// declarePendingType declares the type of the scope being populated if it is not declared yet
+func (c *Checker) declarePendingType(name string) {
+	if statement, ok := c.pendingTypes[name]; ok {
+		// removed before the declaration, so the type referring to itself is not declared twice
+		delete(c.pendingTypes, name)
+		c.declareType(statement)
+	}
+}
+
+// lookupType looks up the type by its name, the type declared later in the scope being populated is declared first
+func (c *Checker) lookupType(name string) *env.EnvTypeEntity {
+	c.declarePendingType(name)
+	return c.Env.LookupType(name)
+}
+
 // Adds built-in functions to the symbol table
 func (c *Checker) addBuiltinFunctions() {
 	c.Env.InsertSymbol(&env.EnvSymbolEntity{
 		Used: true,
 		Name: "println",
-		Type: &env.ChlangFunctionType{ // (...string) -> void
-			SpreadType: env.SymbolTypeString,
+		Type: &env.ChlangFunctionType{ // (...any) -> void
+			SpreadType: env.SymbolTypeAny,
 			Return:     env.SymbolTypeVoid,
 		},
 		EntityType: env.SymbolEntityFunction,
 	})
+
+	// constructors of the built-in Option and Result values
+	for _, enum := range []*env.ChlangEnumType{env.OptionEnum, env.ResultEnum} {
+		var variantType env.ChlangType = &env.ChlangOptionType{}
+		if enum == env.ResultEnum {
+			variantType = &env.ChlangResultType{}
+		}
+		for _, variant := range enum.Variants {
+			c.Env.InsertSymbol(&env.EnvSymbolEntity{
+				Used:       true,
+				Name:       variant.Name,
+				Type:       variantType,
+				EntityType: env.SymbolEntityVariant,
+			})
+		}
+	}
 }
 
 func (c *Checker) visitStatement(statement ast.Statement) {
 	switch stmt := statement.(type) {
 	case *ast.TypeDeclarationStatement,
 		*ast.StructDeclarationStatement,
-		*ast.TraitDeclarationStatement,
-		*ast.ImplStatement:
+		*ast.EnumDeclarationStatement:
 		// Types declarations are already processed in the 'populateSymbolDeclarations' method
+	case *ast.TraitDeclarationStatement:
+		// Methods signatures are already processed in the 'populateSymbolDeclarations' method
+		c.visitTraitBody(stmt)
+	case *ast.ImplStatement:
+		// Methods signatures are already processed in the 'populateSymbolDeclarations' method
+		c.visitImplBody(stmt)
 	case *ast.ConstDeclarationStatement:
 		c.visitConstDeclaration(stmt)
 	case *ast.VarDeclarationStatement:
 		c.visitVarDeclaration(stmt)
+	case *ast.TupleDeclarationStatement:
+		c.visitTupleDeclaration(stmt)
 	case *ast.FuncDeclarationStatement:
 		c.visitFuncBody(stmt)
 	case *ast.ExpressionStatement:
-		c.inferExpression(stmt.Expression)
+		stmt.Type = c.inferExpression(stmt.Expression)
 	case *ast.ForRangeStatement:
 		c.Env.OpenScope()
 
@@ -100,11 +203,89 @@ func (c *Checker) visitStatement(statement ast.Statement) {
 		stmt.Identifier.Symbol = rangeVar
 
 		// We don't need a new block scope, because the for-range statement is already a block statement
+		c.loopDepth++
 		for _, statement := range stmt.Body.Statements {
 			c.visitStatement(statement)
 		}
+		c.loopDepth--
+
+		c.Env.CloseScope()
+	case *ast.ForInStatement:
+		iterableType := c.inferExpression(stmt.Iterable)
+		arrayType, ok := iterableType.(*env.ChlangArrayType)
+		if !ok {
+			if iterableType != env.SymbolTypeInvalid {
+				c.reportError(fmt.Sprintf("cannot iterate over '%s', expected an array", iterableType), stmt.Iterable.GetSpan())
+			}
+			arrayType = &env.ChlangArrayType{ElementType: env.SymbolTypeInvalid}
+		}
 
+		c.Env.OpenScope()
+		if stmt.Index != nil {
+			c.insertLoopVariable(stmt.Index, env.SymbolTypeInt32)
+		}
+		c.insertLoopVariable(stmt.Value, arrayType.ElementType)
+		// We don't need a new block scope, because the for-in statement is already a block statement
+		c.loopDepth++
+		for _, statement := range stmt.Body.Statements {
+			c.visitStatement(statement)
+		}
+		c.loopDepth--
+		c.Env.CloseScope()
+	case *ast.ForStatement:
+		// the loop variables are visible in the condition, body and post statement only
+		c.Env.OpenScope()
+		if stmt.Init != nil {
+			c.visitStatement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			condType := c.inferExpression(stmt.Condition)
+			if condType != env.SymbolTypeBool && condType != env.SymbolTypeInvalid {
+				c.Errors = append(c.Errors, &errors.SemanticError{
+					Message:  fmt.Sprintf("invalid condition type expected 'bool', but got '%s'", condType),
+					Span:     stmt.Condition.GetSpan(),
+					Position: stmt.Condition.GetSpan().Start,
+				})
+			}
+		}
+		c.visitLoopBody(stmt.Body)
+		if stmt.Post != nil {
+			c.visitStatement(stmt.Post)
+		}
 		c.Env.CloseScope()
+	case *ast.IncDecStatement:
+		targetType := c.inferExpression(stmt.Target)
+		if targetType == env.SymbolTypeInvalid {
+			return
+		}
+		if primitive, ok := targetType.(env.ChlangPrimitiveType); !ok || !primitive.IsNumeric() {
+			c.reportError(fmt.Sprintf("operator '%s' requires a numeric operand, but got '%s'", stmt.Operator.Literal, targetType), stmt.Span)
+			return
+		} else if primitive.IsFloat() {
+			// integer literals are not compatible with floats, so the step becomes '1.0'
+			stmt.Assign.Right = &ast.FloatLiteral{Span: stmt.Assign.Right.GetSpan(), Value: "1.0"}
+		}
+		c.inferExpression(stmt.Assign)
+	case *ast.WhileStatement:
+		condType := c.inferExpression(stmt.Condition)
+		if condType != env.SymbolTypeBool && condType != env.SymbolTypeInvalid {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("invalid condition type expected 'bool', but got '%s'", condType),
+				Span:     stmt.Condition.GetSpan(),
+				Position: stmt.Span.Start,
+			})
+		}
+		c.visitLoopBody(stmt.Body)
+	case *ast.LoopStatement:
+		c.visitLoopBody(stmt.Body)
+	case *ast.BreakStatement:
+		if c.loopDepth == 0 {
+			c.reportError("'break' statement outside of a loop", stmt.Span)
+		}
+	case *ast.ContinueStatement:
+		if c.loopDepth == 0 {
+			c.reportError("'continue' statement outside of a loop", stmt.Span)
+		}
 	case *ast.BlockStatement:
 		c.Env.OpenScope()
 		c.populateSymbolDeclarations(stmt.Statements)
@@ -113,12 +294,13 @@ func (c *Checker) visitStatement(statement ast.Statement) {
 		}
 		c.Env.CloseScope()
 	case *ast.ReturnStatement:
-		exprReturnType := c.inferExpression(stmt.Expression)
 		if c.function == nil {
+			c.inferExpression(stmt.Expression)
 			c.reportError("unexpected 'return' statement outside the function", stmt.Span)
 			return
 		}
 		signature := c.function.Type.(*env.ChlangFunctionType)
+		exprReturnType := c.inferValue(stmt.Expression, signature.Return)
 		if !env.IsLeftCompatibleType(signature.Return, exprReturnType) {
 			c.reportError(
 				fmt.Sprintf("function '%s' returns '%s', but expression type is '%s'", c.function.Name, signature.Return, exprReturnType),
@@ -126,31 +308,45 @@ func (c *Checker) visitStatement(statement ast.Statement) {
 			)
 		}
 	default:
-		log.Printf("[checker.go]: Unknown statement type: %T", stmt)
+		c.Warnings = append(c.Warnings, fmt.Errorf("unknown statement type: %T", stmt))
 	}
 }
 
+func (c *Checker) visitLoopBody(body *ast.BlockStatement) {
+	c.loopDepth++
+	c.visitStatement(body)
+	c.loopDepth--
+}
+
 func (c *Checker) visitTypeDeclaration(stmt *ast.TypeDeclarationStatement) {
-	panic("Type declaration is not implemented yet")
-	// if sym := c.Env.LookupType(stmt.Name.Value); sym != nil {
-	// 	c.Errors = append(c.Errors, &errors.SemanticError{
-	// 		Message:  fmt.Sprintf("type '%s' has already been declared at %s", stmt.Name.Value, sym.Span),
-	// 		Span:     stmt.Span,
-	// 		Position: stmt.Span.Start,
-	// 	})
-	// 	return
-	// }
-
-	// typeSpec := c.resolveASTType(stmt.Spec)
-	// typeEntity := &env.EnvTypeEntity{
-	// 	Name: stmt.Name.Value,
-	// 	Used: false,
-	// 	Spec: &env.ChlangUserType{
-	// 		Name: stmt.Name.Value,
-	// 		Spec: typeSpec,
-	// 	},
-	// }
-	// c.Env.InsertType(typeEntity)
+	if sym := c.Env.LookupType(stmt.Name.Value); sym != nil {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("type '%s' has already been declared at %s", stmt.Name.Value, sym.Span),
+			Span:     stmt.Span,
+			Position: stmt.Span.Start,
+		})
+		return
+	}
+
+	typeSpec := c.resolveASTType(stmt.Spec)
+	if typeSpec == env.SymbolTypeInvalid {
+		return
+	}
+	if typeSpec == env.SymbolTypeVoid {
+		c.reportError(fmt.Sprintf("cannot declare type '%s' as 'void'", stmt.Name.Value), stmt.Span)
+		return
+	}
+
+	c.Env.InsertType(&env.EnvTypeEntity{
+		Name: stmt.Name.Value,
+		Used: false,
+		Spec: &env.ChlangUserType{
+			Name: stmt.Name.Value,
+			Spec: typeSpec,
+		},
+		Span:   stmt.Span,
+		Public: stmt.Public,
+	})
 }
 
 func (c *Checker) visitStructDeclaration(stmt *ast.StructDeclarationStatement) {
@@ -163,9 +359,13 @@ func (c *Checker) visitStructDeclaration(stmt *ast.StructDeclarationStatement) {
 	}
 
 	structType := &env.ChlangStructType{
-		Name:   stmt.Name.Value,
-		Fields: make([]*env.ChlangStructField, 0),
+		Name:       stmt.Name.Value,
+		Fields:     make([]*env.ChlangStructField, 0),
+		TypeParams: c.declareTypeParams(stmt.TypeParams),
 	}
+	prevTypeParams := c.enterTypeParams(structType.TypeParams)
+	defer func() { c.typeParams = prevTypeParams }()
+
 	for _, field := range stmt.Body.Fields {
 		if exists := structType.LookupField(field.Name.Value); exists != nil {
 			c.Errors = append(c.Errors, &errors.SemanticError{
@@ -179,19 +379,61 @@ func (c *Checker) visitStructDeclaration(stmt *ast.StructDeclarationStatement) {
 			return
 		}
 		structType.Fields = append(structType.Fields, &env.ChlangStructField{
-			Name: field.Name.Value,
-			Type: fieldType,
+			Name:   field.Name.Value,
+			Type:   fieldType,
+			Public: field.Public,
 		})
 	}
 
 	structEntity := &env.EnvTypeEntity{
-		Name: stmt.Name.Value,
-		Used: false,
-		Spec: structType,
+		Name:   stmt.Name.Value,
+		Used:   false,
+		Spec:   structType,
+		Public: stmt.Public,
 	}
 	c.Env.InsertType(structEntity)
 }
 
+func (c *Checker) visitEnumDeclaration(stmt *ast.EnumDeclarationStatement) {
+	if sym := c.Env.LookupType(stmt.Name.Value); sym != nil {
+		c.reportError(
+			fmt.Sprintf("enum '%s' has already been declared at %s", stmt.Name.Value, sym.Span),
+			stmt.Span,
+		)
+		return
+	}
+
+	enumType := &env.ChlangEnumType{
+		Name:     stmt.Name.Value,
+		Variants: make([]*env.ChlangEnumVariant, 0),
+	}
+	// the enum is inserted before the variants, so payloads can refer to it
+	c.Env.InsertType(&env.EnvTypeEntity{
+		Name:   stmt.Name.Value,
+		Used:   false,
+		Spec:   enumType,
+		Span:   stmt.Span,
+		Public: stmt.Public,
+	})
+
+	for _, variant := range stmt.Variants {
+		if enumType.LookupVariant(variant.Name.Value) != nil {
+			c.reportError(fmt.Sprintf("duplicate variant '%s' in enum '%s'", variant.Name.Value, stmt.Name.Value), variant.Name.Span)
+			continue
+		}
+		enumVariant := &env.ChlangEnumVariant{Name: variant.Name.Value}
+		for _, payload := range variant.Payload {
+			payloadType := c.resolveASTType(payload)
+			if payloadType == env.SymbolTypeVoid {
+				c.reportError(fmt.Sprintf("variant '%s' of enum '%s' cannot hold a 'void' value", variant.Name.Value, stmt.Name.Value), payload.GetSpan())
+				payloadType = env.SymbolTypeInvalid
+			}
+			enumVariant.Payload = append(enumVariant.Payload, payloadType)
+		}
+		enumType.Variants = append(enumType.Variants, enumVariant)
+	}
+}
+
 func (c *Checker) visitTraitDeclaration(stmt *ast.TraitDeclarationStatement) {
 	if sym := c.Env.LookupType(stmt.Name.Value); sym != nil {
 		c.Errors = append(c.Errors, &errors.SemanticError{
@@ -202,22 +444,95 @@ func (c *Checker) visitTraitDeclaration(stmt *ast.TraitDeclarationStatement) {
 		return
 	}
 
-	// TODO: Implement trait type
-	traitEntity := &env.EnvTypeEntity{
-		Name: stmt.Name.Value,
-		Used: false,
-		Spec: &env.ChlangTraitType{
-			Name:       stmt.Name.Value,
-			Signatures: []*env.ChlangFunctionType{},
-			// Declarations: []*hir.Function{},
-		},
+	traitType := &env.ChlangTraitType{
+		Name:    stmt.Name.Value,
+		Methods: make([]*env.ChlangTraitMethod, 0),
+	}
+	// the trait is inserted before the methods, so signatures can refer to it
+	c.Env.InsertType(&env.EnvTypeEntity{
+		Name:   stmt.Name.Value,
+		Used:   false,
+		Spec:   traitType,
+		Span:   stmt.Span,
+		Public: stmt.Public,
+	})
+
+	for _, signature := range stmt.MethodSignatures {
+		c.addTraitMethod(traitType, signature, signature.Span, false)
+	}
+	for _, method := range stmt.MethodDeclarations {
+		if symbol := c.addTraitMethod(traitType, method.Signature, method.Span, true); symbol != nil {
+			method.Symbol = symbol
+		}
+	}
+}
+
+// Resolves the trait method signature and adds it to the trait, returns nil if the method is invalid
+func (c *Checker) addTraitMethod(traitType *env.ChlangTraitType, signature *ast.FunctionSignature, span *chToken.Span, hasDefault bool) *env.EnvSymbolEntity {
+	name := signature.Name.Value
+	if traitType.LookupMethod(name) != nil {
+		c.reportError(fmt.Sprintf("method '%s' is already declared in trait '%s'", name, traitType.Name), signature.Name.Span)
+		return nil
+	}
+	if signature.SelfArg == nil {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("method '%s' of trait '%s' must have a 'self' receiver", name, traitType.Name),
+			HelpMsg:  fmt.Sprintf("declare the receiver as the first argument: fn %s(&self)", name),
+			Position: span.Start,
+			Span:     signature.Span,
+		})
+		return nil
+	}
+	if !c.checkMethodTypeParams(signature, traitType.Name) {
+		return nil
+	}
+
+	methodSymbol := c.resolveFuncSymbol(signature, span)
+	if methodSymbol == nil {
+		return nil
+	}
+	c.addSelfArg(methodSymbol, signature.SelfArg, traitType)
+	traitType.Methods = append(traitType.Methods, &env.ChlangTraitMethod{
+		Name:       name,
+		Symbol:     methodSymbol,
+		HasDefault: hasDefault,
+	})
+	return methodSymbol
+}
+
+// Inserts the loop variable into the current scope
+func (c *Checker) insertLoopVariable(identifier *ast.Identifier, varType env.ChlangType) {
+	symbol := &env.EnvSymbolEntity{
+		Name:       identifier.Value,
+		Type:       varType,
+		EntityType: env.SymbolEntityVariable,
+		Span:       identifier.Span,
+	}
+	if !c.Env.InsertSymbol(symbol) {
+		c.reportError(fmt.Sprintf("loop variable '%s' has already been declared", identifier.Value), identifier.Span)
 	}
+	identifier.Symbol = symbol
+}
 
-	if len(stmt.MethodDeclarations) > 0 {
-		panic("Trait method declarations are not implemented yet")
+// Checks bodies of the default methods declared in the trait
+func (c *Checker) visitTraitBody(stmt *ast.TraitDeclarationStatement) {
+	for _, method := range stmt.MethodDeclarations {
+		if methodSymbol, ok := method.Symbol.(*env.EnvSymbolEntity); ok {
+			c.checkFuncBody(method, methodSymbol)
+		}
 	}
+}
 
-	c.Env.InsertType(traitEntity)
+// Adds the receiver as the first argument of the method
+func (c *Checker) addSelfArg(methodSymbol *env.EnvSymbolEntity, selfArg *ast.FuncArgument, receiverType env.ChlangType) {
+	self := &env.EnvSymbolEntity{
+		Name:       selfArg.Name.Value,
+		Used:       true,
+		Type:       receiverType,
+		EntityType: env.SymbolEntityVariable,
+		Span:       selfArg.Name.Span,
+	}
+	methodSymbol.FunctionArgs = append([]*env.EnvSymbolEntity{self}, methodSymbol.FunctionArgs...)
 }
 
 func (c *Checker) visitImplDeclaration(implStmt *ast.ImplStatement) {
@@ -230,46 +545,179 @@ func (c *Checker) visitImplDeclaration(implStmt *ast.ImplStatement) {
 		return
 	}
 
-	switch structType := receiverType.Spec.(type) {
-	case *env.ChlangStructType:
-		for _, implMethod := range implStmt.Methods {
-			fn := structType.LookupMethod(implMethod.Signature.Name.Value)
-			// check if the method is already implemented in the struct
-			if fn != nil {
-				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  fmt.Sprintf("method '%s' is already implemented in struct '%s'", implMethod.Signature.Name.Value, structType.Name),
-					Position: implMethod.Span.Start,
-				})
-			} else if (structType.LookupField(implMethod.Signature.Name.Value)) != nil {
-				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  fmt.Sprintf("method '%s' has the same name as a field in struct '%s'", implMethod.Signature.Name.Value, structType.Name),
-					Position: implMethod.Span.Start,
-				})
-			} else {
-				// TODO: check if the method signature is compatible with the trait method signature
+	structType, ok := env.Underlying(receiverType.Spec).(*env.ChlangStructType)
+	if !ok {
+		c.reportError(fmt.Sprintf("cannot implement methods for non-struct type '%s'", implStmt.Receiver.Value), implStmt.Receiver.Span)
+		return
+	}
+	if len(structType.TypeParams) > 0 {
+		c.reportError(fmt.Sprintf("cannot implement methods for generic struct '%s'", implStmt.Receiver.Value), implStmt.Receiver.Span)
+		return
+	}
+	receiverType.Used = true
+	implStmt.Type = structType
+	if structType.Methods == nil {
+		structType.Methods = make(map[string]*env.EnvSymbolEntity)
+	}
+
+	for _, implMethod := range implStmt.Methods {
+		name := implMethod.Signature.Name.Value
+		// check if the method is already implemented in the struct (inherited trait methods can be overridden)
+		if fn := structType.Methods[name]; fn != nil {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("method '%s' is already implemented in struct '%s' at %s", name, structType.Name, fn.Span),
+				Position: implMethod.Span.Start,
+				Span:     implMethod.Signature.Span,
+			})
+			continue
+		}
+		if structType.LookupField(name) != nil {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("method '%s' has the same name as a field in struct '%s'", name, structType.Name),
+				Position: implMethod.Span.Start,
+				Span:     implMethod.Signature.Span,
+			})
+			continue
+		}
+		if implMethod.Signature.SelfArg == nil {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("method '%s' of struct '%s' must have a 'self' receiver", name, structType.Name),
+				HelpMsg:  fmt.Sprintf("declare the receiver as the first argument: fn %s(&self)", name),
+				Position: implMethod.Span.Start,
+				Span:     implMethod.Signature.Span,
+			})
+			continue
+		}
+		if !c.checkMethodTypeParams(implMethod.Signature, structType.Name) {
+			continue
+		}
+
+		methodSymbol := c.resolveFuncSymbol(implMethod.Signature, implMethod.Span)
+		if methodSymbol == nil {
+			continue
+		}
+		// the receiver is passed as the first argument of the method
+		c.addSelfArg(methodSymbol, implMethod.Signature.SelfArg, structType)
+		methodSymbol.Public = implMethod.Public
+		structType.Methods[name] = methodSymbol
+		implMethod.Symbol = methodSymbol
+	}
+
+	for _, trait := range implStmt.Traits {
+		traitEntity := c.lookupTraitEntity(trait)
+		if traitEntity == nil {
+			continue
+		}
+		traitType, ok := env.Underlying(traitEntity.Spec).(*env.ChlangTraitType)
+		if !ok {
+			c.reportError(fmt.Sprintf("'%s' is not a trait", traitEntity.Name), trait.GetSpan())
+			continue
+		}
+		traitEntity.Used = true
+		if structType.Implements(traitType) {
+			c.reportError(fmt.Sprintf("struct '%s' already implements trait '%s'", structType.Name, traitType.Name), trait.GetSpan())
+			continue
+		}
+		c.checkTraitImplementation(implStmt, structType, traitType)
+		structType.Traits = append(structType.Traits, traitType)
+	}
+}
+
+// Returns the type named in the 'by' clause of the impl block, the trait may be exported by the imported package: geometry.Shape
+func (c *Checker) lookupTraitEntity(trait ast.Expression) *env.EnvTypeEntity {
+	switch trait := trait.(type) {
+	case *ast.MemberExpression:
+		return c.lookupPackageType(trait.Left.(*ast.Identifier), trait.Member)
+	case *ast.Identifier:
+		traitEntity := c.Env.LookupType(trait.Value)
+		if traitEntity == nil {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("trait '%s' not found", trait.Value),
+				Position: trait.Span.Start,
+				Span:     trait.Span,
+			})
+		}
+		return traitEntity
+	}
+	c.reportError(fmt.Sprintf("invalid trait name: %T", trait), trait.GetSpan())
+	return nil
+}
+
+// Checks that the impl block provides every method of the trait without a default body, with a compatible signature
+func (c *Checker) checkTraitImplementation(implStmt *ast.ImplStatement, structType *env.ChlangStructType, traitType *env.ChlangTraitType) {
+	for _, traitMethod := range traitType.Methods {
+		var implMethod *ast.FuncDeclarationStatement
+		for _, method := range implStmt.Methods {
+			if method.Signature.Name.Value == traitMethod.Name {
+				implMethod = method
+				break
 			}
 		}
-		for _, trait := range implStmt.Traits {
-			traitType := c.Env.LookupType(trait.Value)
-			if traitType == nil {
+		if implMethod == nil {
+			if !traitMethod.HasDefault {
 				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  fmt.Sprintf("trait '%s' not found", trait.Value),
-					Position: trait.Span.Start,
-					Span:     trait.Span,
+					Message:  fmt.Sprintf("struct '%s' does not implement method '%s' of trait '%s'", structType.Name, traitMethod.Name, traitType.Name),
+					HelpMsg:  fmt.Sprintf("add 'fn %s%s' to the impl block", traitMethod.Name, traitMethod.Symbol.Type),
+					Position: implStmt.Span.Start,
+					Span:     implStmt.Span,
 				})
-				return
 			}
+			continue
+		}
+
+		methodSymbol, ok := implMethod.Symbol.(*env.EnvSymbolEntity)
+		if !ok {
+			continue // the method is invalid and already reported
+		}
+		expected := traitMethod.Symbol.Type.(*env.ChlangFunctionType)
+		actual := methodSymbol.Type.(*env.ChlangFunctionType)
+		if !isSameFunctionType(expected, actual) {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("method '%s' of struct '%s' has type '%s', but trait '%s' requires '%s'", traitMethod.Name, structType.Name, actual, traitType.Name, expected),
+				Position: implMethod.Span.Start,
+				Span:     implMethod.Signature.Span,
+			})
+		}
+	}
+}
+
+// Returns true if both function types have the same arguments and return types
+func isSameFunctionType(a, b *env.ChlangFunctionType) bool {
+	if len(a.Args) != len(b.Args) {
+		return false
+	}
+	isSame := func(x, y env.ChlangType) bool {
+		return env.IsLeftCompatibleType(x, y) && env.IsLeftCompatibleType(y, x)
+	}
+	for i, arg := range a.Args {
+		if !isSame(arg, b.Args[i]) {
+			return false
+		}
+	}
+	if a.SpreadType != nil || b.SpreadType != nil {
+		if a.SpreadType == nil || b.SpreadType == nil || !isSame(a.SpreadType, b.SpreadType) {
+			return false
+		}
+	}
+	return isSame(a.Return, b.Return)
+}
 
-			// TODO: Check if the trait is implemented by the struct
+// Checks bodies of the methods declared in the impl block
+func (c *Checker) visitImplBody(implStmt *ast.ImplStatement) {
+	for _, method := range implStmt.Methods {
+		if methodSymbol, ok := method.Symbol.(*env.EnvSymbolEntity); ok {
+			c.checkFuncBody(method, methodSymbol)
 		}
 	}
-	panic("ImplStatement is not implemented yet")
 }
 
 func (c *Checker) resolveASTType(spec ast.Expression) env.ChlangType {
 	switch s := spec.(type) {
 	case *ast.Identifier:
-		ty := c.Env.LookupType(s.Value)
+		if param, ok := c.typeParams[s.Value]; ok {
+			return param
+		}
+		ty := c.lookupType(s.Value)
 		if ty == nil {
 			c.Errors = append(c.Errors, &errors.SemanticError{
 				Message:  fmt.Sprintf("type '%s' not found", s.Value),
@@ -277,8 +725,13 @@ func (c *Checker) resolveASTType(spec ast.Expression) env.ChlangType {
 			})
 			return env.SymbolTypeInvalid
 		}
-		ty.Used = true
-		return ty.Spec
+		return c.resolveNamedType(ty, s.Span)
+	case *ast.MemberExpression: // type exported by the imported package: geometry.Point
+		ty := c.lookupPackageType(s.Left.(*ast.Identifier), s.Member)
+		if ty == nil {
+			return env.SymbolTypeInvalid
+		}
+		return c.resolveNamedType(ty, s.Span)
 	case *ast.ArrayType:
 		arrayType := &env.ChlangArrayType{
 			ElementType: c.resolveASTType(s.Type),
@@ -286,7 +739,6 @@ func (c *Checker) resolveASTType(spec ast.Expression) env.ChlangType {
 		if s.Size != nil {
 			size, ok := s.Size.(*ast.IntLiteral)
 			if !ok {
-				fmt.Printf("[warn]: array size must be a constant integer: %T\n", s.Size)
 				c.Errors = append(c.Errors, &errors.SemanticError{
 					Message:  "array size must be a constant integer",
 					Position: s.Size.GetSpan().Start,
@@ -300,6 +752,28 @@ func (c *Checker) resolveASTType(spec ast.Expression) env.ChlangType {
 			arrayType.Length = int(length)
 		}
 		return arrayType
+	case *ast.FunctionType:
+		functionType := &env.ChlangFunctionType{Return: env.SymbolTypeVoid}
+		for _, arg := range s.Args {
+			argType := c.resolveASTType(arg)
+			if argType == env.SymbolTypeInvalid {
+				return env.SymbolTypeInvalid
+			}
+			functionType.Args = append(functionType.Args, argType)
+		}
+		if s.Spread != nil {
+			functionType.SpreadType = c.resolveASTType(s.Spread)
+			if functionType.SpreadType == env.SymbolTypeInvalid {
+				return env.SymbolTypeInvalid
+			}
+		}
+		if s.ReturnType != nil {
+			functionType.Return = c.resolveASTType(s.ReturnType)
+			if functionType.Return == env.SymbolTypeInvalid {
+				return env.SymbolTypeInvalid
+			}
+		}
+		return functionType
 	case *ast.StructType:
 		structType := &env.ChlangStructType{
 			Fields: make([]*env.ChlangStructField, 0),
@@ -309,18 +783,72 @@ func (c *Checker) resolveASTType(spec ast.Expression) env.ChlangType {
 			if fieldType == env.SymbolTypeInvalid {
 				return env.SymbolTypeInvalid
 			}
+			// the anonymous struct has no declaration to mark the fields with 'pub'
 			structType.Fields = append(structType.Fields, &env.ChlangStructField{
-				Name: field.Name.Value,
-				Type: fieldType,
+				Name:   field.Name.Value,
+				Type:   fieldType,
+				Public: true,
 			})
 		}
 		return structType
+	case *ast.GenericType:
+		return c.resolveGenericType(s)
+	case *ast.TupleType:
+		tupleType := &env.ChlangTupleType{}
+		for _, element := range s.Types {
+			elementType := c.resolveASTType(element)
+			if elementType == env.SymbolTypeInvalid {
+				return env.SymbolTypeInvalid
+			}
+			if elementType == env.SymbolTypeVoid {
+				c.reportError("cannot use 'void' as one of the function results", element.GetSpan())
+				return env.SymbolTypeInvalid
+			}
+			tupleType.Elements = append(tupleType.Elements, elementType)
+		}
+		return tupleType
 	default:
 		c.reportError(fmt.Sprintf("unknown type specification: %T", spec), spec.GetSpan())
 		return env.SymbolTypeInvalid
 	}
 }
 
+// resolveNamedType returns the type referenced by its name, the generic structs require the type arguments
+func (c *Checker) resolveNamedType(ty *env.EnvTypeEntity, span *chToken.Span) env.ChlangType {
+	if structType, ok := ty.Spec.(*env.ChlangStructType); ok && len(structType.TypeParams) > 0 {
+		c.reportError(fmt.Sprintf("generic struct '%s' expects %d type arguments", ty.Name, len(structType.TypeParams)), span)
+		return env.SymbolTypeInvalid
+	}
+	ty.Used = true
+	return ty.Spec
+}
+
+// checkConstantExpression checks that the value of the constant is computed by the compiler:
+// the expression is made of literals and operators
+func (c *Checker) checkConstantExpression(name string, expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntLiteral, *ast.FloatLiteral, *ast.BoolLiteral, *ast.StringLiteral:
+		return true
+	case *ast.UnaryExpression:
+		return c.checkConstantExpression(name, expr.Right)
+	case *ast.BinaryExpression:
+		if expr.Operator.Type == chToken.SLASH || expr.Operator.Type == chToken.PERCENT {
+			if divisor, ok := expr.Right.(*ast.IntLiteral); ok && divisor.Value == "0" {
+				c.reportError(fmt.Sprintf("division by zero in the value of constant '%s'", name), expr.Span)
+				return false
+			}
+		}
+		return c.checkConstantExpression(name, expr.Left) && c.checkConstantExpression(name, expr.Right)
+	}
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("value of constant '%s' is not known at compile time", name),
+		HelpMsg:  "initialize the constant with literals and operators: const MASK = -(1 << 4)",
+		Position: expr.GetSpan().Start,
+		Span:     expr.GetSpan(),
+	})
+	return false
+}
+
 func (c *Checker) visitConstDeclaration(stmt *ast.ConstDeclarationStatement) {
 	if ty := c.Env.LookupType(stmt.Name.Value); ty != nil {
 		c.reportError(fmt.Sprintf("cannot use type '%s' as a constant name", stmt.Name.Value), stmt.Span)
@@ -357,6 +885,9 @@ func (c *Checker) visitConstDeclaration(stmt *ast.ConstDeclarationStatement) {
 			return
 		}
 		constValueType = exprType.(env.ChlangPrimitiveType)
+		if !c.checkConstantExpression(stmt.Name.Value, stmt.Value) {
+			return
+		}
 	}
 
 	if stmt.Type != nil {
@@ -374,6 +905,7 @@ func (c *Checker) visitConstDeclaration(stmt *ast.ConstDeclarationStatement) {
 		Type:       constValueType,
 		EntityType: env.SymbolEntityConstant,
 		Span:       stmt.Span,
+		Public:     stmt.Public,
 	}
 	c.Env.InsertSymbol(symbol)
 	stmt.Symbol = symbol
@@ -389,13 +921,32 @@ func (c *Checker) visitVarDeclaration(stmt *ast.VarDeclarationStatement) {
 		return
 	}
 
-	var varType env.ChlangType
+	var varType, typeTag env.ChlangType
+	if stmt.Type != nil {
+		typeTag = c.resolveASTType(stmt.Type)
+	}
 	if stmt.Value != nil {
-		varType = c.inferExpression(stmt.Value)
+		varType = c.inferValue(stmt.Value, typeTag)
+		if tuple, ok := varType.(*env.ChlangTupleType); ok {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("assignment mismatch: 1 variable but %d values", len(tuple.Elements)),
+				HelpMsg:  "declare a variable for each value: let a, b = ...",
+				Span:     stmt.Span,
+				Position: stmt.Span.Start,
+			})
+			return
+		}
 		if stmt.Type == nil {
 			varType = c.getGeneralTypeOf(varType)
+			if env.HasUnknownType(varType) {
+				c.Errors = append(c.Errors, &errors.SemanticError{
+					Message:  fmt.Sprintf("cannot infer the type of variable '%s' from '%s'", stmt.Name.Value, varType),
+					HelpMsg:  fmt.Sprintf("add the type annotation: let %s: <type> = ...", stmt.Name.Value),
+					Position: stmt.Span.Start,
+				})
+				return
+			}
 		} else {
-			typeTag := c.resolveASTType(stmt.Type)
 			if !env.IsLeftCompatibleType(typeTag, varType) {
 				c.Errors = append(c.Errors, &errors.SemanticError{
 					Message:  fmt.Sprintf("variable '%s' has type '%s', but value type is '%s'", stmt.Name.Value, typeTag, varType),
@@ -412,7 +963,7 @@ func (c *Checker) visitVarDeclaration(stmt *ast.VarDeclarationStatement) {
 		})
 		return
 	} else {
-		varType = c.resolveASTType(stmt.Type)
+		varType = typeTag
 	}
 
 	if varType == env.SymbolTypeInvalid {
@@ -451,46 +1002,61 @@ func (c *Checker) visitFuncSignature(decl *ast.FuncDeclarationStatement) {
 		return
 	}
 
-	functionType := &env.ChlangFunctionType{}
+	if decl.Signature.SelfArg != nil {
+		c.reportError(fmt.Sprintf("function '%s' cannot have a 'self' receiver outside of an impl block", decl.Signature.Name.Value), decl.Signature.SelfArg.Name.Span)
+	}
+
+	funcSymbol := c.resolveFuncSymbol(decl.Signature, decl.Span)
+	if funcSymbol == nil {
+		return
+	}
+
+	// if the function is entry point, is already used
+	if decl.Signature.Name.Value == "main" {
+		funcSymbol.Used = true
+	}
+
+	funcSymbol.Public = decl.Public
+	if ok := c.Env.InsertSymbol(funcSymbol); !ok {
+		panic("unexpected error: function symbol already exists")
+	}
+	decl.Symbol = funcSymbol
+}
+
+// Resolves types of the function signature and creates a function symbol (w/o inserting it into the symbol table)
+// Returns nil if the return type is invalid
+func (c *Checker) resolveFuncSymbol(signature *ast.FunctionSignature, span *chToken.Span) *env.EnvSymbolEntity {
+	functionType := &env.ChlangFunctionType{TypeParams: c.declareTypeParams(signature.TypeParams)}
+	name := anonymousFunctionName
+	if signature.Name != nil {
+		name = signature.Name.Value
+	}
+	prevTypeParams := c.enterTypeParams(functionType.TypeParams)
+	defer func() { c.typeParams = prevTypeParams }()
 
 	// infer return type
-	if decl.Signature.ReturnType == nil {
+	if signature.ReturnType == nil {
 		functionType.Return = env.SymbolTypeVoid
 	} else {
-		functionType.Return = c.resolveASTType(decl.Signature.ReturnType)
+		functionType.Return = c.resolveASTType(signature.ReturnType)
 	}
 
 	if functionType.Return == env.SymbolTypeInvalid {
 		c.Errors = append(c.Errors, &errors.SemanticError{
-			Message:  fmt.Sprintf("invalid function '%s' return type", decl.Signature.Name.Value),
-			Position: decl.Span.Start,
+			Message:  fmt.Sprintf("invalid function '%s' return type", name),
+			Position: span.Start,
 		})
-		return
-	}
-
-	// if the function is entry point, is already used
-	used := false
-	if decl.Signature.Name.Value == "main" {
-		used = true
-
-		if functionType.Return != env.SymbolTypeVoid {
-			c.Errors = append(c.Errors, &errors.SemanticError{
-				Message:  "main function must return void",
-				Position: decl.Span.Start,
-			})
-			return
-		}
+		return nil
 	}
 
 	funcSymbol := &env.EnvSymbolEntity{
-		Name:       decl.Signature.Name.Value,
-		Used:       used,
+		Name:       name,
 		Type:       functionType,
 		EntityType: env.SymbolEntityFunction,
-		Span:       decl.Span,
+		Span:       span,
 	}
 
-	for _, arg := range decl.Signature.Args {
+	for idx, arg := range signature.Args {
 		argType := c.resolveASTType(arg.Type)
 		if argType == env.SymbolTypeInvalid {
 			c.Errors = append(c.Errors, &errors.SemanticError{
@@ -512,33 +1078,48 @@ func (c *Checker) visitFuncSignature(decl *ast.FuncDeclarationStatement) {
 			Used:       false,
 		}
 
-		functionType.Args = append(functionType.Args, argType)
+		if arg.Spread {
+			c.resolveSpreadArgument(functionType, arg, argType, idx == len(signature.Args)-1)
+		} else {
+			functionType.Args = append(functionType.Args, argType)
+		}
 
 		// Note that we don't insert the arguments into the symbol table because we don't check the function body here except for the arguments.
 		// Arguments will populates into the symbol table in 'visitFuncDeclaration' function
 		funcSymbol.FunctionArgs = append(funcSymbol.FunctionArgs, argSymbol)
 	}
 
-	if ok := c.Env.InsertSymbol(funcSymbol); !ok {
-		panic("unexpected error: function symbol already exists")
-	}
-	decl.Symbol = funcSymbol
+	return funcSymbol
 }
 
 // Checks function body for type matching
 func (c *Checker) visitFuncBody(stmt *ast.FuncDeclarationStatement) {
 	funcSymbol := c.Env.LookupSymbolLocal(stmt.Signature.Name.Value)
-	if funcSymbol == nil {
-		// Since function declarations populates in the 'populateSymbolDeclaration' method
-		// There is no way to have 'nil' result of lookup
-		panic(fmt.Sprintf("Unexpected nil as result of lookupInScope function '%s'", stmt.Signature.Name.Value))
+	if funcSymbol == nil || funcSymbol.EntityType != env.SymbolEntityFunction {
+		// the declaration of the function failed, its error is already reported
+		return
 	}
+	c.checkFuncBody(stmt, funcSymbol)
+}
 
+// Checks function or method body with the arguments of the function symbol
+func (c *Checker) checkFuncBody(stmt *ast.FuncDeclarationStatement, funcSymbol *env.EnvSymbolEntity) {
+	c.checkFunctionBody(stmt.Body, funcSymbol, false)
+	stmt.Symbol = funcSymbol
+}
+
+// checkFunctionBody visits the body of the named or anonymous function in the new function scope
+func (c *Checker) checkFunctionBody(body *ast.BlockStatement, funcSymbol *env.EnvSymbolEntity, anonymous bool) {
 	// check function arguments and visit function body
 	c.Env.OpenScope()
-	c.populateSymbolDeclarations(stmt.Body.Statements)
+	c.populateSymbolDeclarations(body.Statements)
 	prevFuncPtr := c.function
 	c.function = funcSymbol
+	// the loops enclosing the function don't continue in its body
+	prevLoopDepth := c.loopDepth
+	c.loopDepth = 0
+	prevTypeParams := c.enterTypeParams(funcSymbol.Type.(*env.ChlangFunctionType).TypeParams)
+	c.functionScope = &functionScope{symbol: funcSymbol, scope: c.Env.Local, anonymous: anonymous, parent: c.functionScope}
 
 	// Pushing arguments into symbol table, we didn't check types
 	// because it already done in the 'populateSymbolDeclarations' method
@@ -546,16 +1127,70 @@ func (c *Checker) visitFuncBody(stmt *ast.FuncDeclarationStatement) {
 		c.Env.InsertSymbol(arg)
 	}
 
-	c.visitStatement(stmt.Body)
+	c.visitStatement(body)
 	c.Env.CloseScope()
 	c.function = prevFuncPtr
-
-	stmt.Symbol = funcSymbol
+	c.loopDepth = prevLoopDepth
+	c.typeParams = prevTypeParams
+	c.functionScope = c.functionScope.parent
 }
 
 // Check expression type and return its internal type
+// Type aliases are resolved to their underlying types, so the callers don't need to unwrap them.
 // If the expression is nil, return env.SymbolTypeVoid
 func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
+	return env.Underlying(c.inferExpressionType(expr))
+}
+
+// inferValue infers the type of a value stored into a location of the expected type (nil if unknown).
+// Array literals take the element type from the expected type instead of inferring it from their elements.
+func (c *Checker) inferValue(value ast.Expression, expected env.ChlangType) env.ChlangType {
+	if array, ok := value.(*ast.ArrayExpression); ok && expected != nil {
+		if arrayType, ok := env.Underlying(expected).(*env.ChlangArrayType); ok {
+			return c.inferArrayExpression(array, arrayType.ElementType)
+		}
+	}
+	return c.inferExpression(value)
+}
+
+// inferArrayExpression infers the type of the array literal. If the element type is declared,
+// each element is checked against it, so an array of a trait can hold different structs:
+// let shapes: Shape[2] = [Circle { r: 1.0 }, Square { s: 2.0 }]
+func (c *Checker) inferArrayExpression(e *ast.ArrayExpression, elementType env.ChlangType) env.ChlangType {
+	if elementType != nil {
+		for _, elem := range e.Elements {
+			elemType := c.inferValue(elem, elementType)
+			if elemType != env.SymbolTypeInvalid && !env.IsLeftCompatibleType(elementType, elemType) {
+				c.Errors = append(c.Errors, &errors.SemanticError{
+					Message:  fmt.Sprintf("array element type mismatch: expected '%s', but got '%s'", elementType, elemType),
+					Position: elem.GetSpan().Start,
+				})
+			}
+		}
+		return &env.ChlangArrayType{ElementType: elementType, Length: len(e.Elements)}
+	}
+
+	arrayType := &env.ChlangArrayType{
+		ElementType: env.SymbolTypeInvalid,
+		Length:      len(e.Elements),
+	}
+	for _, elem := range e.Elements {
+		elemType := c.inferExpression(elem)
+		if arrayType.ElementType == env.SymbolTypeInvalid {
+			arrayType.ElementType = elemType
+		} else if !env.IsCompatibleType(arrayType.ElementType, elemType) {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("array element type mismatch: expected '%s', but got '%s'", arrayType.ElementType, elemType),
+				Position: elem.GetSpan().Start,
+			})
+		}
+		arrayType.ElementType = c.getMaxTypeOf(arrayType.ElementType, elemType)
+	}
+	return arrayType
+}
+
+// inferExpressionType returns the type of the expression, it may be a type alias
+func (c *Checker) inferExpressionType(expr ast.Expression) env.ChlangType {
 	if expr == nil {
 		return env.SymbolTypeVoid
 	}
@@ -566,12 +1201,32 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 			c.reportError(fmt.Sprintf("identifier '%s' not found", e.Value), e.Span)
 			return env.SymbolTypeInvalid
 		}
+		if sym.EntityType == env.SymbolEntityVariant && len(env.BuiltinVariantEnum(sym.Name).LookupVariant(sym.Name).Payload) > 0 {
+			c.reportError(fmt.Sprintf("variant '%s' expects a payload value: %s(...)", sym.Name, sym.Name), e.Span)
+			return env.SymbolTypeInvalid
+		}
+		if sym.EntityType == env.SymbolEntityVariable && !c.checkCapture(e) {
+			return env.SymbolTypeInvalid
+		}
+		if sym.EntityType == env.SymbolEntityPackage {
+			c.reportError(fmt.Sprintf("cannot use package '%s' as a value", e.Value), e.Span)
+			return env.SymbolTypeInvalid
+		}
+		if functionType, ok := sym.Type.(*env.ChlangFunctionType); ok && len(functionType.TypeParams) > 0 && sym.EntityType == env.SymbolEntityFunction {
+			c.reportError(fmt.Sprintf("cannot use generic function '%s' as a value, the type arguments are inferred at the call", e.Value), e.Span)
+			return env.SymbolTypeInvalid
+		}
 		sym.Used = true
 		e.Symbol = sym
 		return sym.Type
 	case *ast.InitStructExpression:
-		sym := c.Env.LookupType(e.Name.Value)
-		if sym == nil {
+		var sym *env.EnvTypeEntity
+		if e.Package != nil {
+			sym = c.lookupPackageType(e.Package, e.Name)
+			if sym == nil {
+				return env.SymbolTypeInvalid
+			}
+		} else if sym = c.Env.LookupType(e.Name.Value); sym == nil {
 			c.Errors = append(c.Errors, &errors.SemanticError{
 				Message:  fmt.Sprintf("struct '%s' not found", e.Name.Value),
 				Position: e.Span.Start,
@@ -579,7 +1234,7 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 			})
 			return env.SymbolTypeInvalid
 		}
-		structType, isStructType := sym.Spec.(*env.ChlangStructType)
+		structType, isStructType := env.Underlying(sym.Spec).(*env.ChlangStructType)
 		if !isStructType {
 			c.Errors = append(c.Errors, &errors.SemanticError{
 				Message:  fmt.Sprintf("'%s' is not a struct", e.Name.Value),
@@ -598,7 +1253,17 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		}
 
 		// type checking of struct fields
+		initialized := make(map[string]bool, len(e.Fields))
+		fieldTypes := make([]env.ChlangType, 0, len(e.Fields))
 		for _, field := range e.Fields {
+			if initialized[field.Name.Value] {
+				c.Errors = append(c.Errors, &errors.SemanticError{
+					Message:  fmt.Sprintf("field '%s' is initialized more than once", field.Name.Value),
+					Position: field.Name.Span.Start,
+				})
+				return env.SymbolTypeInvalid
+			}
+			initialized[field.Name.Value] = true
 			structField := structType.LookupField(field.Name.Value)
 			if structField == nil {
 				c.Errors = append(c.Errors, &errors.SemanticError{
@@ -607,7 +1272,19 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 				})
 				return env.SymbolTypeInvalid
 			}
-			fieldType := c.inferExpression(field.Value)
+			if !c.checkFieldAccess(structType, structField, field.Name.Span) {
+				return env.SymbolTypeInvalid
+			}
+			fieldTypes = append(fieldTypes, c.inferExpression(field.Value))
+		}
+		if len(structType.TypeParams) > 0 {
+			structType = c.inferStructTypeArgs(e, structType, fieldTypes)
+			if structType == nil {
+				return env.SymbolTypeInvalid
+			}
+		}
+		for idx, field := range e.Fields {
+			structField, fieldType := structType.LookupField(field.Name.Value), fieldTypes[idx]
 			if !env.IsLeftCompatibleType(structField.Type, fieldType) {
 				c.Errors = append(c.Errors, &errors.SemanticError{
 					Message:  fmt.Sprintf("field '%s' expects type '%s', but got '%s'", field.Name.Value, structField.Type, fieldType),
@@ -617,8 +1294,12 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 			}
 		}
 		sym.Used = true
-		return sym.Spec
+		e.Type = structType
+		return structType
 	case *ast.AssignExpression:
+		if _, ok := e.Left.(*ast.TupleExpression); ok {
+			return c.inferTupleAssignment(e)
+		}
 		leftType := c.inferExpression(e.Left)
 		rightType := c.inferExpression(e.Right)
 
@@ -627,14 +1308,14 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		}
 
 		if chToken.IsAssignment(e.Operator.Type) {
-			switch e.Left.(type) {
-			case *ast.Identifier:
-			case *ast.IndexExpression:
-			default:
-				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  "left side of an assignment must be an identifier",
-					Position: e.Span.Start,
-				})
+			if !c.checkAssignTarget(e.Left, e.Span) {
+				return env.SymbolTypeInvalid
+			}
+			if tuple, ok := rightType.(*env.ChlangTupleType); ok {
+				c.reportError(fmt.Sprintf("assignment mismatch: 1 variable but %d values", len(tuple.Elements)), e.Span)
+				return env.SymbolTypeInvalid
+			}
+			if e.Operator.Type != chToken.ASSIGN && !c.checkCompoundAssignment(leftType, rightType, e.Operator) {
 				return env.SymbolTypeInvalid
 			}
 			if !env.IsLeftCompatibleType(leftType, rightType) {
@@ -660,7 +1341,7 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		})
 		return env.SymbolTypeInvalid
 	case *ast.MemberExpression:
-		panic("MemberExpression is not implemented yet")
+		return c.inferMemberExpression(e)
 	case *ast.CallExpression:
 		var fnSymbol *env.EnvSymbolEntity
 
@@ -668,61 +1349,81 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		case *ast.Identifier:
 			sym := c.Env.LookupSymbol(callee.Value)
 			if sym == nil {
+				if target, ok := env.GetPrimitiveTypeByTag(callee.Value); ok {
+					return c.inferConversion(e, target)
+				}
 				c.reportError(fmt.Sprintf("function '%s' not found", callee.Value), e.Span)
 				return env.SymbolTypeInvalid
 			}
+			if sym.EntityType == env.SymbolEntityVariant {
+				callee.Symbol = sym
+				return c.inferBuiltinConstruction(e, sym.Name)
+			}
+			if sym.EntityType == env.SymbolEntityVariable {
+				fnSymbol = c.inferFunctionValue(callee, callee.Value)
+				if fnSymbol == nil {
+					return env.SymbolTypeInvalid
+				}
+				break
+			}
 			if sym.EntityType != env.SymbolEntityFunction {
 				c.reportError(fmt.Sprintf("'%s' is not a function", callee.Value), e.Span)
 				return env.SymbolTypeInvalid
 			}
+			callee.Symbol = sym
 			fnSymbol = sym
 		case *ast.MemberExpression:
-			panic("MemberExpression is not implemented yet")
-			// ty, monoFunc := c.inferMemberExpression(expr.(*ast.MemberExpression))
-			// if ty == env.SymbolTypeInvalid || monoFunc == nil {
-			// 	c.reportError(fmt.Sprintf("unknown function '%s'", callee.Member.Value), e.Span)
-			// 	return env.SymbolTypeInvalid
-			// }
-			// if _, ok := ty.(*env.ChlangFunctionType); !ok {
-			// 	c.reportError(fmt.Sprintf("'%s' is not a function", callee.Member.Value), e.Span)
-			// 	return env.SymbolTypeInvalid
-			// }
-			// functionType = ty.(*env.ChlangFunctionType)
-			// functionName = monoFunc.RawName
-		}
-
-		functionType := fnSymbol.Type.(*env.ChlangFunctionType)
-		if functionType.SpreadType == nil {
-			if len(functionType.Args) != len(e.Args) {
-				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  fmt.Sprintf("function '%s' expects %d arguments, but got %d", fnSymbol.Name, len(functionType.Args), len(e.Args)),
-					Position: e.Span.Start,
-				})
-				return env.SymbolTypeInvalid
-			}
-			for idx, argExpr := range e.Args {
-				argExprType := c.inferExpression(argExpr)
-				argSymbol := functionType.Args[idx]
-				if !env.IsLeftCompatibleType(argSymbol, argExprType) {
-					c.Errors = append(c.Errors, &errors.SemanticError{
-						Message:  fmt.Sprintf("function '%s' expects argument '%s' to be '%s', but got '%s'", fnSymbol.Name, functionType.Args[idx], argSymbol, argExprType),
-						Position: e.Span.Start,
-					})
+			if enumType, variant, ok := c.lookupEnumVariant(callee); ok {
+				if variant == nil {
+					return env.SymbolTypeInvalid
 				}
+				return c.inferEnumConstruction(e, enumType, variant)
 			}
-		} else {
-			for _, argExpr := range e.Args {
-				t := c.inferExpression(argExpr)
-				if t == env.SymbolTypeInvalid {
+			if pkg := c.lookupPackage(callee.Left); pkg != nil {
+				fnSymbol = c.lookupPackageSymbol(pkg, callee.Member)
+				if fnSymbol == nil {
 					return env.SymbolTypeInvalid
 				}
+				if fnSymbol.EntityType != env.SymbolEntityFunction {
+					c.reportError(fmt.Sprintf("'%s.%s' is not a function", pkg.Name, callee.Member.Value), e.Span)
+					return env.SymbolTypeInvalid
+				}
+				callee.LeftType = pkg
+				callee.Symbol = fnSymbol
+				break
+			}
+			fnSymbol = c.inferMethod(callee)
+			if fnSymbol == nil {
+				return env.SymbolTypeInvalid
+			}
+		default:
+			fnSymbol = c.inferFunctionValue(callee, anonymousFunctionName)
+			if fnSymbol == nil {
+				return env.SymbolTypeInvalid
 			}
-			fmt.Printf("[warn]: Type checking of the spread arguments not implemented! Ignoring type check for function '%s'.\n", fnSymbol.Name)
+		}
+
+		e.Symbol = fnSymbol
+		functionType := c.checkCallArguments(e, fnSymbol, fnSymbol.Type.(*env.ChlangFunctionType))
+		if functionType == nil {
+			return env.SymbolTypeInvalid
 		}
 		fnSymbol.Used = true
 		return functionType.Return
 	case *ast.StringLiteral:
 		return env.SymbolTypeString
+	case *ast.StringTemplate:
+		for _, part := range e.Parts {
+			partType := c.inferExpression(part)
+			if partType == env.SymbolTypeInvalid || !c.checkSingleValue(part, partType) {
+				return env.SymbolTypeInvalid
+			}
+			if partType == env.SymbolTypeVoid {
+				c.reportError("cannot use 'void' as a value in the string template", part.GetSpan())
+				return env.SymbolTypeInvalid
+			}
+		}
+		return env.SymbolTypeString
 	case *ast.IntLiteral:
 		var intType env.ChlangPrimitiveType
 		if e.Suffix != "" {
@@ -759,27 +1460,12 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 			return env.SymbolTypeInvalid
 		}
 
+		e.Type = symbolType
 		return symbolType
 	case *ast.BoolLiteral:
 		return env.SymbolTypeBool
 	case *ast.ArrayExpression:
-		arrayType := &env.ChlangArrayType{
-			ElementType: env.SymbolTypeInvalid,
-			Length:      len(e.Elements),
-		}
-		for _, elem := range e.Elements {
-			elemType := c.inferExpression(elem)
-			if arrayType.ElementType == env.SymbolTypeInvalid {
-				arrayType.ElementType = elemType
-			} else if !env.IsCompatibleType(arrayType.ElementType, elemType) {
-				c.Errors = append(c.Errors, &errors.SemanticError{
-					Message:  fmt.Sprintf("array element type mismatch: expected '%s', but got '%s'", arrayType.ElementType, elemType),
-					Position: elem.GetSpan().Start,
-				})
-			}
-			arrayType.ElementType = c.getMaxTypeOf(arrayType.ElementType, elemType)
-		}
-		return arrayType
+		return c.inferArrayExpression(e, nil)
 	case *ast.IndexExpression:
 		arrayType := c.inferExpression(e.Left)
 		if arrayType == env.SymbolTypeInvalid {
@@ -791,7 +1477,7 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		}
 
 		if arrayType, ok := arrayType.(*env.ChlangArrayType); ok {
-			if !indexType.(*env.ChlangPrimitiveType).IsInteger() {
+			if indexType, ok := indexType.(env.ChlangPrimitiveType); !ok || !indexType.IsInteger() {
 				c.Errors = append(c.Errors, &errors.SemanticError{
 					Message:  fmt.Sprintf("index operator requires integer type, but got '%s'", indexType),
 					Position: e.Span.Start,
@@ -817,6 +1503,10 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		}
 		switch e.Operator.Type {
 		case chToken.MINUS, chToken.PLUS:
+			if param, ok := rightType.(*env.ChlangTypeParam); ok {
+				param.Require(env.ConstraintNumeric, e.Operator.Literal)
+				return param
+			}
 			operandType, ok := rightType.(env.ChlangPrimitiveType)
 			if !ok || !operandType.IsNumeric() {
 				c.Errors = append(c.Errors, &errors.SemanticError{
@@ -897,6 +1587,17 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 		// TODO: Refactor this, because it's not a good way to determine the type of the if expression
 		// It's better to return a composite type and check it in the upper level
 		return c.getMaxTypeOf(thenType, elseType)
+	case *ast.TryExpression:
+		return c.inferTryExpression(e)
+	case *ast.TupleExpression:
+		return c.inferTupleExpression(e)
+	case *ast.FunctionExpression:
+		return c.inferFunctionExpression(e)
+	case *ast.SpreadExpression:
+		c.reportError("the spread array can be passed to the variadic function only", e.Span)
+		return env.SymbolTypeInvalid
+	case *ast.WhenExpression:
+		return c.inferWhenExpression(e)
 	}
 
 	c.Errors = append(c.Errors, &errors.SemanticError{
@@ -907,6 +1608,38 @@ func (c *Checker) inferExpression(expr ast.Expression) env.ChlangType {
 	return env.SymbolTypeInvalid
 }
 
+// checkAssignTarget reports an error if the expression cannot be assigned: it must be a variable, an index or a field
+func (c *Checker) checkAssignTarget(target ast.Expression, span *chToken.Span) bool {
+	switch left := target.(type) {
+	case *ast.Identifier:
+		if symbol, ok := left.Symbol.(*env.EnvSymbolEntity); ok && symbol.EntityType == env.SymbolEntityConstant {
+			c.reportError(fmt.Sprintf("cannot assign to constant '%s'", left.Value), span)
+			return false
+		}
+		if symbol, ok := left.Symbol.(*env.EnvSymbolEntity); ok && symbol.EntityType == env.SymbolEntityVariant {
+			c.reportError(fmt.Sprintf("cannot assign to variant '%s'", left.Value), span)
+			return false
+		}
+	case *ast.IndexExpression:
+	case *ast.MemberExpression:
+		if _, ok := left.LeftType.(*env.ChlangEnumType); ok {
+			c.reportError(fmt.Sprintf("cannot assign to enum variant '%s'", left.Member.Value), span)
+			return false
+		}
+		if pkg, ok := left.LeftType.(*env.ChlangPackageType); ok {
+			c.reportError(fmt.Sprintf("cannot assign to '%s' of package '%s'", left.Member.Value, pkg.Name), span)
+			return false
+		}
+	default:
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  "left side of an assignment must be an identifier, an index or a field",
+			Position: span.Start,
+		})
+		return false
+	}
+	return true
+}
+
 // getGeneralTypeOf returns the minimal general type of the type
 // For example, if the types are i8 the general type will be i32 (because all integer variables by default is the i32)
 // Same behavior for the complex types, the array of i8 will be the array of i32
@@ -924,47 +1657,215 @@ func (c *Checker) getGeneralTypeOf(exprType env.ChlangType) env.ChlangType {
 		}
 	case *env.ChlangArrayType:
 		ty.ElementType = c.getGeneralTypeOf(ty.ElementType)
+	case *env.ChlangOptionType:
+		if ty.Value != nil {
+			exprType = &env.ChlangOptionType{Value: c.getGeneralTypeOf(ty.Value)}
+		}
+	case *env.ChlangResultType:
+		result := &env.ChlangResultType{}
+		if ty.Value != nil {
+			result.Value = c.getGeneralTypeOf(ty.Value)
+		}
+		if ty.Error != nil {
+			result.Error = c.getGeneralTypeOf(ty.Error)
+		}
+		exprType = result
 	}
 	return exprType
 }
 
-// func (c *Checker) inferMemberExpression(expr *ast.MemberExpression) (env.ChlangType, *env.MonomorphicFunction) {
-// 	left := c.inferExpression(expr.Left)
-// 	if left == env.SymbolTypeInvalid {
-// 		return env.SymbolTypeInvalid
-// 	}
-// 	member := expr.Member.Value
-
-// 	switch leftType := left.(type) {
-// 	case *env.ChlangStructType:
-// 		if field := leftType.LookupField(member); field != nil {
-// 			return field.Type, nil
-// 		}
-// 		if method := leftType.LookupMethod(member); method != nil {
-// 			return method.Type.(*env.EnvTypeEntity), method
-// 		}
-// 		c.Errors = append(c.Errors, &errors.SemanticError{
-// 			Message:  fmt.Sprintf("field '%s' not found in struct", member),
-// 			Position: expr.Span.Start,
-// 		})
-// 		return env.SymbolTypeInvalid
-// 		// case *env.ChlangArrayType:
-// 		// 	if member != "len" {
-// 		// 		c.Errors = append(c.Errors, &errors.SemanticError{
-// 		// 			Message:  fmt.Sprintf("unknown array member '%s'", member),
-// 		// 			Position: e.Span.Start,
-// 		// 		})
-// 		// 		return env.SymbolTypeInvalid
-// 		// 	}
-// 		// 	return &env.ChlangFunctionType{
-// 		// 		SpreadType: nil,
-// 		// 		Return:     env.SymbolTypeInt32,
-// 		// 		Args:       []env.ChlangType{leftType},
-// 		// 	}
-// 	}
-// 	fmt.Printf("[warn]: Unsupported member expression type %T, accessing to member '%s'.\n", left, member)
-// 	return env.SymbolTypeInvalid
-// }
+// inferMemberExpression returns the type of the struct field accessed by the member expression
+// or the enum type of the unit variant, e.g. 'Color.Red'
+func (c *Checker) inferMemberExpression(expr *ast.MemberExpression) env.ChlangType {
+	if enumType, variant, ok := c.lookupEnumVariant(expr); ok {
+		if variant == nil {
+			return env.SymbolTypeInvalid
+		}
+		if len(variant.Payload) > 0 {
+			c.reportError(fmt.Sprintf("variant '%s.%s' expects %d payload values", enumType.Name, variant.Name, len(variant.Payload)), expr.Span)
+			return env.SymbolTypeInvalid
+		}
+		return enumType
+	}
+	if pkg := c.lookupPackage(expr.Left); pkg != nil {
+		return c.inferPackageMember(expr, pkg)
+	}
+
+	left := c.inferExpression(expr.Left)
+	if left == env.SymbolTypeInvalid {
+		return env.SymbolTypeInvalid
+	}
+	member := expr.Member.Value
+
+	switch leftType := left.(type) {
+	case *env.ChlangStructType:
+		expr.LeftType = leftType
+		if field := leftType.LookupField(member); field != nil {
+			if !c.checkFieldAccess(leftType, field, expr.Member.Span) {
+				return env.SymbolTypeInvalid
+			}
+			return field.Type
+		}
+		if method := leftType.LookupMethod(member); method != nil {
+			c.reportError(fmt.Sprintf("method '%s' of struct '%s' must be called", member, leftType.Name), expr.Span)
+			return env.SymbolTypeInvalid
+		}
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("field '%s' not found in struct '%s'", member, leftType.Name),
+			Position: expr.Member.Span.Start,
+			Span:     expr.Span,
+		})
+		return env.SymbolTypeInvalid
+	case *env.ChlangTraitType:
+		if leftType.LookupMethod(member) != nil {
+			c.reportError(fmt.Sprintf("method '%s' of trait '%s' must be called", member, leftType.Name), expr.Span)
+		} else {
+			c.reportError(fmt.Sprintf("cannot access field '%s' of trait type '%s'", member, leftType.Name), expr.Span)
+		}
+		return env.SymbolTypeInvalid
+	}
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("cannot access member '%s' of non-struct type '%s'", member, left),
+		Position: expr.Span.Start,
+		Span:     expr.Span,
+	})
+	return env.SymbolTypeInvalid
+}
+
+// lookupEnumVariant resolves the enum variant referenced by 'Enum.Variant' or 'package.Enum.Variant'.
+// It returns ok = false if the left side is not an enum type name (variables shadow type names),
+// the variant is nil if the enum has no such variant, the error is already reported in this case.
+func (c *Checker) lookupEnumVariant(expr *ast.MemberExpression) (*env.ChlangEnumType, *env.ChlangEnumVariant, bool) {
+	var typeEntity *env.EnvTypeEntity
+	var pkg *env.ChlangPackageType
+	switch left := expr.Left.(type) {
+	case *ast.Identifier:
+		if c.Env.LookupSymbol(left.Value) != nil {
+			return nil, nil, false
+		}
+		typeEntity = c.Env.LookupType(left.Value)
+	case *ast.MemberExpression:
+		if pkg = c.lookupPackage(left.Left); pkg != nil {
+			typeEntity = pkg.LookupType(left.Member.Value)
+		}
+	}
+	if typeEntity == nil {
+		return nil, nil, false
+	}
+	enumType, ok := env.Underlying(typeEntity.Spec).(*env.ChlangEnumType)
+	if !ok {
+		return nil, nil, false
+	}
+	if pkg != nil && !typeEntity.Public {
+		c.reportNotExported(pkg, expr.Left.(*ast.MemberExpression).Member)
+		return enumType, nil, true
+	}
+	typeEntity.Used = true
+	expr.LeftType = enumType
+
+	variant := enumType.LookupVariant(expr.Member.Value)
+	if variant == nil {
+		c.reportError(fmt.Sprintf("variant '%s' not found in enum '%s'", expr.Member.Value, enumType.Name), expr.Member.Span)
+	}
+	return enumType, variant, true
+}
+
+// inferEnumConstruction checks the payload values of the variant construction 'Shape.Circle(1.0)'
+func (c *Checker) inferEnumConstruction(call *ast.CallExpression, enumType *env.ChlangEnumType, variant *env.ChlangEnumVariant) env.ChlangType {
+	if len(call.Args) != len(variant.Payload) {
+		c.reportError(
+			fmt.Sprintf("variant '%s.%s' expects %d payload values, but got %d", enumType.Name, variant.Name, len(variant.Payload), len(call.Args)),
+			call.Span,
+		)
+		return env.SymbolTypeInvalid
+	}
+	for idx, arg := range call.Args {
+		argType := c.inferExpression(arg)
+		if argType == env.SymbolTypeInvalid || variant.Payload[idx] == env.SymbolTypeInvalid {
+			return env.SymbolTypeInvalid
+		}
+		if !env.IsLeftCompatibleType(variant.Payload[idx], argType) {
+			c.reportError(
+				fmt.Sprintf("variant '%s.%s' expects payload value %d to be '%s', but got '%s'", enumType.Name, variant.Name, idx+1, variant.Payload[idx], argType),
+				arg.GetSpan(),
+			)
+			return env.SymbolTypeInvalid
+		}
+	}
+	return enumType
+}
+
+// inferMethod returns the method symbol called by the member expression, or nil if the member is not a method
+func (c *Checker) inferMethod(expr *ast.MemberExpression) *env.EnvSymbolEntity {
+	left := c.inferExpression(expr.Left)
+	if left == env.SymbolTypeInvalid {
+		return nil
+	}
+	member := expr.Member.Value
+
+	if traitType, ok := left.(*env.ChlangTraitType); ok {
+		expr.LeftType = traitType
+		method := traitType.LookupMethod(member)
+		if method == nil {
+			c.reportError(fmt.Sprintf("method '%s' not found in trait '%s'", member, traitType.Name), expr.Span)
+			return nil
+		}
+		expr.Symbol = method.Symbol
+		return method.Symbol
+	}
+
+	if param, ok := left.(*env.ChlangTypeParam); ok {
+		expr.LeftType = param
+		method := param.LookupMethod(member)
+		if method == nil {
+			c.reportError(fmt.Sprintf("method '%s' not found in the trait bounds of type parameter '%s'", member, param.Name), expr.Span)
+			return nil
+		}
+		expr.Symbol = method.Symbol
+		return method.Symbol
+	}
+
+	switch left.(type) {
+	case *env.ChlangOptionType, *env.ChlangResultType:
+		return c.inferBuiltinMethod(expr, left)
+	}
+
+	structType, ok := left.(*env.ChlangStructType)
+	if !ok {
+		c.reportError(fmt.Sprintf("cannot call method '%s' of non-struct type '%s'", member, left), expr.Span)
+		return nil
+	}
+	expr.LeftType = structType
+
+	method := structType.LookupMethod(member)
+	if method == nil {
+		if field := structType.LookupField(member); field != nil {
+			if !c.checkFieldAccess(structType, field, expr.Member.Span) {
+				return nil
+			}
+			if _, ok := env.Underlying(field.Type).(*env.ChlangFunctionType); ok {
+				// the field holds a function value: obj.callback(x)
+				method = &env.EnvSymbolEntity{
+					Name:       member,
+					Used:       true,
+					Type:       env.Underlying(field.Type),
+					EntityType: env.SymbolEntityVariable,
+				}
+				expr.Symbol = method
+				return method
+			}
+			c.reportError(fmt.Sprintf("field '%s' of struct '%s' is not a method", member, structType.Name), expr.Span)
+		} else {
+			c.reportError(fmt.Sprintf("method '%s' not found in struct '%s'", member, structType.Name), expr.Span)
+		}
+		return nil
+	}
+	if !c.checkMethodAccess(structType, member, expr.Member.Span) {
+		return nil
+	}
+	expr.Symbol = method
+	return method
+}
 
 func (c *Checker) inferIfBlockStatement(block *ast.BlockStatement) env.ChlangType {
 	c.Env.OpenScope()
@@ -1103,6 +2004,11 @@ func (c *Checker) checkIntLiteralSuffix(node *ast.IntLiteral) env.ChlangPrimitiv
 }
 
 func (c *Checker) checkTypesCompatibility(a, b env.ChlangType, operator *chToken.Token) (env.ChlangType, error) {
+	_, leftIsParam := a.(*env.ChlangTypeParam)
+	_, rightIsParam := b.(*env.ChlangTypeParam)
+	if leftIsParam || rightIsParam {
+		return c.checkTypeParamOperation(a, b, operator)
+	}
 	switch operator.Type {
 	case chToken.PLUS,
 		chToken.MINUS,
@@ -1119,6 +2025,12 @@ func (c *Checker) checkTypesCompatibility(a, b env.ChlangType, operator *chToken
 		rightType, rightIsPrimitive := b.(env.ChlangPrimitiveType)
 
 		if leftIsPrimitive && rightIsPrimitive && leftType.IsNumeric() && rightType.IsNumeric() {
+			if isBitwiseOperator(operator.Type) && (!leftType.IsInteger() || !rightType.IsInteger()) {
+				return env.SymbolTypeInvalid, &errors.SemanticError{
+					Message:  fmt.Sprintf("type mismatch: operator '%s' requires integer operands (left: %s, right: %s)", operator.Literal, a, b),
+					Position: operator.Position,
+				}
+			}
 			if a == b {
 				return a, nil
 			}
@@ -1140,8 +2052,16 @@ func (c *Checker) checkTypesCompatibility(a, b env.ChlangType, operator *chToken
 			Position: operator.Position,
 			HelpMsg:  fmt.Sprintf("got '%s' and '%s'", a, b),
 		}
+	case chToken.AND, chToken.OR:
+		if a == env.SymbolTypeBool && b == env.SymbolTypeBool {
+			return env.SymbolTypeBool, nil
+		}
+		return env.SymbolTypeInvalid, &errors.SemanticError{
+			Message:  fmt.Sprintf("type mismatch: operator '%s' requires 'bool' operands (left: %s, right: %s)", operator.Literal, a, b),
+			Position: operator.Position,
+		}
 	case chToken.EQUALS, chToken.NOT_EQUALS, chToken.LESS,
-		chToken.LESS_EQUALS, chToken.GREATER, chToken.GREATER_EQUALS, chToken.AND, chToken.OR:
+		chToken.LESS_EQUALS, chToken.GREATER, chToken.GREATER_EQUALS:
 		if env.IsCompatibleType(a, b) {
 			return env.SymbolTypeBool, nil
 		}
@@ -1150,6 +2070,42 @@ func (c *Checker) checkTypesCompatibility(a, b env.ChlangType, operator *chToken
 	return env.SymbolTypeInvalid, fmt.Errorf("type mismatch: unknown operator: %s", operator.Literal)
 }
 
+// binary operators applied by the compound assignments: 'a += b' assigns 'a + b'
+var compoundAssignOperators = map[chToken.TokenType]chToken.TokenType{
+	chToken.PLUS_ASSIGN:        chToken.PLUS,
+	chToken.MINUS_ASSIGN:       chToken.MINUS,
+	chToken.ASTERISK_ASSIGN:    chToken.ASTERISK,
+	chToken.SLASH_ASSIGN:       chToken.SLASH,
+	chToken.EXPONENT_ASSIGN:    chToken.EXPONENT,
+	chToken.PERCENT_ASSIGN:     chToken.PERCENT,
+	chToken.AMPERSAND_ASSIGN:   chToken.AMPERSAND,
+	chToken.PIPE_ASSIGN:        chToken.PIPE,
+	chToken.CARET_ASSIGN:       chToken.CARET,
+	chToken.LEFT_SHIFT_ASSIGN:  chToken.LEFT_SHIFT,
+	chToken.RIGHT_SHIFT_ASSIGN: chToken.RIGHT_SHIFT,
+}
+
+// checkCompoundAssignment verifies that the value of the assign target supports the operator of the compound assignment
+func (c *Checker) checkCompoundAssignment(leftType, rightType env.ChlangType, assign *chToken.Token) bool {
+	operator := &chToken.Token{Type: compoundAssignOperators[assign.Type], Literal: assign.Literal, Position: assign.Position}
+	if _, err := c.checkTypesCompatibility(leftType, rightType, operator); err != nil {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  err.Error(),
+			Position: assign.Position,
+		})
+		return false
+	}
+	return true
+}
+
+func isBitwiseOperator(operator chToken.TokenType) bool {
+	switch operator {
+	case chToken.AMPERSAND, chToken.PIPE, chToken.CARET, chToken.LEFT_SHIFT, chToken.RIGHT_SHIFT:
+		return true
+	}
+	return false
+}
+
 func (c *Checker) getMaxTypeOf(left, right env.ChlangType) env.ChlangType {
 	leftType, leftIsPrimitive := left.(env.ChlangPrimitiveType)
 	rightType, rightIsPrimitive := right.(env.ChlangPrimitiveType)
@@ -1158,10 +2114,33 @@ func (c *Checker) getMaxTypeOf(left, right env.ChlangType) env.ChlangType {
 		return env.GetMaxType(leftType, rightType)
 	}
 
-	fmt.Printf("[warn]: getMaxTypeOf: unsupported types: %s, %s\n", left, right)
+	// unknown type arguments are taken from the other side: 'if ok { Some(1) } else { None }' is Option<i8>
+	switch leftType := left.(type) {
+	case *env.ChlangOptionType:
+		if rightOption, ok := right.(*env.ChlangOptionType); ok {
+			return &env.ChlangOptionType{Value: c.getMaxTypeArgOf(leftType.Value, rightOption.Value)}
+		}
+	case *env.ChlangResultType:
+		if rightResult, ok := right.(*env.ChlangResultType); ok {
+			return &env.ChlangResultType{
+				Value: c.getMaxTypeArgOf(leftType.Value, rightResult.Value),
+				Error: c.getMaxTypeArgOf(leftType.Error, rightResult.Error),
+			}
+		}
+	}
+
+	c.Warnings = append(c.Warnings, fmt.Errorf("getMaxTypeOf: unsupported types: %s, %s", left, right))
 	return left
 }
 
+func (c *Checker) reportWarning(message string, span *chToken.Span) {
+	c.Warnings = append(c.Warnings, &errors.SemanticWarning{
+		Message:  message,
+		Span:     span,
+		Position: span.Start,
+	})
+}
+
 func (c *Checker) reportError(message string, span *chToken.Span) {
 	c.Errors = append(c.Errors, &errors.SemanticError{
 		Message:  message,
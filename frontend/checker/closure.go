@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+)
+
+// Name of the anonymous function symbol, it is shown in error messages
+const anonymousFunctionName = "<anonymous>"
+
+// functionScope is the body scope of the function being checked.
+// Variables declared outside the scope are captured by the function, that is allowed for anonymous functions only:
+// named functions are created once (like constants) and have no captured variables.
+type functionScope struct {
+	symbol    *env.EnvSymbolEntity
+	scope     *env.EnvScope
+	anonymous bool
+	parent    *functionScope
+}
+
+// inferFunctionExpression checks the body of the anonymous function and returns its function type
+func (c *Checker) inferFunctionExpression(e *ast.FunctionExpression) env.ChlangType {
+	funcSymbol := c.resolveFuncSymbol(e.Signature, e.Span)
+	if funcSymbol == nil {
+		return env.SymbolTypeInvalid
+	}
+	funcSymbol.Used = true
+	c.checkFunctionBody(e.Body, funcSymbol, true)
+	e.Symbol = funcSymbol
+	return funcSymbol.Type
+}
+
+// checkCapture reports an error if the variable is declared outside the named function being checked.
+// The variable may be captured through the chain of the nested anonymous functions only.
+func (c *Checker) checkCapture(ident *ast.Identifier) bool {
+	_, declScope := c.Env.LookupSymbolScope(ident.Value)
+	for function := c.functionScope; function != nil && !declScope.IsNestedIn(function.scope); function = function.parent {
+		if !function.anonymous {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("function '%s' cannot capture variable '%s' of the enclosing scope", function.symbol.Name, ident.Value),
+				HelpMsg:  fmt.Sprintf("pass '%s' as an argument or use an anonymous function: let %s = fn(...) { ... }", ident.Value, function.symbol.Name),
+				Span:     ident.Span,
+				Position: ident.Span.Start,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// inferFunctionValue checks the callee which is a value of the function type: a variable, a parameter or an expression,
+// e.g. 'callback(x)' or 'make_adder(1)(2)'. It returns the symbol describing the callee for the call checks.
+func (c *Checker) inferFunctionValue(callee ast.Expression, name string) *env.EnvSymbolEntity {
+	calleeType := c.inferExpression(callee)
+	if calleeType == env.SymbolTypeInvalid {
+		return nil
+	}
+	if _, ok := calleeType.(*env.ChlangFunctionType); !ok {
+		c.reportError(fmt.Sprintf("value of type '%s' is not callable", calleeType), callee.GetSpan())
+		return nil
+	}
+	return &env.EnvSymbolEntity{
+		Name:       name,
+		Used:       true,
+		Type:       calleeType,
+		EntityType: env.SymbolEntityVariable,
+	}
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+)
+
+// inferConversion checks the explicit conversion of the number to the numeric type: f64(x), u8(y).
+// Any numeric type converts to another one, the lossy conversions truncate the value or lose the precision.
+// The bool and string values have no numeric conversions.
+func (c *Checker) inferConversion(call *ast.CallExpression, target env.ChlangPrimitiveType) env.ChlangType {
+	if !target.IsNumeric() {
+		c.reportError(fmt.Sprintf("cannot convert to '%s', only the numeric types have conversions", target), call.Function.GetSpan())
+		return env.SymbolTypeInvalid
+	}
+	if len(call.Args) != 1 {
+		c.reportError(fmt.Sprintf("conversion to '%s' expects 1 argument, but got %d", target, len(call.Args)), call.Span)
+		return env.SymbolTypeInvalid
+	}
+
+	valueType := c.inferExpression(call.Args[0])
+	if valueType == env.SymbolTypeInvalid {
+		return env.SymbolTypeInvalid
+	}
+	if source, ok := valueType.(env.ChlangPrimitiveType); !ok || !source.IsNumeric() {
+		span := call.Args[0].GetSpan()
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("cannot convert value of type '%s' to '%s'", valueType, target),
+			HelpMsg:  "only the numbers can be converted to the numeric types",
+			Span:     span,
+			Position: span.Start,
+		})
+		return env.SymbolTypeInvalid
+	}
+	call.Conversion = target
+	return target
+}
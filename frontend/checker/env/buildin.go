@@ -18,6 +18,7 @@ const (
 	SymbolTypeBool                        // true, false
 	SymbolTypeString                      // string literal
 	SymbolTypeVoid                        // void
+	SymbolTypeAny                         // value of any type, accepted by the built-in functions only
 )
 
 var langSymbolTypeTag = map[ChlangPrimitiveType]string{
@@ -34,6 +35,7 @@ var langSymbolTypeTag = map[ChlangPrimitiveType]string{
 	SymbolTypeBool:    "bool",
 	SymbolTypeString:  "string",
 	SymbolTypeVoid:    "void",
+	SymbolTypeAny:     "any",
 
 	SymbolTypeInvalid: "<invalid>",
 }
@@ -103,8 +105,10 @@ func (t ChlangPrimitiveType) GetNumberBitSize() int {
 		return 16
 	case SymbolTypeInt32, SymbolTypeUint32:
 		return 32
-	case SymbolTypeInt64, SymbolTypeUint64:
+	case SymbolTypeInt64, SymbolTypeUint64, SymbolTypeFloat64:
 		return 64
+	case SymbolTypeFloat32:
+		return 32
 	}
 	return 0
 }
@@ -24,12 +24,18 @@
 // - InsertType: Inserts a new type into the current scope.
 // - LookupSymbolLocal: Looks up a symbol in the current scope only.
 // - LookupSymbol: Looks up a symbol in the current scope and all parent scopes.
+// - LookupSymbolScope: Looks up a symbol like LookupSymbol and returns the scope declaring it.
 // - LookupType: Looks up a type in the current scope and all parent scopes.
+// - Checkpoint: Takes a snapshot of the symbols and types of the current scope.
+// - Restore: Removes the declarations made in the scope and the methods implemented after the checkpoint.
 // - Print: Prints the current symbol table to the console.
+// - Write: Writes the current symbol table to the given writer.
 package env
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/usein-abilev/chlang/frontend/token"
 )
@@ -42,6 +48,8 @@ const (
 	SymbolEntityVariable
 	SymbolEntityFunction
 	SymbolEntityConstant
+	SymbolEntityVariant // built-in variant constructor: Some, None, Ok, Err
+	SymbolEntityPackage // imported package, its members are accessed by the package name: geometry.area(s)
 )
 
 func (t symbolEntityType) String() string {
@@ -52,6 +60,10 @@ func (t symbolEntityType) String() string {
 		return "Function"
 	case SymbolEntityConstant:
 		return "Constant"
+	case SymbolEntityVariant:
+		return "Variant"
+	case SymbolEntityPackage:
+		return "Package"
 	}
 	return "Unknown"
 }
@@ -77,6 +89,10 @@ type EnvSymbolEntity struct {
 	// Arguments of the function, if it's a function
 	FunctionArgs []*EnvSymbolEntity
 
+	// Whether the symbol is exported from the package (declared with 'pub'),
+	// the public methods are accessible outside of the package of the struct
+	Public bool
+
 	// The position of the symbol in the source code
 	Span *token.Span
 }
@@ -95,6 +111,9 @@ type EnvTypeEntity struct {
 	// Actual type specification
 	Spec ChlangType
 
+	// Whether the type is exported from the package (declared with 'pub')
+	Public bool
+
 	// The code region of the type in the source code
 	Span *token.Span
 }
@@ -208,6 +227,35 @@ func (st *Env) LookupSymbol(name string) *EnvSymbolEntity {
 	return st.Local.lookupSymbol(name)
 }
 
+// LookupSymbolScope searches for a symbol like LookupSymbol and returns the scope declaring it
+func (st *Env) LookupSymbolScope(name string) (*EnvSymbolEntity, *EnvScope) {
+	for scope := st.Local; scope != nil; scope = scope.parent {
+		if symbol, ok := scope.symbols[name]; ok {
+			return symbol, scope
+		}
+	}
+	return nil, nil
+}
+
+// IsNestedIn reports whether the scope is the given scope or one of its nested scopes
+func (st *EnvScope) IsNestedIn(scope *EnvScope) bool {
+	for current := st; current != nil; current = current.parent {
+		if current == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupTypeLocal searches for a type in the current scope only
+func (st *Env) LookupTypeLocal(name string) *EnvTypeEntity {
+	t, ok := st.Local.types[name]
+	if !ok {
+		return nil
+	}
+	return t
+}
+
 func (st *Env) LookupType(name string) *EnvTypeEntity {
 	return st.Local.lookupType(name)
 }
@@ -234,7 +282,88 @@ func (st *EnvScope) lookupType(name string) *EnvTypeEntity {
 	return t
 }
 
+// ScopeCheckpoint is a snapshot of the symbols and types declared in the scope.
+// Restoring a checkpoint removes the declarations made after it was taken.
+type ScopeCheckpoint struct {
+	scope   *EnvScope
+	symbols map[string]*EnvSymbolEntity
+	types   map[string]*EnvTypeEntity
+	structs []structCheckpoint
+}
+
+// structCheckpoint is a snapshot of the methods and traits of the struct visible from the scope,
+// impl blocks add them to the struct declared before the checkpoint
+type structCheckpoint struct {
+	spec    *ChlangStructType
+	methods map[string]*EnvSymbolEntity
+	traits  []*ChlangTraitType
+}
+
+// Checkpoint takes a snapshot of the current scope
+func (st *Env) Checkpoint() ScopeCheckpoint {
+	checkpoint := ScopeCheckpoint{
+		scope:   st.Local,
+		symbols: make(map[string]*EnvSymbolEntity, len(st.Local.symbols)),
+		types:   make(map[string]*EnvTypeEntity, len(st.Local.types)),
+	}
+	for name, symbol := range st.Local.symbols {
+		checkpoint.symbols[name] = symbol
+	}
+	for name, t := range st.Local.types {
+		checkpoint.types[name] = t
+	}
+	seen := make(map[*ChlangStructType]bool)
+	for scope := st.Local; scope != nil; scope = scope.parent {
+		for _, t := range scope.types {
+			spec, ok := Underlying(t.Spec).(*ChlangStructType)
+			if !ok || seen[spec] {
+				continue
+			}
+			seen[spec] = true
+			checkpoint.structs = append(checkpoint.structs, structCheckpoint{
+				spec:    spec,
+				methods: copyMethods(spec.Methods),
+				traits:  append([]*ChlangTraitType(nil), spec.Traits...),
+			})
+		}
+	}
+	return checkpoint
+}
+
+// Restore makes the scope of the checkpoint current again and removes the symbols and types declared after it,
+// the methods and traits implemented after it are removed from the structs
+func (st *Env) Restore(checkpoint ScopeCheckpoint) {
+	st.Local = checkpoint.scope
+	st.Local.symbols = make(map[string]*EnvSymbolEntity, len(checkpoint.symbols))
+	for name, symbol := range checkpoint.symbols {
+		st.Local.symbols[name] = symbol
+	}
+	st.Local.types = make(map[string]*EnvTypeEntity, len(checkpoint.types))
+	for name, t := range checkpoint.types {
+		st.Local.types[name] = t
+	}
+	for _, snapshot := range checkpoint.structs {
+		snapshot.spec.Methods = copyMethods(snapshot.methods)
+		snapshot.spec.Traits = append([]*ChlangTraitType(nil), snapshot.traits...)
+	}
+}
+
+func copyMethods(methods map[string]*EnvSymbolEntity) map[string]*EnvSymbolEntity {
+	if methods == nil {
+		return nil
+	}
+	copied := make(map[string]*EnvSymbolEntity, len(methods))
+	for name, method := range methods {
+		copied[name] = method
+	}
+	return copied
+}
+
 func (st *Env) Print() {
+	st.Write(os.Stdout)
+}
+
+func (st *Env) Write(w io.Writer) {
 	header := []string{"Name", "Type", "Entity Type"}
 	columnsSize := []int{0, 0, 0}
 	rows := make([][]string, 0)
@@ -263,16 +392,16 @@ func (st *Env) Print() {
 		rows = append(rows, []string{name, typeString, "Type"})
 	}
 
-	fmt.Print("\n---------------Symbol Table------------\n")
+	fmt.Fprint(w, "\n---------------Symbol Table------------\n")
 	for i, h := range header {
-		fmt.Printf("%-*s", columnsSize[i]+2, h)
+		fmt.Fprintf(w, "%-*s", columnsSize[i]+2, h)
 	}
-	fmt.Print("\n")
+	fmt.Fprint(w, "\n")
 	for _, row := range rows {
 		for i, col := range row {
-			fmt.Printf("%-*s", columnsSize[i]+2, col)
+			fmt.Fprintf(w, "%-*s", columnsSize[i]+2, col)
 		}
-		fmt.Print("\n")
+		fmt.Fprint(w, "\n")
 	}
-	fmt.Print("---------------------------------------\n\n")
+	fmt.Fprint(w, "---------------------------------------\n\n")
 }
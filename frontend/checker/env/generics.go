@@ -0,0 +1,196 @@
+package env
+
+import "strings"
+
+// Operators applied to the values of the type parameter in the body of the generic function,
+// every type argument of the parameter must support them
+type TypeParamConstraint int
+
+const (
+	ConstraintEquality TypeParamConstraint = 1 << iota // == and !=
+	ConstraintOrdered                                  // <, <=, > and >=
+	ConstraintNumeric                                  // arithmetic operators and the unary minus
+	ConstraintInteger                                  // bitwise and shift operators
+)
+
+var typeParamConstraints = []TypeParamConstraint{ConstraintEquality, ConstraintOrdered, ConstraintNumeric, ConstraintInteger}
+
+// Type parameter of the generic function or struct, e.g. T in 'fn max<T>(a: T, b: T) -> T'
+type ChlangTypeParam struct {
+	Name        string
+	Bounds      []*ChlangTraitType // traits implemented by every type argument
+	Constraints TypeParamConstraint
+	Operators   map[TypeParamConstraint]string // the first operator requiring the constraint, it is shown in errors
+}
+
+func (ChlangTypeParam) Type() {}
+func (c ChlangTypeParam) String() string {
+	return c.Name
+}
+
+// HasBound reports whether the type arguments of the parameter must implement the trait
+func (p *ChlangTypeParam) HasBound(trait *ChlangTraitType) bool {
+	for _, bound := range p.Bounds {
+		if bound == trait {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupMethod returns the method of the bound trait, the methods are the only members of the type parameter values
+func (p *ChlangTypeParam) LookupMethod(name string) *ChlangTraitMethod {
+	for _, bound := range p.Bounds {
+		if method := bound.LookupMethod(name); method != nil {
+			return method
+		}
+	}
+	return nil
+}
+
+// Require records the operator applied to the values of the parameter
+func (p *ChlangTypeParam) Require(constraint TypeParamConstraint, operator string) bool {
+	if p.Constraints&constraint != 0 {
+		return false
+	}
+	p.Constraints |= constraint
+	if p.Operators == nil {
+		p.Operators = make(map[TypeParamConstraint]string)
+	}
+	p.Operators[constraint] = operator
+	return true
+}
+
+// UnsupportedOperator returns the operator applied to the parameter values that the type argument doesn't support
+func (p *ChlangTypeParam) UnsupportedOperator(arg ChlangType) (string, bool) {
+	for _, constraint := range typeParamConstraints {
+		if p.Constraints&constraint != 0 && !satisfiesConstraint(arg, constraint) {
+			return p.Operators[constraint], true
+		}
+	}
+	return "", false
+}
+
+// Operators supported by the values of the type at runtime
+func satisfiesConstraint(t ChlangType, constraint TypeParamConstraint) bool {
+	t = Underlying(t)
+	primitive, isPrimitive := t.(ChlangPrimitiveType)
+	switch constraint {
+	case ConstraintEquality:
+		switch t.(type) {
+		case ChlangPrimitiveType, *ChlangEnumType, *ChlangOptionType, *ChlangResultType:
+			return true
+		}
+	case ConstraintOrdered, ConstraintNumeric:
+		return isPrimitive && primitive.IsNumeric()
+	case ConstraintInteger:
+		return isPrimitive && primitive.IsInteger()
+	}
+	return false
+}
+
+// Substitute replaces the type parameters by the type arguments bound to them
+func Substitute(t ChlangType, bindings map[*ChlangTypeParam]ChlangType) ChlangType {
+	switch ty := t.(type) {
+	case *ChlangTypeParam:
+		if bound, ok := bindings[ty]; ok && bound != nil {
+			return bound
+		}
+	case *ChlangArrayType:
+		return &ChlangArrayType{ElementType: Substitute(ty.ElementType, bindings), Length: ty.Length}
+	case *ChlangTupleType:
+		tuple := &ChlangTupleType{Elements: make([]ChlangType, 0, len(ty.Elements))}
+		for _, element := range ty.Elements {
+			tuple.Elements = append(tuple.Elements, Substitute(element, bindings))
+		}
+		return tuple
+	case *ChlangOptionType:
+		if ty.Value != nil {
+			return &ChlangOptionType{Value: Substitute(ty.Value, bindings)}
+		}
+	case *ChlangResultType:
+		result := &ChlangResultType{}
+		if ty.Value != nil {
+			result.Value = Substitute(ty.Value, bindings)
+		}
+		if ty.Error != nil {
+			result.Error = Substitute(ty.Error, bindings)
+		}
+		return result
+	case *ChlangFunctionType:
+		function := &ChlangFunctionType{Return: Substitute(ty.Return, bindings)}
+		for _, arg := range ty.Args {
+			function.Args = append(function.Args, Substitute(arg, bindings))
+		}
+		if ty.SpreadType != nil {
+			function.SpreadType = Substitute(ty.SpreadType, bindings)
+		}
+		return function
+	case *ChlangStructType:
+		if ty.Generic != nil {
+			args := make([]ChlangType, 0, len(ty.TypeArgs))
+			for _, arg := range ty.TypeArgs {
+				args = append(args, Substitute(arg, bindings))
+			}
+			return ty.Generic.Instantiate(args)
+		}
+	}
+	return t
+}
+
+// Instantiate returns the struct with the type arguments substituted in the fields of the generic struct.
+// Instances are cached, so the struct types with the same type arguments are identical.
+func (s *ChlangStructType) Instantiate(args []ChlangType) *ChlangStructType {
+	for _, instance := range s.instances {
+		if isSameTypeList(instance.TypeArgs, args) {
+			return instance
+		}
+	}
+
+	bindings := make(map[*ChlangTypeParam]ChlangType, len(args))
+	names := make([]string, 0, len(args))
+	for idx, param := range s.TypeParams {
+		bindings[param] = args[idx]
+		names = append(names, TypeArgName(args[idx]))
+	}
+	instance := &ChlangStructType{
+		Name:     s.Name + "<" + strings.Join(names, ", ") + ">",
+		Fields:   make([]*ChlangStructField, 0, len(s.Fields)),
+		Generic:  s,
+		TypeArgs: args,
+	}
+	s.instances = append(s.instances, instance)
+	for _, field := range s.Fields {
+		instance.Fields = append(instance.Fields, &ChlangStructField{
+			Name:   field.Name,
+			Type:   Substitute(field.Type, bindings),
+			Public: field.Public,
+		})
+	}
+	return instance
+}
+
+// TypeArgName returns the name of the type argument as it is written in the type specification, e.g. 'Point' instead of 'struct Point'
+func TypeArgName(t ChlangType) string {
+	switch ty := t.(type) {
+	case *ChlangStructType:
+		return ty.Name
+	case *ChlangEnumType:
+		return ty.Name
+	case *ChlangTraitType:
+		return ty.Name
+	}
+	return t.String()
+}
+
+func isSameTypeList(a, b []ChlangType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if !IsLeftCompatibleType(a[idx], b[idx]) || !IsLeftCompatibleType(b[idx], a[idx]) {
+			return false
+		}
+	}
+	return true
+}
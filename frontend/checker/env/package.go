@@ -0,0 +1,24 @@
+package env
+
+// Package imported by the program, e.g. 'geometry' imported by 'import "shapes/geometry"'.
+// The members of the package are the declarations of its top-level scope, only the exported ones are accessible.
+type ChlangPackageType struct {
+	Name string
+	Path string // import path relative to the project root
+	Env  *Env   // environment of the checked package, its current scope is the top-level one
+}
+
+func (ChlangPackageType) Type() {}
+func (c ChlangPackageType) String() string {
+	return "package " + c.Name
+}
+
+// LookupSymbol returns the function or constant declared by the package
+func (c *ChlangPackageType) LookupSymbol(name string) *EnvSymbolEntity {
+	return c.Env.LookupSymbolLocal(name)
+}
+
+// LookupType returns the type declared by the package
+func (c *ChlangPackageType) LookupType(name string) *EnvTypeEntity {
+	return c.Env.LookupTypeLocal(name)
+}
@@ -8,10 +8,49 @@ type ChlangType interface {
 	String() string
 }
 
+// User type declared by the 'type' statement, e.g. type Meters = f64
+// Aliases are transparent: an alias is compatible with its underlying type and with other aliases of it.
+// The name is kept to print the type in error messages as it was written by the user.
+type ChlangUserType struct {
+	Name string
+	Spec ChlangType
+}
+
+func (ChlangUserType) Type() {}
+func (c ChlangUserType) String() string {
+	return c.Name
+}
+
+// Underlying returns the type behind the aliases, or the type itself if it is not an alias
+func Underlying(t ChlangType) ChlangType {
+	for {
+		alias, ok := t.(*ChlangUserType)
+		if !ok {
+			return t
+		}
+		t = alias.Spec
+	}
+}
+
+// Trait method: signature symbol (the first argument is 'self') and whether the trait provides a default body
+type ChlangTraitMethod struct {
+	Name       string
+	Symbol     *EnvSymbolEntity
+	HasDefault bool
+}
+
 type ChlangTraitType struct {
-	Name         string
-	Signatures   []*ChlangFunctionType
-	Declarations []*EnvSymbolEntity
+	Name    string
+	Methods []*ChlangTraitMethod
+}
+
+func (t *ChlangTraitType) LookupMethod(name string) *ChlangTraitMethod {
+	for _, method := range t.Methods {
+		if method.Name == name {
+			return method
+		}
+	}
+	return nil
 }
 
 func (ChlangTraitType) Type() {}
@@ -20,15 +59,22 @@ func (c ChlangTraitType) String() string {
 }
 
 type ChlangStructField struct {
-	Name string
-	Type ChlangType
+	Name   string
+	Type   ChlangType
+	Public bool // the field is accessible outside of the package of the struct (declared with 'pub')
 }
 
 // Struct type, e.g. struct { a: i32, b: i32 }
 type ChlangStructType struct {
 	Name    string
 	Fields  []*ChlangStructField
-	Methods map[string]*EnvSymbolEntity
+	Methods map[string]*EnvSymbolEntity // methods declared in impl blocks
+	Traits  []*ChlangTraitType          // traits implemented by the struct
+
+	TypeParams []*ChlangTypeParam // type parameters of the generic struct, e.g. struct Pair<A, B>
+	Generic    *ChlangStructType  // generic struct of the instance, e.g. Pair of Pair<i32, string>
+	TypeArgs   []ChlangType       // type arguments of the instance
+	instances  []*ChlangStructType
 }
 
 func (s *ChlangStructType) LookupField(name string) *ChlangStructField {
@@ -40,8 +86,56 @@ func (s *ChlangStructType) LookupField(name string) *ChlangStructField {
 	return nil
 }
 
+// FieldIndex returns the position of the field in the struct declaration, or -1 if there is no such field
+func (s *ChlangStructType) FieldIndex(name string) int {
+	for idx, field := range s.Fields {
+		if field.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// LookupMethod returns the method declared in impl blocks or the default method inherited from a trait
 func (s *ChlangStructType) LookupMethod(name string) *EnvSymbolEntity {
-	return s.Methods[name]
+	if method, ok := s.Methods[name]; ok {
+		return method
+	}
+	for _, trait := range s.Traits {
+		if method := trait.LookupMethod(name); method != nil && method.HasDefault {
+			return method.Symbol
+		}
+	}
+	return nil
+}
+
+// IsInherited reports whether the method is inherited from a trait default instead of declared in impl blocks
+func (s *ChlangStructType) IsInherited(name string) bool {
+	_, ok := s.Methods[name]
+	return !ok && s.LookupMethod(name) != nil
+}
+
+// IsPublicMethod reports whether the method is accessible outside of the package of the struct:
+// it is declared with 'pub' or it implements a method of the trait
+func (s *ChlangStructType) IsPublicMethod(name string) bool {
+	if method, ok := s.Methods[name]; ok && method.Public {
+		return true
+	}
+	for _, trait := range s.Traits {
+		if trait.LookupMethod(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ChlangStructType) Implements(trait *ChlangTraitType) bool {
+	for _, t := range s.Traits {
+		if t == trait {
+			return true
+		}
+	}
+	return false
 }
 
 func (ChlangStructType) Type() {}
@@ -49,6 +143,130 @@ func (c ChlangStructType) String() string {
 	return "struct " + c.Name
 }
 
+// Enum variant, the payload is empty for unit variants
+type ChlangEnumVariant struct {
+	Name    string
+	Payload []ChlangType
+}
+
+// Enum type (tagged union), e.g. enum Shape { Point, Circle(f64) }
+// The tag of a variant is its position in the declaration.
+type ChlangEnumType struct {
+	Name     string
+	Variants []*ChlangEnumVariant
+}
+
+func (e *ChlangEnumType) LookupVariant(name string) *ChlangEnumVariant {
+	for _, variant := range e.Variants {
+		if variant.Name == name {
+			return variant
+		}
+	}
+	return nil
+}
+
+// VariantIndex returns the tag of the variant, or -1 if there is no such variant
+func (e *ChlangEnumType) VariantIndex(name string) int {
+	for idx, variant := range e.Variants {
+		if variant.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (ChlangEnumType) Type() {}
+func (c ChlangEnumType) String() string {
+	return "enum " + c.Name
+}
+
+// Built-in Option<T> type: Some(T) or None.
+// The value type is nil while it is unknown, e.g. 'None' is Option<_> until it meets a typed place.
+type ChlangOptionType struct {
+	Value ChlangType
+}
+
+// VariantPayload returns the payload types of the variant, ok is false if the type has no such variant
+func (o *ChlangOptionType) VariantPayload(name string) (payload []ChlangType, ok bool) {
+	switch name {
+	case "Some":
+		return []ChlangType{o.Value}, true
+	case "None":
+		return nil, true
+	}
+	return nil, false
+}
+
+func (ChlangOptionType) Type() {}
+func (c ChlangOptionType) String() string {
+	return "Option<" + typeArgString(c.Value) + ">"
+}
+
+// Built-in Result<T, E> type: Ok(T) or Err(E), unknown types are nil like in the Option type
+type ChlangResultType struct {
+	Value ChlangType
+	Error ChlangType
+}
+
+// VariantPayload returns the payload types of the variant, ok is false if the type has no such variant
+func (r *ChlangResultType) VariantPayload(name string) (payload []ChlangType, ok bool) {
+	switch name {
+	case "Ok":
+		return []ChlangType{r.Value}, true
+	case "Err":
+		return []ChlangType{r.Error}, true
+	}
+	return nil, false
+}
+
+func (ChlangResultType) Type() {}
+func (c ChlangResultType) String() string {
+	return "Result<" + typeArgString(c.Value) + ", " + typeArgString(c.Error) + ">"
+}
+
+func typeArgString(t ChlangType) string {
+	if t == nil {
+		return "_"
+	}
+	return t.String()
+}
+
+// Enums backing the values of the built-in Option and Result types at runtime.
+// Payload types are given by ChlangOptionType and ChlangResultType, so they are nil here.
+var (
+	OptionEnum = &ChlangEnumType{Name: "Option", Variants: []*ChlangEnumVariant{
+		{Name: "Some", Payload: []ChlangType{nil}},
+		{Name: "None"},
+	}}
+	ResultEnum = &ChlangEnumType{Name: "Result", Variants: []*ChlangEnumVariant{
+		{Name: "Ok", Payload: []ChlangType{nil}},
+		{Name: "Err", Payload: []ChlangType{nil}},
+	}}
+)
+
+// BuiltinVariantEnum returns the built-in enum declaring the variant: Some, None, Ok or Err, otherwise nil
+func BuiltinVariantEnum(name string) *ChlangEnumType {
+	for _, enum := range []*ChlangEnumType{OptionEnum, ResultEnum} {
+		if enum.LookupVariant(name) != nil {
+			return enum
+		}
+	}
+	return nil
+}
+
+// HasUnknownType reports whether the type arguments of Option or Result are not inferred yet
+func HasUnknownType(t ChlangType) bool {
+	switch ty := Underlying(t).(type) {
+	case *ChlangOptionType:
+		return ty.Value == nil || HasUnknownType(ty.Value)
+	case *ChlangResultType:
+		return ty.Value == nil || ty.Error == nil || HasUnknownType(ty.Value) || HasUnknownType(ty.Error)
+	case *ChlangArrayType:
+		return HasUnknownType(ty.ElementType)
+	}
+	return false
+}
+
 // Array type, e.g. i32[10], i32[]
 type ChlangArrayType struct {
 	ElementType ChlangType
@@ -64,16 +282,45 @@ func (c ChlangArrayType) String() string {
 	return element + "[]"
 }
 
+// Several results of the function, e.g. fn f() -> i32, f64
+type ChlangTupleType struct {
+	Elements []ChlangType
+}
+
+func (ChlangTupleType) Type() {}
+func (c ChlangTupleType) String() string {
+	elements := ""
+	for i, element := range c.Elements {
+		if i > 0 {
+			elements += ", "
+		}
+		elements += element.String()
+	}
+	return "(" + elements + ")"
+}
+
 // Represents a function type in the language
 // Example: (i32, i32) -> i32
 // Example 1: (MyOwnType, i32) -> (i32, MyOwnType)
+// Example 2: (string, ...i32) -> void, the variadic arguments are passed as an array of the spread type
 type ChlangFunctionType struct {
-	SpreadType ChlangType // nil if not spread
+	SpreadType ChlangType // element type of the variadic arguments, nil if not spread
 	Return     ChlangType
 	Args       []ChlangType
+	TypeParams []*ChlangTypeParam // type parameters of the generic function, e.g. fn max<T>(a: T, b: T) -> T
 }
 
 func (c ChlangFunctionType) String() string {
+	params := ""
+	for i, param := range c.TypeParams {
+		if i > 0 {
+			params += ", "
+		}
+		params += param.Name
+	}
+	if params != "" {
+		params = "<" + params + ">"
+	}
 	args := ""
 	for i, arg := range c.Args {
 		if i > 0 {
@@ -81,7 +328,19 @@ func (c ChlangFunctionType) String() string {
 		}
 		args += arg.String()
 	}
-	return "(" + args + ") -> " + c.Return.String()
+	if c.SpreadType != nil {
+		if len(c.Args) > 0 {
+			args += ", "
+		}
+		args += "..." + c.SpreadType.String()
+	}
+	return params + "(" + args + ") -> " + c.Return.String()
+}
+
+// IsVariadic reports whether the function takes the variadic arguments packed into an array,
+// the built-in functions accepting values of any type receive them as separate arguments
+func (c ChlangFunctionType) IsVariadic() bool {
+	return c.SpreadType != nil && c.SpreadType != SymbolTypeAny
 }
 func (ChlangFunctionType) Type() {}
 
@@ -90,7 +349,8 @@ func (ChlangPrimitiveType) Type() {}
 // IsLeftCompatibleType checks if the left type is compatible with the right type
 // This is used for type checking
 func IsLeftCompatibleType(left, right ChlangType) bool {
-	if left == right {
+	left, right = Underlying(left), Underlying(right)
+	if left == right || left == SymbolTypeAny {
 		return true
 	}
 
@@ -108,13 +368,66 @@ func IsLeftCompatibleType(left, right ChlangType) bool {
 			return IsLeftCompatibleType(leftType.ElementType, rightArray.ElementType) &&
 				(leftType.Length == rightArray.Length || leftType.Length == 0)
 		}
+	case *ChlangTraitType:
+		switch rightType := right.(type) {
+		case *ChlangStructType:
+			return rightType.Implements(leftType)
+		case *ChlangTypeParam:
+			return rightType.HasBound(leftType)
+		}
+	case *ChlangTupleType:
+		if rightTuple, ok := right.(*ChlangTupleType); ok && len(leftType.Elements) == len(rightTuple.Elements) {
+			for i, element := range leftType.Elements {
+				if !IsLeftCompatibleType(element, rightTuple.Elements[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	case *ChlangOptionType:
+		if rightOption, ok := right.(*ChlangOptionType); ok {
+			return isLeftCompatibleTypeArg(leftType.Value, rightOption.Value)
+		}
+	case *ChlangResultType:
+		if rightResult, ok := right.(*ChlangResultType); ok {
+			return isLeftCompatibleTypeArg(leftType.Value, rightResult.Value) &&
+				isLeftCompatibleTypeArg(leftType.Error, rightResult.Error)
+		}
+	case *ChlangFunctionType:
+		if rightFunction, ok := right.(*ChlangFunctionType); ok {
+			if len(leftType.Args) != len(rightFunction.Args) || !isSameSpreadType(leftType.SpreadType, rightFunction.SpreadType) {
+				return false
+			}
+			// arguments and return types must match exactly
+			for i, arg := range leftType.Args {
+				if !IsLeftCompatibleType(arg, rightFunction.Args[i]) || !IsLeftCompatibleType(rightFunction.Args[i], arg) {
+					return false
+				}
+			}
+			return IsLeftCompatibleType(leftType.Return, rightFunction.Return) &&
+				IsLeftCompatibleType(rightFunction.Return, leftType.Return)
+		}
 	}
 
 	return false
 }
 
+// Variadic arguments of the function types must have the same element type
+func isSameSpreadType(left, right ChlangType) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	return IsLeftCompatibleType(left, right) && IsLeftCompatibleType(right, left)
+}
+
+// The unknown type argument on the right (e.g. the error type of 'Ok(1)') is compatible with any type
+func isLeftCompatibleTypeArg(left, right ChlangType) bool {
+	return right == nil || (left != nil && IsLeftCompatibleType(left, right))
+}
+
 // IsCompatibleType checks if the left type is compatible with the right type
 func IsCompatibleType(left, right ChlangType) bool {
+	left, right = Underlying(left), Underlying(right)
 	if left == right {
 		return true
 	}
@@ -133,7 +446,7 @@ func IsCompatibleType(left, right ChlangType) bool {
 		}
 	case *ChlangFunctionType:
 		if rightFunction, ok := right.(*ChlangFunctionType); ok {
-			if len(leftType.Args) != len(rightFunction.Args) {
+			if len(leftType.Args) != len(rightFunction.Args) || !isSameSpreadType(leftType.SpreadType, rightFunction.SpreadType) {
 				return false
 			}
 			for i, arg := range leftType.Args {
@@ -143,7 +456,29 @@ func IsCompatibleType(left, right ChlangType) bool {
 			}
 			return IsCompatibleType(leftType.Return, rightFunction.Return)
 		}
+	case *ChlangTupleType:
+		if rightTuple, ok := right.(*ChlangTupleType); ok && len(leftType.Elements) == len(rightTuple.Elements) {
+			for i, element := range leftType.Elements {
+				if !IsCompatibleType(element, rightTuple.Elements[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	case *ChlangOptionType:
+		if rightOption, ok := right.(*ChlangOptionType); ok {
+			return isCompatibleTypeArg(leftType.Value, rightOption.Value)
+		}
+	case *ChlangResultType:
+		if rightResult, ok := right.(*ChlangResultType); ok {
+			return isCompatibleTypeArg(leftType.Value, rightResult.Value) &&
+				isCompatibleTypeArg(leftType.Error, rightResult.Error)
+		}
 	}
 
 	return false
 }
+
+func isCompatibleTypeArg(left, right ChlangType) bool {
+	return left == nil || right == nil || IsCompatibleType(left, right)
+}
@@ -0,0 +1,363 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	chToken "github.com/usein-abilev/chlang/frontend/token"
+)
+
+// Type argument inferred for the type parameter of the generic function.
+// The operators applied to the parameter values are known after the body is checked, so they are verified at the end.
+type typeArgUse struct {
+	param *env.ChlangTypeParam
+	arg   env.ChlangType
+	owner string
+	span  *chToken.Span
+}
+
+// declareTypeParams resolves the type parameters of the generic function or struct, the bounds must be traits
+func (c *Checker) declareTypeParams(params []*ast.TypeParam) []*env.ChlangTypeParam {
+	typeParams := make([]*env.ChlangTypeParam, 0, len(params))
+	declared := make(map[string]bool, len(params))
+	for _, param := range params {
+		name := param.Name.Value
+		if declared[name] {
+			c.reportError(fmt.Sprintf("type parameter '%s' is declared more than once", name), param.Name.Span)
+			continue
+		}
+		declared[name] = true
+		if ty := c.lookupType(name); ty != nil {
+			c.reportError(fmt.Sprintf("cannot use type '%s' as a type parameter name", name), param.Name.Span)
+			continue
+		}
+
+		typeParam := &env.ChlangTypeParam{Name: name}
+		for _, bound := range param.Bounds {
+			boundEntity := c.lookupType(bound.Value)
+			if boundEntity == nil {
+				c.reportError(fmt.Sprintf("trait '%s' not found", bound.Value), bound.Span)
+				continue
+			}
+			traitType, ok := env.Underlying(boundEntity.Spec).(*env.ChlangTraitType)
+			if !ok {
+				c.reportError(fmt.Sprintf("'%s' is not a trait, only traits can bound type parameter '%s'", bound.Value, name), bound.Span)
+				continue
+			}
+			boundEntity.Used = true
+			typeParam.Bounds = append(typeParam.Bounds, traitType)
+		}
+		typeParams = append(typeParams, typeParam)
+	}
+	return typeParams
+}
+
+// enterTypeParams makes the type parameters visible to the type specifications and returns the previously visible ones
+func (c *Checker) enterTypeParams(params []*env.ChlangTypeParam) map[string]*env.ChlangTypeParam {
+	prev := c.typeParams
+	if len(params) == 0 {
+		return prev
+	}
+	c.typeParams = make(map[string]*env.ChlangTypeParam, len(prev)+len(params))
+	for name, param := range prev {
+		c.typeParams[name] = param
+	}
+	for _, param := range params {
+		c.typeParams[param.Name] = param
+	}
+	return prev
+}
+
+// checkMethodTypeParams reports an error if the method declares type parameters, only functions and structs can be generic
+func (c *Checker) checkMethodTypeParams(signature *ast.FunctionSignature, receiver string) bool {
+	if len(signature.TypeParams) == 0 {
+		return true
+	}
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("method '%s' of '%s' cannot have type parameters", signature.Name.Value, receiver),
+		HelpMsg:  "declare a generic function taking the receiver as an argument instead",
+		Span:     signature.TypeParams[0].Span,
+		Position: signature.TypeParams[0].Span.Start,
+	})
+	return false
+}
+
+// resolveGenericStruct resolves the instance of the generic struct, e.g. Pair<i32, string>
+func (c *Checker) resolveGenericStruct(spec *ast.GenericType) env.ChlangType {
+	var typeEntity *env.EnvTypeEntity
+	if spec.Package != nil {
+		if typeEntity = c.lookupPackageType(spec.Package, spec.Name); typeEntity == nil {
+			return env.SymbolTypeInvalid
+		}
+	} else if typeEntity = c.lookupType(spec.Name.Value); typeEntity == nil {
+		c.reportError(fmt.Sprintf("type '%s' not found", spec.Name.Value), spec.Name.Span)
+		return env.SymbolTypeInvalid
+	}
+	structType, ok := env.Underlying(typeEntity.Spec).(*env.ChlangStructType)
+	if !ok || len(structType.TypeParams) == 0 {
+		c.reportError(fmt.Sprintf("type '%s' has no type parameters", spec.Name.Value), spec.Span)
+		return env.SymbolTypeInvalid
+	}
+	if len(spec.Args) != len(structType.TypeParams) {
+		c.reportError(
+			fmt.Sprintf("struct '%s' expects %d type arguments, but got %d", structType.Name, len(structType.TypeParams), len(spec.Args)),
+			spec.Span,
+		)
+		return env.SymbolTypeInvalid
+	}
+	typeEntity.Used = true
+
+	args := make([]env.ChlangType, 0, len(spec.Args))
+	for idx, arg := range spec.Args {
+		argType := c.resolveASTType(arg)
+		if argType == env.SymbolTypeInvalid {
+			return env.SymbolTypeInvalid
+		}
+		if argType == env.SymbolTypeVoid {
+			c.reportError(fmt.Sprintf("cannot use 'void' as a type argument of '%s'", structType.Name), arg.GetSpan())
+			return env.SymbolTypeInvalid
+		}
+		if !c.checkTypeArgBounds(structType.TypeParams[idx], argType, "struct '"+structType.Name+"'", arg.GetSpan()) {
+			return env.SymbolTypeInvalid
+		}
+		args = append(args, argType)
+	}
+	return structType.Instantiate(args)
+}
+
+// inferStructTypeArgs infers the type arguments of the generic struct from the field values of the struct literal
+func (c *Checker) inferStructTypeArgs(e *ast.InitStructExpression, structType *env.ChlangStructType, fieldTypes []env.ChlangType) *env.ChlangStructType {
+	owner := "struct '" + structType.Name + "'"
+	bindings := newTypeBindings(structType.TypeParams)
+	for idx, field := range e.Fields {
+		if fieldTypes[idx] == env.SymbolTypeInvalid {
+			return nil
+		}
+		paramType := structType.LookupField(field.Name.Value).Type
+		if !c.unifyTypeArg(paramType, fieldTypes[idx], bindings, owner, field.Value.GetSpan()) {
+			return nil
+		}
+	}
+	args := c.boundTypeArgs(structType.TypeParams, bindings, owner, e.Span)
+	if args == nil {
+		return nil
+	}
+	return structType.Instantiate(args)
+}
+
+// instantiateFunction infers the type arguments of the generic function call from the argument types
+// and returns the function type with the type arguments substituted
+func (c *Checker) instantiateFunction(e *ast.CallExpression, fnSymbol *env.EnvSymbolEntity, functionType *env.ChlangFunctionType, argTypes []env.ChlangType) *env.ChlangFunctionType {
+	owner := "function '" + fnSymbol.Name + "'"
+	bindings := newTypeBindings(functionType.TypeParams)
+	for idx, argType := range argTypes {
+		if argType == env.SymbolTypeInvalid {
+			return nil
+		}
+		paramType := functionType.SpreadType
+		if idx < len(functionType.Args) {
+			paramType = functionType.Args[idx]
+		} else if _, ok := e.Args[idx].(*ast.SpreadExpression); ok {
+			arrayType, ok := argType.(*env.ChlangArrayType)
+			if !ok {
+				continue // the spread value is reported by the argument checks
+			}
+			argType = arrayType.ElementType
+		}
+		if !c.unifyTypeArg(paramType, argType, bindings, owner, e.Args[idx].GetSpan()) {
+			return nil
+		}
+	}
+
+	args := c.boundTypeArgs(functionType.TypeParams, bindings, owner, e.Span)
+	if args == nil {
+		return nil
+	}
+	e.TypeArgs = make([]ast.NodeLiteralType, 0, len(args))
+	for idx, param := range functionType.TypeParams {
+		c.typeArgUses = append(c.typeArgUses, typeArgUse{param: param, arg: args[idx], owner: owner, span: e.Span})
+		e.TypeArgs = append(e.TypeArgs, args[idx])
+	}
+	return env.Substitute(&env.ChlangFunctionType{
+		Args:       functionType.Args,
+		SpreadType: functionType.SpreadType,
+		Return:     functionType.Return,
+	}, bindings).(*env.ChlangFunctionType)
+}
+
+// newTypeBindings returns the bindings of the type parameters, the parameter is bound to nil until its type argument is inferred
+func newTypeBindings(params []*env.ChlangTypeParam) map[*env.ChlangTypeParam]env.ChlangType {
+	bindings := make(map[*env.ChlangTypeParam]env.ChlangType, len(params))
+	for _, param := range params {
+		bindings[param] = nil
+	}
+	return bindings
+}
+
+// unifyTypeArg binds the type parameters found in the parameter type to the corresponding parts of the argument type.
+// A parameter inferred from several arguments gets the widest of their types: max(1, 2i64) is max<i64>.
+// Mismatches of other parts of the types are left to the compatibility checks of the substituted types.
+func (c *Checker) unifyTypeArg(paramType, argType env.ChlangType, bindings map[*env.ChlangTypeParam]env.ChlangType, owner string, span *chToken.Span) bool {
+	if argType == nil {
+		return true // unknown type argument of Option or Result, e.g. 'None'
+	}
+	argType = env.Underlying(argType)
+
+	switch param := env.Underlying(paramType).(type) {
+	case *env.ChlangTypeParam:
+		bound, own := bindings[param]
+		if !own {
+			return true // the type parameter of the enclosing generic function
+		}
+		argType = c.getGeneralTypeOf(argType)
+		switch {
+		case bound == nil || env.IsLeftCompatibleType(argType, bound):
+			bindings[param] = argType
+		case env.IsLeftCompatibleType(bound, argType):
+		default:
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("type parameter '%s' of %s is inferred as both '%s' and '%s'", param.Name, owner, bound, argType),
+				HelpMsg:  "convert the arguments to the same type",
+				Span:     span,
+				Position: span.Start,
+			})
+			return false
+		}
+	case *env.ChlangArrayType:
+		if arg, ok := argType.(*env.ChlangArrayType); ok {
+			return c.unifyTypeArg(param.ElementType, arg.ElementType, bindings, owner, span)
+		}
+	case *env.ChlangTupleType:
+		if arg, ok := argType.(*env.ChlangTupleType); ok && len(arg.Elements) == len(param.Elements) {
+			for idx, element := range param.Elements {
+				if !c.unifyTypeArg(element, arg.Elements[idx], bindings, owner, span) {
+					return false
+				}
+			}
+		}
+	case *env.ChlangOptionType:
+		if arg, ok := argType.(*env.ChlangOptionType); ok {
+			return c.unifyTypeArg(param.Value, arg.Value, bindings, owner, span)
+		}
+	case *env.ChlangResultType:
+		if arg, ok := argType.(*env.ChlangResultType); ok {
+			return c.unifyTypeArg(param.Value, arg.Value, bindings, owner, span) &&
+				c.unifyTypeArg(param.Error, arg.Error, bindings, owner, span)
+		}
+	case *env.ChlangFunctionType:
+		if arg, ok := argType.(*env.ChlangFunctionType); ok && len(arg.Args) == len(param.Args) {
+			for idx, paramArg := range param.Args {
+				if !c.unifyTypeArg(paramArg, arg.Args[idx], bindings, owner, span) {
+					return false
+				}
+			}
+			return c.unifyTypeArg(param.Return, arg.Return, bindings, owner, span)
+		}
+	case *env.ChlangStructType:
+		if arg, ok := argType.(*env.ChlangStructType); ok && param.Generic != nil && param.Generic == arg.Generic {
+			for idx, paramArg := range param.TypeArgs {
+				if !c.unifyTypeArg(paramArg, arg.TypeArgs[idx], bindings, owner, span) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// boundTypeArgs returns the inferred type arguments in the order of the type parameters and checks their trait bounds
+func (c *Checker) boundTypeArgs(params []*env.ChlangTypeParam, bindings map[*env.ChlangTypeParam]env.ChlangType, owner string, span *chToken.Span) []env.ChlangType {
+	args := make([]env.ChlangType, 0, len(params))
+	for _, param := range params {
+		arg := bindings[param]
+		if arg == nil || env.HasUnknownType(arg) {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("cannot infer type parameter '%s' of %s", param.Name, owner),
+				HelpMsg:  fmt.Sprintf("use '%s' in the type of an argument or a field", param.Name),
+				Span:     span,
+				Position: span.Start,
+			})
+			return nil
+		}
+		if !c.checkTypeArgBounds(param, arg, owner, span) {
+			return nil
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// checkTypeArgBounds reports an error if the type argument doesn't implement the traits bounding the type parameter
+func (c *Checker) checkTypeArgBounds(param *env.ChlangTypeParam, arg env.ChlangType, owner string, span *chToken.Span) bool {
+	for _, bound := range param.Bounds {
+		if !env.IsLeftCompatibleType(bound, arg) {
+			c.reportError(
+				fmt.Sprintf("type '%s' does not implement trait '%s' required by type parameter '%s' of %s", arg, bound.Name, param.Name, owner),
+				span,
+			)
+			return false
+		}
+	}
+	return true
+}
+
+// checkTypeParamOperation checks the binary operator applied to the values of the type parameter.
+// Both operands must have the type of the parameter, the operator is required from all its type arguments.
+func (c *Checker) checkTypeParamOperation(a, b env.ChlangType, operator *chToken.Token) (env.ChlangType, error) {
+	param, ok := a.(*env.ChlangTypeParam)
+	if !ok {
+		param = b.(*env.ChlangTypeParam)
+	}
+	if a != b {
+		return env.SymbolTypeInvalid, fmt.Errorf("type mismatch: operator '%s' requires both operands of type '%s' (left: %s, right: %s)", operator.Literal, param, a, b)
+	}
+
+	switch operator.Type {
+	case chToken.PLUS, chToken.MINUS, chToken.ASTERISK, chToken.EXPONENT, chToken.PERCENT, chToken.SLASH:
+		param.Require(env.ConstraintNumeric, operator.Literal)
+		return param, nil
+	case chToken.AMPERSAND, chToken.PIPE, chToken.CARET, chToken.LEFT_SHIFT, chToken.RIGHT_SHIFT:
+		param.Require(env.ConstraintInteger, operator.Literal)
+		return param, nil
+	case chToken.EQUALS, chToken.NOT_EQUALS:
+		param.Require(env.ConstraintEquality, operator.Literal)
+		return env.SymbolTypeBool, nil
+	case chToken.LESS, chToken.LESS_EQUALS, chToken.GREATER, chToken.GREATER_EQUALS:
+		param.Require(env.ConstraintOrdered, operator.Literal)
+		return env.SymbolTypeBool, nil
+	}
+	return env.SymbolTypeInvalid, fmt.Errorf("type mismatch: operator '%s' cannot be applied to values of type parameter '%s'", operator.Literal, param)
+}
+
+// checkTypeArgConstraints verifies that the type arguments support the operators applied to the values of their parameters.
+// The type argument that is a type parameter of the enclosing generic function passes the constraints on to its own type arguments.
+func (c *Checker) checkTypeArgConstraints() {
+	for changed := true; changed; {
+		changed = false
+		for _, use := range c.typeArgUses {
+			outer, ok := use.arg.(*env.ChlangTypeParam)
+			if !ok {
+				continue
+			}
+			for constraint, operator := range use.param.Operators {
+				if outer.Require(constraint, operator) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, use := range c.typeArgUses {
+		if _, ok := use.arg.(*env.ChlangTypeParam); ok {
+			continue
+		}
+		if operator, unsupported := use.param.UnsupportedOperator(use.arg); unsupported {
+			c.reportError(
+				fmt.Sprintf("type '%s' does not support operator '%s' applied to type parameter '%s' of %s", use.arg, operator, use.param.Name, use.owner),
+				use.span,
+			)
+		}
+	}
+}
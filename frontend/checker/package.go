@@ -0,0 +1,121 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	"github.com/usein-abilev/chlang/frontend/token"
+)
+
+// lookupPackage returns the package imported under the name of the identifier,
+// or nil if the expression is not a package name (variables shadow the imported packages)
+func (c *Checker) lookupPackage(expr ast.Expression) *env.ChlangPackageType {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	symbol := c.Env.LookupSymbol(ident.Value)
+	if symbol == nil || symbol.EntityType != env.SymbolEntityPackage {
+		return nil
+	}
+	symbol.Used = true
+	ident.Symbol = symbol
+	return symbol.Type.(*env.ChlangPackageType)
+}
+
+// lookupPackageSymbol returns the function or constant exported by the package: geometry.area, math.PI
+func (c *Checker) lookupPackageSymbol(pkg *env.ChlangPackageType, member *ast.Identifier) *env.EnvSymbolEntity {
+	symbol := pkg.LookupSymbol(member.Value)
+	if symbol == nil {
+		c.reportError(fmt.Sprintf("symbol '%s' not found in package '%s'", member.Value, pkg.Name), member.Span)
+		return nil
+	}
+	if !symbol.Public {
+		c.reportNotExported(pkg, member)
+		return nil
+	}
+	symbol.Used = true
+	return symbol
+}
+
+// lookupPackageType returns the type exported by the package named by the identifier: geometry.Point
+func (c *Checker) lookupPackageType(pkgName *ast.Identifier, member *ast.Identifier) *env.EnvTypeEntity {
+	pkg := c.lookupPackage(pkgName)
+	if pkg == nil {
+		c.reportError(fmt.Sprintf("'%s' is not an imported package", pkgName.Value), pkgName.Span)
+		return nil
+	}
+	typeEntity := pkg.LookupType(member.Value)
+	if typeEntity == nil {
+		c.reportError(fmt.Sprintf("type '%s' not found in package '%s'", member.Value, pkg.Name), member.Span)
+		return nil
+	}
+	if !typeEntity.Public {
+		c.reportNotExported(pkg, member)
+		return nil
+	}
+	return typeEntity
+}
+
+func (c *Checker) reportNotExported(pkg *env.ChlangPackageType, member *ast.Identifier) {
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("'%s' is not exported by package '%s'", member.Value, pkg.Name),
+		HelpMsg:  fmt.Sprintf("declare it with 'pub' to use it outside of package '%s'", pkg.Name),
+		Span:     member.Span,
+		Position: member.Span.Start,
+	})
+}
+
+// inferPackageMember checks the access to the constant or function exported by the package: math.PI, geometry.area
+func (c *Checker) inferPackageMember(expr *ast.MemberExpression, pkg *env.ChlangPackageType) env.ChlangType {
+	symbol := c.lookupPackageSymbol(pkg, expr.Member)
+	if symbol == nil {
+		return env.SymbolTypeInvalid
+	}
+	if functionType, ok := symbol.Type.(*env.ChlangFunctionType); ok && len(functionType.TypeParams) > 0 {
+		c.reportError(fmt.Sprintf("cannot use generic function '%s.%s' as a value, the type arguments are inferred at the call", pkg.Name, symbol.Name), expr.Span)
+		return env.SymbolTypeInvalid
+	}
+	expr.LeftType = pkg
+	expr.Symbol = symbol
+	return symbol.Type
+}
+
+// isImported reports whether the struct is declared by another package, its private fields and methods are not accessible
+func (c *Checker) isImported(structType *env.ChlangStructType) bool {
+	if structType.Generic != nil {
+		structType = structType.Generic
+	}
+	typeEntity := c.Env.LookupType(structType.Name)
+	return typeEntity == nil || env.Underlying(typeEntity.Spec) != structType
+}
+
+// checkFieldAccess reports the access to the private field of the struct declared by another package
+func (c *Checker) checkFieldAccess(structType *env.ChlangStructType, field *env.ChlangStructField, span *token.Span) bool {
+	if field.Public || !c.isImported(structType) {
+		return true
+	}
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("field '%s' of struct '%s' is private", field.Name, structType.Name),
+		HelpMsg:  "declare the field with 'pub' to access it outside of the package of the struct",
+		Span:     span,
+		Position: span.Start,
+	})
+	return false
+}
+
+// checkMethodAccess reports the call of the private method of the struct declared by another package
+func (c *Checker) checkMethodAccess(structType *env.ChlangStructType, name string, span *token.Span) bool {
+	if structType.IsPublicMethod(name) || !c.isImported(structType) {
+		return true
+	}
+	c.Errors = append(c.Errors, &errors.SemanticError{
+		Message:  fmt.Sprintf("method '%s' of struct '%s' is private", name, structType.Name),
+		HelpMsg:  "declare the method with 'pub' to call it outside of the package of the struct",
+		Span:     span,
+		Position: span.Start,
+	})
+	return false
+}
@@ -0,0 +1,181 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+)
+
+// resolveGenericType resolves the built-in types with type arguments: Option<T> and Result<T, E>, or the instance of the generic struct
+func (c *Checker) resolveGenericType(spec *ast.GenericType) env.ChlangType {
+	expected := map[string]int{"Option": 1, "Result": 2}
+	count, ok := expected[spec.Name.Value]
+	if !ok || spec.Package != nil {
+		return c.resolveGenericStruct(spec)
+	}
+	if len(spec.Args) != count {
+		c.reportError(fmt.Sprintf("type '%s' expects %d type arguments, but got %d", spec.Name.Value, count, len(spec.Args)), spec.Span)
+		return env.SymbolTypeInvalid
+	}
+
+	args := make([]env.ChlangType, 0, count)
+	for _, arg := range spec.Args {
+		argType := c.resolveASTType(arg)
+		if argType == env.SymbolTypeInvalid {
+			return env.SymbolTypeInvalid
+		}
+		if argType == env.SymbolTypeVoid {
+			c.reportError(fmt.Sprintf("cannot use 'void' as a type argument of '%s'", spec.Name.Value), arg.GetSpan())
+			return env.SymbolTypeInvalid
+		}
+		args = append(args, argType)
+	}
+
+	if spec.Name.Value == "Option" {
+		return &env.ChlangOptionType{Value: args[0]}
+	}
+	return &env.ChlangResultType{Value: args[0], Error: args[1]}
+}
+
+// inferBuiltinConstruction checks the construction of the built-in variant: Some(x), Ok(x) or Err(e).
+// The other type argument stays unknown until the value meets a typed place, e.g. 'Ok(1)' is Result<i8, _>
+func (c *Checker) inferBuiltinConstruction(call *ast.CallExpression, name string) env.ChlangType {
+	variant := env.BuiltinVariantEnum(name).LookupVariant(name)
+	if len(call.Args) != len(variant.Payload) {
+		c.reportError(fmt.Sprintf("variant '%s' expects %d payload values, but got %d", name, len(variant.Payload), len(call.Args)), call.Span)
+		return env.SymbolTypeInvalid
+	}
+	payloadType := c.inferExpression(call.Args[0])
+	if payloadType == env.SymbolTypeInvalid {
+		return env.SymbolTypeInvalid
+	}
+	if payloadType == env.SymbolTypeVoid {
+		c.reportError(fmt.Sprintf("variant '%s' cannot hold a 'void' value", name), call.Args[0].GetSpan())
+		return env.SymbolTypeInvalid
+	}
+
+	switch name {
+	case "Some":
+		return &env.ChlangOptionType{Value: payloadType}
+	case "Ok":
+		return &env.ChlangResultType{Value: payloadType}
+	default:
+		return &env.ChlangResultType{Error: payloadType}
+	}
+}
+
+// inferBuiltinMethod returns the symbol of the Option and Result method:
+// unwrap, unwrap_or, is_some and is_none for Option, unwrap, unwrap_or, is_ok and is_err for Result
+func (c *Checker) inferBuiltinMethod(expr *ast.MemberExpression, receiverType env.ChlangType) *env.EnvSymbolEntity {
+	var valueType env.ChlangType
+	var checks []string
+	switch receiver := receiverType.(type) {
+	case *env.ChlangOptionType:
+		valueType, checks = receiver.Value, []string{"is_some", "is_none"}
+	case *env.ChlangResultType:
+		valueType, checks = receiver.Value, []string{"is_ok", "is_err"}
+	}
+	expr.LeftType = receiverType
+
+	member := expr.Member.Value
+	methodType := &env.ChlangFunctionType{Return: env.SymbolTypeBool}
+	switch member {
+	case checks[0], checks[1]:
+	case "unwrap", "unwrap_or":
+		if valueType == nil {
+			c.reportError(fmt.Sprintf("cannot infer the value type of '%s' to call method '%s'", receiverType, member), expr.Span)
+			return nil
+		}
+		methodType.Return = valueType
+		if member == "unwrap_or" {
+			methodType.Args = []env.ChlangType{valueType}
+		}
+	default:
+		c.reportError(fmt.Sprintf("method '%s' not found in type '%s'", member, receiverType), expr.Span)
+		return nil
+	}
+
+	method := &env.EnvSymbolEntity{
+		Name:       member,
+		Used:       true,
+		Type:       methodType,
+		EntityType: env.SymbolEntityFunction,
+	}
+	expr.Symbol = method
+	return method
+}
+
+// inferTryExpression checks the '?' operator. The Err (None) value is returned from the enclosing function as is,
+// so the function must return Result with a compatible error type (Option for the Option operand).
+func (c *Checker) inferTryExpression(e *ast.TryExpression) env.ChlangType {
+	operandType := c.inferExpression(e.Expression)
+	if operandType == env.SymbolTypeInvalid {
+		return env.SymbolTypeInvalid
+	}
+	if c.function == nil {
+		c.reportError("the '?' operator can only be used inside a function", e.Span)
+		return env.SymbolTypeInvalid
+	}
+	returnType := env.Underlying(c.function.Type.(*env.ChlangFunctionType).Return)
+
+	var valueType env.ChlangType
+	switch operand := operandType.(type) {
+	case *env.ChlangResultType:
+		functionResult, ok := returnType.(*env.ChlangResultType)
+		if !ok {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("the '?' operator returns the error from function '%s', but the function returns '%s'", c.function.Name, returnType),
+				HelpMsg:  fmt.Sprintf("change the return type of '%s' to Result<T, E>", c.function.Name),
+				Span:     e.Span,
+				Position: e.Span.Start,
+			})
+			return env.SymbolTypeInvalid
+		}
+		if operand.Error != nil && !env.IsLeftCompatibleType(functionResult.Error, operand.Error) {
+			c.reportError(
+				fmt.Sprintf("error type '%s' is not compatible with error type '%s' of function '%s'", operand.Error, functionResult.Error, c.function.Name),
+				e.Span,
+			)
+			return env.SymbolTypeInvalid
+		}
+		valueType = operand.Value
+	case *env.ChlangOptionType:
+		if _, ok := returnType.(*env.ChlangOptionType); !ok {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("the '?' operator returns None from function '%s', but the function returns '%s'", c.function.Name, returnType),
+				HelpMsg:  fmt.Sprintf("change the return type of '%s' to Option<T>", c.function.Name),
+				Span:     e.Span,
+				Position: e.Span.Start,
+			})
+			return env.SymbolTypeInvalid
+		}
+		valueType = operand.Value
+	default:
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("the '?' operator cannot be applied to type '%s'", operandType),
+			HelpMsg:  "the '?' operator is applicable to Result and Option values",
+			Span:     e.Span,
+			Position: e.Span.Start,
+		})
+		return env.SymbolTypeInvalid
+	}
+
+	if valueType == nil {
+		c.reportError(fmt.Sprintf("cannot infer the value type of '%s'", operandType), e.Span)
+		return env.SymbolTypeInvalid
+	}
+	return valueType
+}
+
+// getMaxTypeArgOf unifies type arguments of Option and Result, the unknown argument (nil) takes the other one
+func (c *Checker) getMaxTypeArgOf(left, right env.ChlangType) env.ChlangType {
+	if left == nil || left == right {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	return c.getMaxTypeOf(left, right)
+}
@@ -0,0 +1,126 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	chToken "github.com/usein-abilev/chlang/frontend/token"
+)
+
+// inferTupleExpression returns the tuple type of the comma separated values, e.g. 'return a, 2, 2.65'
+func (c *Checker) inferTupleExpression(e *ast.TupleExpression) env.ChlangType {
+	tupleType := &env.ChlangTupleType{Elements: make([]env.ChlangType, 0, len(e.Elements))}
+	for _, element := range e.Elements {
+		elementType := c.inferExpression(element)
+		if elementType == env.SymbolTypeInvalid || !c.checkSingleValue(element, elementType) {
+			return env.SymbolTypeInvalid
+		}
+		if elementType == env.SymbolTypeVoid {
+			c.reportError("cannot use 'void' as a value", element.GetSpan())
+			return env.SymbolTypeInvalid
+		}
+		tupleType.Elements = append(tupleType.Elements, elementType)
+	}
+	return tupleType
+}
+
+// checkSingleValue reports an error if the expression is a call of the function with several results
+func (c *Checker) checkSingleValue(expr ast.Expression, exprType env.ChlangType) bool {
+	if tuple, ok := exprType.(*env.ChlangTupleType); ok {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("multiple values '%s' in single-value context", tuple),
+			HelpMsg:  "assign the values to variables first: let a, b = ...",
+			Span:     expr.GetSpan(),
+			Position: expr.GetSpan().Start,
+		})
+		return false
+	}
+	return true
+}
+
+// inferTupleValues checks that the value has the expected number of elements, e.g. 'let a, b = f()'
+func (c *Checker) inferTupleValues(value ast.Expression, count int, span *chToken.Span) *env.ChlangTupleType {
+	valueType := c.inferExpression(value)
+	if valueType == env.SymbolTypeInvalid {
+		return nil
+	}
+	tuple, ok := valueType.(*env.ChlangTupleType)
+	if !ok {
+		c.reportError(fmt.Sprintf("assignment mismatch: %d variables but 1 value of type '%s'", count, valueType), span)
+		return nil
+	}
+	if len(tuple.Elements) != count {
+		c.reportError(fmt.Sprintf("assignment mismatch: %d variables but %d values", count, len(tuple.Elements)), span)
+		return nil
+	}
+	return tuple
+}
+
+// visitTupleDeclaration declares a variable for each value: let a, b, c = f()
+func (c *Checker) visitTupleDeclaration(stmt *ast.TupleDeclarationStatement) {
+	declared := make(map[string]bool, len(stmt.Names))
+	for _, name := range stmt.Names {
+		if declared[name.Value] {
+			c.reportError(fmt.Sprintf("variable '%s' is declared more than once", name.Value), name.Span)
+			return
+		}
+		declared[name.Value] = true
+		if sym := c.Env.LookupSymbolLocal(name.Value); sym != nil {
+			c.Errors = append(c.Errors, &errors.SemanticError{
+				Message:  fmt.Sprintf("variable '%s' has already been declared at %s", name.Value, sym.Span),
+				Position: stmt.Span.Start,
+				Span:     stmt.Span,
+			})
+			return
+		}
+	}
+
+	tuple := c.inferTupleValues(stmt.Value, len(stmt.Names), stmt.Span)
+	if tuple == nil {
+		return
+	}
+
+	stmt.Symbols = make([]ast.NodeSymbolRef, 0, len(stmt.Names))
+	for idx, name := range stmt.Names {
+		symbol := &env.EnvSymbolEntity{
+			Name:       name.Value,
+			Type:       c.getGeneralTypeOf(tuple.Elements[idx]),
+			EntityType: env.SymbolEntityVariable,
+			Span:       stmt.Span,
+		}
+		c.Env.InsertSymbol(symbol)
+		name.Symbol = symbol
+		stmt.Symbols = append(stmt.Symbols, symbol)
+	}
+}
+
+// inferTupleAssignment checks the parallel assignment 'a, b = b, a', the parser allows only the plain assignment here
+func (c *Checker) inferTupleAssignment(e *ast.AssignExpression) env.ChlangType {
+	targets := e.Left.(*ast.TupleExpression)
+
+	targetTypes := make([]env.ChlangType, 0, len(targets.Elements))
+	for _, target := range targets.Elements {
+		targetType := c.inferExpression(target)
+		if targetType == env.SymbolTypeInvalid || !c.checkAssignTarget(target, target.GetSpan()) {
+			return env.SymbolTypeInvalid
+		}
+		targetTypes = append(targetTypes, targetType)
+	}
+
+	tuple := c.inferTupleValues(e.Right, len(targets.Elements), e.Span)
+	if tuple == nil {
+		return env.SymbolTypeInvalid
+	}
+	for idx, targetType := range targetTypes {
+		if !env.IsLeftCompatibleType(targetType, tuple.Elements[idx]) {
+			c.reportError(
+				fmt.Sprintf("cannot assign value of type '%s' to '%s' in the parallel assignment", tuple.Elements[idx], targetType),
+				targets.Elements[idx].GetSpan(),
+			)
+			return env.SymbolTypeInvalid
+		}
+	}
+	return env.SymbolTypeVoid
+}
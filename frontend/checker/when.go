@@ -0,0 +1,364 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	chToken "github.com/usein-abilev/chlang/frontend/token"
+)
+
+// whenCoverage tracks the values matched by the previous arms of the when expression
+type whenCoverage struct {
+	variants map[string]bool // matched enum variants
+	values   map[string]bool // matched literal values
+	ranges   [][2]int64      // matched integer ranges, bounds are inclusive
+	all      bool            // the 'else' arm has been seen
+}
+
+func (w *whenCoverage) coversInt(value int64) bool {
+	for _, r := range w.ranges {
+		if value >= r[0] && value <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the values which are not matched by the arms, the result is empty if the match is exhaustive.
+// Only enums, Option, Result and bools can be matched exhaustively without the 'else' arm.
+func (w *whenCoverage) missing(subjectType env.ChlangType) []string {
+	if w.all {
+		return nil
+	}
+	missing := make([]string, 0)
+	switch t := subjectType.(type) {
+	case *env.ChlangEnumType:
+		for _, variant := range t.Variants {
+			if !w.variants[variant.Name] {
+				missing = append(missing, fmt.Sprintf("'%s.%s'", t.Name, variant.Name))
+			}
+		}
+	case *env.ChlangOptionType, *env.ChlangResultType:
+		enum := env.OptionEnum
+		if _, ok := t.(*env.ChlangResultType); ok {
+			enum = env.ResultEnum
+		}
+		for _, variant := range enum.Variants {
+			if !w.variants[variant.Name] {
+				missing = append(missing, fmt.Sprintf("'%s'", variant.Name))
+			}
+		}
+	case env.ChlangPrimitiveType:
+		if t == env.SymbolTypeBool {
+			for _, value := range []string{"true", "false"} {
+				if !w.values[value] {
+					missing = append(missing, fmt.Sprintf("'%s'", value))
+				}
+			}
+			break
+		}
+		missing = append(missing, "all other values")
+	default:
+		missing = append(missing, "all other values")
+	}
+	return missing
+}
+
+// inferWhenExpression checks the arms patterns against the subject type and unifies the arms values like the if expression does.
+// A match on enums and bools, as well as a match producing a value, must be exhaustive. Unreachable arms are reported as warnings.
+func (c *Checker) inferWhenExpression(e *ast.WhenExpression) env.ChlangType {
+	subjectType := c.inferExpression(e.Subject)
+	if subjectType == env.SymbolTypeInvalid {
+		return env.SymbolTypeInvalid
+	}
+	e.SubjectType = subjectType
+
+	coverage := &whenCoverage{variants: make(map[string]bool), values: make(map[string]bool)}
+	valid := true
+	var resultType env.ChlangType
+	for _, arm := range e.Arms {
+		c.Env.OpenScope()
+		if !c.checkWhenPattern(arm, subjectType, coverage) {
+			valid = false
+		}
+		armType := c.inferIfBlockStatement(arm.Body)
+		c.Env.CloseScope()
+
+		// arms leaving the expression (return, break, continue) don't produce a value
+		if isDivergingBlock(arm.Body) || armType == env.SymbolTypeInvalid {
+			continue
+		}
+		if resultType == nil {
+			resultType = armType
+			continue
+		}
+		if !env.IsCompatibleType(resultType, armType) {
+			c.reportError(fmt.Sprintf("cannot determine a single type of when expression (%s, %s)", resultType, armType), arm.Body.GetSpan())
+			valid = false
+			continue
+		}
+		if resultType != armType {
+			resultType = c.getMaxTypeOf(resultType, armType)
+		}
+	}
+	if resultType == nil {
+		resultType = env.SymbolTypeVoid
+	}
+	if !valid {
+		return env.SymbolTypeInvalid
+	}
+
+	isEnum := false
+	switch subjectType.(type) {
+	case *env.ChlangEnumType, *env.ChlangOptionType, *env.ChlangResultType:
+		isEnum = true
+	}
+	mustBeExhaustive := isEnum || subjectType == env.SymbolTypeBool || resultType != env.SymbolTypeVoid
+	if missing := coverage.missing(subjectType); mustBeExhaustive && len(missing) > 0 {
+		c.Errors = append(c.Errors, &errors.SemanticError{
+			Message:  fmt.Sprintf("non-exhaustive when expression: %s not matched", strings.Join(missing, ", ")),
+			HelpMsg:  "add the missing arms or the 'else' arm",
+			Span:     e.Span,
+			Position: e.Span.Start,
+		})
+		return env.SymbolTypeInvalid
+	}
+	return resultType
+}
+
+// checkWhenPattern checks the arm pattern, declares the payload bindings in the current scope and records the matched values
+func (c *Checker) checkWhenPattern(arm *ast.WhenArm, subjectType env.ChlangType, coverage *whenCoverage) bool {
+	unreachable := coverage.all
+	switch pattern := arm.Pattern.(type) {
+	case nil:
+		if !unreachable && len(coverage.missing(subjectType)) == 0 {
+			c.reportWarning("unreachable 'else' arm: all values are already matched", arm.Span)
+		}
+		coverage.all = true
+		return true
+	case *ast.RangeExpr:
+		primitive, ok := subjectType.(env.ChlangPrimitiveType)
+		if !ok || !primitive.IsInteger() {
+			c.reportError(fmt.Sprintf("range pattern cannot match value of type '%s'", subjectType), pattern.Span)
+			return false
+		}
+		start, okStart := c.inferIntPattern(pattern.Start)
+		end, okEnd := c.inferIntPattern(pattern.End)
+		if !okStart || !okEnd {
+			return false
+		}
+		if !pattern.Inclusive {
+			end--
+		}
+		if start > end {
+			c.reportError("empty range pattern: the start is greater than the end", pattern.Span)
+			return false
+		}
+		for _, r := range coverage.ranges {
+			if start >= r[0] && end <= r[1] {
+				unreachable = true
+			}
+		}
+		coverage.ranges = append(coverage.ranges, [2]int64{start, end})
+	case *ast.MemberExpression, *ast.Identifier:
+		name, payload, ok := c.lookupWhenVariant(pattern, subjectType)
+		if !ok {
+			return false
+		}
+		if len(payload) > 0 {
+			c.reportError(fmt.Sprintf("variant '%s' has %d payload values, bind them: %s(...)", name, len(payload), name), pattern.GetSpan())
+			return false
+		}
+		unreachable = unreachable || coverage.variants[name]
+		coverage.variants[name] = true
+	case *ast.CallExpression:
+		name, payload, ok := c.lookupWhenVariant(pattern.Function, subjectType)
+		if !ok {
+			return false
+		}
+		if len(pattern.Args) != len(payload) {
+			c.reportError(
+				fmt.Sprintf("variant '%s' has %d payload values, but %d bindings given", name, len(payload), len(pattern.Args)),
+				pattern.Span,
+			)
+			return false
+		}
+		for idx, arg := range pattern.Args {
+			binding, ok := arg.(*ast.Identifier)
+			if !ok {
+				c.reportError("invalid payload binding: expected an identifier", arg.GetSpan())
+				return false
+			}
+			if binding.Value == "_" {
+				continue
+			}
+			if payload[idx] == nil {
+				c.reportError(fmt.Sprintf("cannot infer the type of payload binding '%s' from '%s'", binding.Value, subjectType), binding.Span)
+				return false
+			}
+			symbol := &env.EnvSymbolEntity{
+				Name:       binding.Value,
+				Type:       payload[idx],
+				EntityType: env.SymbolEntityVariable,
+				Span:       binding.Span,
+			}
+			if !c.Env.InsertSymbol(symbol) {
+				c.reportError(fmt.Sprintf("payload binding '%s' has already been declared", binding.Value), binding.Span)
+				return false
+			}
+			binding.Symbol = symbol
+		}
+		unreachable = unreachable || coverage.variants[name]
+		coverage.variants[name] = true
+	default:
+		key, ok := c.inferLiteralPattern(pattern, subjectType)
+		if !ok {
+			return false
+		}
+		if value, err := strconv.ParseInt(key, 10, 64); err == nil && coverage.coversInt(value) {
+			unreachable = true
+		}
+		unreachable = unreachable || coverage.values[key]
+		coverage.values[key] = true
+	}
+
+	if unreachable {
+		c.reportWarning("unreachable when arm: the pattern is already matched by the previous arms", arm.Span)
+	}
+	return true
+}
+
+// lookupWhenVariant resolves the variant pattern: 'Enum.Variant' or the built-in 'Some', 'None', 'Ok', 'Err',
+// and checks that it belongs to the type of the subject. It returns the name of the variant and its payload types.
+func (c *Checker) lookupWhenVariant(pattern ast.Expression, subjectType env.ChlangType) (string, []env.ChlangType, bool) {
+	switch p := pattern.(type) {
+	case *ast.MemberExpression:
+		enumType, variant, ok := c.lookupEnumVariant(p)
+		if !ok {
+			break
+		}
+		if variant == nil {
+			return "", nil, false
+		}
+		if enumType != subjectType {
+			c.reportError(fmt.Sprintf("pattern of type '%s' cannot match value of type '%s'", enumType, subjectType), p.Span)
+			return "", nil, false
+		}
+		return variant.Name, variant.Payload, true
+	case *ast.Identifier:
+		symbol := c.Env.LookupSymbol(p.Value)
+		if symbol == nil || symbol.EntityType != env.SymbolEntityVariant {
+			break
+		}
+		p.Symbol = symbol
+		var payload []env.ChlangType
+		found := false
+		switch subject := subjectType.(type) {
+		case *env.ChlangOptionType:
+			payload, found = subject.VariantPayload(p.Value)
+		case *env.ChlangResultType:
+			payload, found = subject.VariantPayload(p.Value)
+		}
+		if !found {
+			c.reportError(fmt.Sprintf("pattern '%s' cannot match value of type '%s'", p.Value, subjectType), p.Span)
+			return "", nil, false
+		}
+		return p.Value, payload, true
+	}
+	c.reportError("invalid pattern: expected a literal, a range or an enum variant", pattern.GetSpan())
+	return "", nil, false
+}
+
+// inferLiteralPattern checks the literal pattern and returns its value as the key of the coverage,
+// integers and floats must match the subject kind exactly, because the VM doesn't compare integers with floats
+func (c *Checker) inferLiteralPattern(pattern ast.Expression, subjectType env.ChlangType) (string, bool) {
+	literal := pattern
+	negative := false
+	if unary, ok := pattern.(*ast.UnaryExpression); ok && unary.Operator.Type == chToken.MINUS {
+		literal, negative = unary.Right, true
+	}
+
+	switch literal.(type) {
+	case *ast.IntLiteral, *ast.FloatLiteral:
+	case *ast.BoolLiteral, *ast.StringLiteral:
+		if negative {
+			c.reportError("invalid pattern: expected a literal, a range or an enum variant", pattern.GetSpan())
+			return "", false
+		}
+	default:
+		c.reportError("invalid pattern: expected a literal, a range or an enum variant", pattern.GetSpan())
+		return "", false
+	}
+
+	patternType := c.inferExpression(pattern)
+	if patternType == env.SymbolTypeInvalid {
+		return "", false
+	}
+	subject, ok := subjectType.(env.ChlangPrimitiveType)
+	patternPrimitive := patternType.(env.ChlangPrimitiveType)
+	if !ok || !(subject == patternPrimitive ||
+		(subject.IsInteger() && patternPrimitive.IsInteger()) ||
+		(subject.IsFloat() && patternPrimitive.IsFloat())) {
+		c.reportError(fmt.Sprintf("pattern of type '%s' cannot match value of type '%s'", patternType, subjectType), pattern.GetSpan())
+		return "", false
+	}
+
+	var key string
+	switch lit := literal.(type) {
+	case *ast.IntLiteral:
+		_, value := c.inferIntLiteral(lit)
+		if negative {
+			value = -value
+		}
+		key = strconv.FormatInt(value, 10)
+	case *ast.FloatLiteral:
+		value, _ := strconv.ParseFloat(lit.Value, 64)
+		if negative {
+			value = -value
+		}
+		key = "float:" + strconv.FormatFloat(value, 'g', -1, 64)
+	case *ast.BoolLiteral:
+		key = lit.Value
+	case *ast.StringLiteral:
+		key = "string:" + lit.Value
+	}
+	return key, true
+}
+
+// inferIntPattern checks the bound of the range pattern, it must be an integer literal
+func (c *Checker) inferIntPattern(pattern ast.Expression) (int64, bool) {
+	literal := pattern
+	negative := false
+	if unary, ok := pattern.(*ast.UnaryExpression); ok && unary.Operator.Type == chToken.MINUS {
+		literal, negative = unary.Right, true
+	}
+	intLiteral, ok := literal.(*ast.IntLiteral)
+	if !ok {
+		c.reportError("range pattern bounds must be integer literals", pattern.GetSpan())
+		return 0, false
+	}
+	if c.inferExpression(pattern) == env.SymbolTypeInvalid {
+		return 0, false
+	}
+	_, value := c.inferIntLiteral(intLiteral)
+	if negative {
+		value = -value
+	}
+	return value, true
+}
+
+// isDivergingBlock reports whether the block ends with the statement leaving it: return, break or continue
+func isDivergingBlock(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	switch block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		return true
+	}
+	return false
+}
@@ -50,6 +50,29 @@ func (e SemanticError) Error() string {
 	return e.Message
 }
 
+// InternalError represents a failure of the compiler itself, e.g. a panic of the type checker.
+// The source may be valid, the error is not caused by the program.
+type InternalError struct {
+	Message string
+}
+
+func (e InternalError) Error() string {
+	return e.Message
+}
+
+// SemanticWarning represents a problem in the source code that doesn't stop the compilation.
+// For example, unreachable arms of the when expression.
+type SemanticWarning struct {
+	Message  string
+	HelpMsg  string
+	Span     *token.Span
+	Position token.TokenPosition
+}
+
+func (e SemanticWarning) Error() string {
+	return e.Message
+}
+
 // Write writes the error message to the given writer.
 // It includes the error message, the position in the source code,
 // the line where the error occurred, and a help message.
@@ -96,3 +119,20 @@ func (e SemanticError) Write(w io.Writer) {
 	}
 	fmt.Fprintf(w, "\n")
 }
+
+func (e SemanticWarning) Write(w io.Writer) {
+	fmt.Fprintf(w, "\033[33mwarning:\033[0m \033[34m%s\n", e.Message)
+	filename := e.Position.Filename
+	if filename == "" {
+		filename = "source"
+	}
+	fmt.Fprintf(w, "--> <%s>%d:%d\033[0m\n", filename, e.Position.Row, e.Position.Column)
+	if e.HelpMsg != "" {
+		fmt.Fprintf(w, "\033[33m%s\033[0m\n", e.HelpMsg)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+func (e InternalError) Write(w io.Writer) {
+	fmt.Fprintf(w, "\033[31minternal compiler error:\033[0m %s\n", e.Message)
+}
@@ -0,0 +1,246 @@
+package frontend
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/errors"
+	"github.com/usein-abilev/chlang/frontend/token"
+)
+
+// SourceExtension is the extension of the source files, the package directory consists of them
+const SourceExtension = ".chl"
+
+// Package is the checked program of the entry file or of the imported package directory
+type Package struct {
+	// Name is declared by the 'package' statement of the package files, it is 'main' if the entry file omits it
+	Name string
+
+	// Path is the import path relative to the project root, it is empty for the entry file
+	Path string
+
+	// Program contains the statements of all package files
+	Program *ast.Program
+
+	// Env is the top-level scope of the package, the other packages access its exported declarations
+	Env *env.Env
+
+	// Imports are the packages imported by the program, in the order of the import statements
+	Imports []*Package
+}
+
+// Build compiles the entry file and the packages it imports.
+// An import path is the directory relative to the project root, the '.chl' files of the directory declare
+// the package named after the last element of the path: import "shapes/geometry" loads the package 'geometry'.
+// The packages are returned in the dependency order, the entry package is the last one.
+// The error is returned if the entry file cannot be read, the problems of the imported packages are diagnostics.
+func Build(entry string, opts *Options) ([]*Package, []errors.CompilerError, error) {
+	source, err := os.ReadFile(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buildOpts := Options{}
+	if opts != nil {
+		buildOpts = *opts
+	}
+	if buildOpts.Filename == "" {
+		buildOpts.Filename = entry
+	}
+	if buildOpts.Root == "" {
+		buildOpts.Root = filepath.Dir(entry)
+	}
+
+	loader := &packageLoader{
+		opts:     &buildOpts,
+		packages: make(map[string]*Package),
+	}
+	buildOpts.logf("Scanning and parsing %s", entry)
+	program, diagnostics := parse(buildOpts.Filename, string(source))
+	if len(diagnostics) > 0 {
+		return nil, withFilename(diagnostics, buildOpts.Filename), nil
+	}
+
+	pkg := &Package{Name: "main", Program: program}
+	if program.Package != nil {
+		pkg.Name = program.Package.Name.Value
+	}
+	loader.check(pkg, buildOpts.Env)
+	if len(loader.diagnostics) > 0 {
+		return nil, withFilename(loader.diagnostics, buildOpts.Filename), nil
+	}
+	return loader.order, nil, nil
+}
+
+// packageLoader loads the imported packages, every package is compiled once before the packages importing it
+type packageLoader struct {
+	opts *Options
+
+	// compiled packages by their import paths, the package is nil if it failed to compile
+	packages map[string]*Package
+
+	// import paths of the packages being loaded, the import of one of them is the import cycle
+	loading []string
+
+	order       []*Package
+	diagnostics []errors.CompilerError
+}
+
+// load compiles the package imported by the path literal, it returns nil if the package has errors
+func (l *packageLoader) load(literal *ast.StringLiteral) *Package {
+	importPath := importPathOf(literal)
+	cleaned := path.Clean(importPath)
+	if importPath == "" || cleaned != importPath || path.IsAbs(importPath) || strings.HasPrefix(cleaned, "..") {
+		l.report(fmt.Sprintf("invalid import path %s", literal.Value), "the import path is the directory relative to the project root: import \"shapes/geometry\"", literal.Span)
+		return nil
+	}
+	if pkg, loaded := l.packages[importPath]; loaded {
+		return pkg
+	}
+	for idx, loading := range l.loading {
+		if loading == importPath {
+			cycle := append(append([]string{}, l.loading[idx:]...), importPath)
+			l.report(fmt.Sprintf("import cycle is not allowed: %s", strings.Join(cycle, " -> ")), "", literal.Span)
+			return nil
+		}
+	}
+
+	dir := filepath.Join(l.opts.Root, filepath.FromSlash(importPath))
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+SourceExtension))
+	if len(files) == 0 {
+		l.report(fmt.Sprintf("cannot find package %s in %s", literal.Value, dir), "", literal.Span)
+		return nil
+	}
+
+	l.loading = append(l.loading, importPath)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	pkg := &Package{Name: path.Base(importPath), Path: importPath, Program: &ast.Program{}}
+	failed := false
+	for _, file := range files {
+		program := l.parseFile(pkg, file)
+		if program == nil {
+			failed = true
+			continue
+		}
+		pkg.Program.Imports = append(pkg.Program.Imports, program.Imports...)
+		pkg.Program.Statements = append(pkg.Program.Statements, program.Statements...)
+	}
+	if failed || !l.check(pkg, nil) {
+		pkg = nil
+	}
+	l.packages[importPath] = pkg
+	return pkg
+}
+
+// parseFile parses the file of the imported package, the file must declare the package and contain only declarations
+func (l *packageLoader) parseFile(pkg *Package, file string) *ast.Program {
+	source, err := os.ReadFile(file)
+	if err != nil {
+		l.diagnostics = append(l.diagnostics, &errors.SyntaxError{Message: err.Error()})
+		return nil
+	}
+	l.opts.logf("Scanning and parsing %s", file)
+	program, diagnostics := parse(file, string(source))
+	if len(diagnostics) > 0 {
+		l.diagnostics = append(l.diagnostics, withFilename(diagnostics, file)...)
+		return nil
+	}
+
+	if program.Package == nil {
+		l.diagnostics = append(l.diagnostics, &errors.SemanticError{
+			Message:  fmt.Sprintf("file of package '%s' must declare the package", pkg.Name),
+			HelpMsg:  fmt.Sprintf("add 'package %s' at the beginning of the file", pkg.Name),
+			Position: token.TokenPosition{Row: 1, Column: 1, Filename: file},
+		})
+		return nil
+	}
+	if name := program.Package.Name; name.Value != pkg.Name {
+		l.report(fmt.Sprintf("file declares package '%s', but the directory %s contains package '%s'", name.Value, pkg.Path, pkg.Name), "", name.Span)
+		return nil
+	}
+
+	valid := true
+	for _, statement := range program.Statements {
+		switch statement.(type) {
+		case *ast.FuncDeclarationStatement, *ast.ConstDeclarationStatement, *ast.TypeDeclarationStatement,
+			*ast.StructDeclarationStatement, *ast.EnumDeclarationStatement, *ast.TraitDeclarationStatement, *ast.ImplStatement:
+		default:
+			l.report(fmt.Sprintf("package '%s' can contain only declarations at the top level", pkg.Name), "move the statement into a function", statement.GetSpan())
+			valid = false
+		}
+	}
+	if !valid {
+		return nil
+	}
+	return program
+}
+
+// check loads the packages imported by the program and checks it in the new package scope,
+// the imported packages are bound to their names in the scope. It returns false if the package has errors.
+func (l *packageLoader) check(pkg *Package, symbols *env.Env) bool {
+	if symbols == nil {
+		symbols = env.NewEnv()
+	}
+	pkg.Env = symbols
+
+	valid := true
+	for _, imports := range pkg.Program.Imports {
+		for _, literal := range imports.Paths {
+			imported := l.load(literal)
+			if imported == nil {
+				valid = false
+				continue
+			}
+			inserted := symbols.InsertSymbol(&env.EnvSymbolEntity{
+				Name:       imported.Name,
+				Type:       &env.ChlangPackageType{Name: imported.Name, Path: imported.Path, Env: imported.Env},
+				EntityType: env.SymbolEntityPackage,
+				Span:       literal.Span,
+			})
+			if !inserted {
+				l.report(fmt.Sprintf("package '%s' is already imported", imported.Name), "", literal.Span)
+				valid = false
+				continue
+			}
+			pkg.Imports = append(pkg.Imports, imported)
+		}
+	}
+	if !valid {
+		return false
+	}
+
+	program, diagnostics := check(pkg.Program, symbols, l.opts)
+	if len(diagnostics) > 0 {
+		l.diagnostics = append(l.diagnostics, diagnostics...)
+		return false
+	}
+	pkg.Program = program
+	l.order = append(l.order, pkg)
+	return true
+}
+
+func (l *packageLoader) report(message, help string, span *token.Span) {
+	diagnostic := &errors.SemanticError{Message: message, HelpMsg: help, Span: span}
+	if span != nil {
+		diagnostic.Position = span.Start
+	}
+	l.diagnostics = append(l.diagnostics, diagnostic)
+}
+
+// importPathOf returns the import path without the quotes of the string literal
+func importPathOf(literal *ast.StringLiteral) string {
+	return literal.Value[1 : len(literal.Value)-1]
+}
+
+func withFilename(diagnostics []errors.CompilerError, filename string) []errors.CompilerError {
+	for _, diagnostic := range diagnostics {
+		setDiagnosticFilename(diagnostic, filename)
+	}
+	return diagnostics
+}
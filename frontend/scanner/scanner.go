@@ -3,23 +3,27 @@ package scanner
 
 import (
 	"fmt"
-	"log"
-	"math"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/usein-abilev/chlang/frontend/errors"
 	"github.com/usein-abilev/chlang/frontend/token"
 )
 
 type Scanner struct {
+	filename string // reported in the positions of the tokens
+
 	input  string // input source code
+	source string // the whole source code, differs from the input for the embedded expressions of string templates
 	row    int    // row number
 	column int    // column number
 
 	char     rune // current character
 	charSize int  // size of the current character
 	offset   int  // offset of the current character (including current character)
+
+	errors []error // errors found during scanning
 }
 
 const (
@@ -33,6 +37,7 @@ func New(input string) (*Scanner, error) {
 
 	scanner := &Scanner{
 		input:  input,
+		source: input,
 		offset: 0,
 		row:    1,
 		column: 0,
@@ -43,6 +48,16 @@ func New(input string) (*Scanner, error) {
 	return scanner, nil
 }
 
+// NewFile creates the scanner of the source file, the positions of the produced tokens refer to the file
+func NewFile(filename, input string) (*Scanner, error) {
+	scanner, err := New(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	scanner.filename = filename
+	return scanner, nil
+}
+
 // Scan scans the source code and returns the next token
 func (s *Scanner) Scan() token.Token {
 	if s.offset >= len(s.input) {
@@ -145,6 +160,9 @@ func (s *Scanner) Scan() token.Token {
 	case ',':
 		s.next()
 		return s.produceToken(token.COMMA, ",")
+	case '?':
+		s.next()
+		return s.produceToken(token.QUESTION, "?")
 	case '(':
 		s.next()
 		return s.produceToken(token.LEFT_PAREN, "(")
@@ -237,6 +255,9 @@ func (s *Scanner) Scan() token.Token {
 		case '=':
 			s.next()
 			return s.produceToken(token.PLUS_ASSIGN, "+=")
+		case '+':
+			s.next()
+			return s.produceToken(token.INCREMENT, "++")
 		default:
 			return s.produceToken(token.PLUS, "+")
 		}
@@ -249,6 +270,9 @@ func (s *Scanner) Scan() token.Token {
 		case '>':
 			s.next()
 			return s.produceToken(token.ARROW, "->")
+		case '-':
+			s.next()
+			return s.produceToken(token.DECREMENT, "--")
 		default:
 			return s.produceToken(token.MINUS, "-")
 		}
@@ -288,8 +312,14 @@ func (s *Scanner) Scan() token.Token {
 	return s.produceToken(token.ILLEGAL, string(s.char))
 }
 
+// Errors returns the errors found during scanning.
+// The scanner produces an ILLEGAL token for each of them.
+func (s *Scanner) Errors() []error {
+	return s.errors
+}
+
 func (s *Scanner) GetLineByPosition(pos token.TokenPosition) string {
-	lines := strings.Split(s.input, "\n")
+	lines := strings.Split(s.source, "\n")
 	if pos.Row < 1 || pos.Row > len(lines) {
 		return ""
 	}
@@ -305,29 +335,130 @@ func (s *Scanner) scanIdentifier() token.Token {
 	return s.produceToken(token.LookupKeyword(literal), literal)
 }
 
+// Sub returns the scanner of the expression embedded into the string template,
+// positions of the produced tokens refer to the original source code
+func (s *Scanner) Sub(part token.StringPart) *Scanner {
+	sub := &Scanner{
+		filename: s.filename,
+		input:    s.input[:part.End],
+		source:   s.source,
+		offset:   part.Offset,
+		row:      part.Position.Row,
+		column:   part.Position.Column - 1,
+	}
+	sub.next()
+	return sub
+}
+
+// scanString scans the string literal. The string containing '$name' or '${expr}' is the string template,
+// it is split into the text parts and the sources of the embedded expressions. '\$' escapes the dollar sign.
 func (s *Scanner) scanString() token.Token {
 	start := s.offset
 	row, column := s.row, s.column
 	quote := s.char
 	s.next()
 
+	template := false
+	parts := make([]token.StringPart, 0)
+	text := ""
+	textStart := s.offset
 	for s.char != quote {
-		if s.char == endOfFile {
+		if s.offset >= len(s.input) {
 			s.fatal("Unterminated string")
+			return s.produceToken(token.ILLEGAL, s.input[start:])
+		}
+		if s.char == '\\' && s.peek() == '$' {
+			template = true
+			text += s.input[textStart:s.offset]
+			s.next()
+			textStart = s.offset // the dollar sign is a part of the text
+			s.next()
+			continue
 		}
 		if s.char == '\\' {
 			s.next()
+		} else if s.char == '$' && (s.peek() == '{' || unicode.IsLetter(s.peek()) || s.peek() == '_') {
+			template = true
+			parts = append(parts, token.StringPart{Text: text + s.input[textStart:s.offset]})
+			position := s.position(s.row, s.column)
+			part, ok := s.scanTemplateExpression()
+			if !ok {
+				s.fatalAt(position, "Unterminated expression of the string template")
+				return s.produceToken(token.ILLEGAL, s.input[start:])
+			}
+			parts = append(parts, part)
+			text = ""
+			textStart = s.offset
+			continue
 		}
 		s.next()
 	}
+	text += s.input[textStart:s.offset]
 
 	s.next()
 	literal := s.input[start:s.offset]
-	return token.Token{
+	stringToken := token.Token{
 		Literal:  literal,
 		Type:     token.STRING_LITERAL,
-		Position: token.TokenPosition{Row: row, Column: column},
+		Position: s.position(row, column),
+	}
+	if template {
+		stringToken.Type = token.STRING_TEMPLATE
+		stringToken.Metadata = &token.TokenMetadata{StringParts: append(parts, token.StringPart{Text: text})}
+	}
+	return stringToken
+}
+
+// scanTemplateExpression scans the expression embedded into the string template: '$name' or '${expr}'.
+// It returns false if the closing brace of the expression is missing.
+func (s *Scanner) scanTemplateExpression() (token.StringPart, bool) {
+	s.next() // skip '$'
+	braced := s.char == '{'
+	if braced {
+		s.next()
+	}
+	part := token.StringPart{
+		Expression: true,
+		Offset:     s.offset,
+		Position:   s.position(s.row, s.column),
 	}
+
+	if !braced {
+		for isIdentPart(s.char) && s.char != '$' && s.next() != endOfFile {
+		}
+		part.End = s.offset
+		return part, true
+	}
+
+	depth := 0
+	for s.char != '}' || depth > 0 {
+		if s.offset >= len(s.input) || s.char == '\n' {
+			return part, false
+		}
+		switch s.char {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"', '\'':
+			// string literal inside the expression: "${join(", ", items)}"
+			quote := s.char
+			s.next()
+			for s.char != quote {
+				if s.offset >= len(s.input) {
+					return part, false
+				}
+				if s.char == '\\' {
+					s.next()
+				}
+				s.next()
+			}
+		}
+		s.next()
+	}
+	part.End = s.offset
+	s.next() // skip '}'
+	return part, true
 }
 
 func (s *Scanner) scanNumber() token.Token {
@@ -393,7 +524,9 @@ func (s *Scanner) scanNumber() token.Token {
 		} else if !unicode.IsDigit(rune(s.char)) || s.char == endOfFile {
 			break
 		}
-		s.next()
+		if s.next() == endOfFile {
+			break
+		}
 	}
 
 	literal := strings.ReplaceAll(s.input[start:s.offset], "_", "")
@@ -436,6 +569,7 @@ func (s *Scanner) next() rune {
 	s.offset += s.charSize
 	s.column++
 	if s.offset >= len(s.input) {
+		s.char = endOfFile
 		return endOfFile
 	}
 
@@ -467,30 +601,31 @@ func (s *Scanner) produceIntegerToken(value string, base int) token.Token {
 		Metadata: &token.TokenMetadata{
 			IntegerBase: base,
 		},
-		Position: token.TokenPosition{
-			Row:    s.row,
-			Column: s.column - len(value),
-		},
+		Position: s.position(s.row, s.column-len(value)),
 	}
 }
 func (s *Scanner) produceToken(t token.TokenType, literal string) token.Token {
 	return token.Token{
-		Type:    t,
-		Literal: literal,
-		Position: token.TokenPosition{
-			Row:    s.row,
-			Column: s.column - len(literal),
-		},
+		Type:     t,
+		Literal:  literal,
+		Position: s.position(s.row, s.column-len(literal)),
 	}
 }
 
 func (s *Scanner) fatal(msg string, args ...interface{}) {
-	minBound := int64(math.Max(float64(s.row-1), 0))
-	maxBound := int64(math.Min(float64(s.row+1), float64(len(strings.Split(s.input, "\n")))))
-	lines := strings.Join(strings.Split(s.input, "\n")[minBound:maxBound], "\n")
+	s.fatalAt(s.position(s.row, s.column), msg, args...)
+}
+
+func (s *Scanner) position(row, column int) token.TokenPosition {
+	return token.TokenPosition{Row: row, Column: column, Filename: s.filename}
+}
 
-	log.Printf("\033[31mFailed line: (pos:%d)\n===============================\n%s\n===============================\033[0m", s.offset, lines)
-	log.Fatalf(fmt.Sprintf("\033[31m[Scanner error]: %s at L%d:%d\n\033[0m", msg, s.row, s.column), args...)
+func (s *Scanner) fatalAt(position token.TokenPosition, msg string, args ...interface{}) {
+	s.errors = append(s.errors, &errors.SyntaxError{
+		Position:  position,
+		ErrorLine: s.GetLineByPosition(position),
+		Message:   fmt.Sprintf(msg, args...),
+	})
 }
 
 func isIdentStart(ch rune) bool {
@@ -11,6 +11,7 @@ const (
 	COMMENT            // // or /* */
 	FLOAT_LITERAL      // 123.45
 	STRING_LITERAL     // "hello"
+	STRING_TEMPLATE    // "x = $x, sum = ${a + b}"
 	IDENTIFIER         // variable_name
 	PLUS               // +
 	MINUS              // -
@@ -44,6 +45,8 @@ const (
 	AMPERSAND_ASSIGN   // &=
 	PIPE_ASSIGN        // |=
 	CARET_ASSIGN       // ^=
+	INCREMENT          // ++
+	DECREMENT          // --
 	ARROW              // ->
 	LEFT_PAREN         // (
 	RIGHT_PAREN        // )
@@ -58,10 +61,12 @@ const (
 	ELLIPSIS           // ... (spread)
 	COLON              // :
 	SEMICOLON          // ;
+	QUESTION           // ? (error propagation)
 
 	// Keywords
 	VAR
 	STRUCT
+	ENUM
 	CONST
 	TYPE
 	TRAIT
@@ -71,12 +76,18 @@ const (
 	RETURN
 	IF
 	ELSE
+	WHEN
 	FOR
+	WHILE
+	LOOP
 	BREAK
 	CONTINUE
 	IN
 	TRUE
 	FALSE
+	PACKAGE
+	IMPORT
+	PUB // exports the declaration from the package
 )
 
 type TokenType int
@@ -102,6 +113,19 @@ type Token struct {
 type TokenMetadata struct {
 	// The number base for parsing, like: 16, 10, 8, 2
 	IntegerBase int
+
+	// The literal text and the embedded expressions of the string template
+	StringParts []StringPart
+}
+
+// StringPart is a part of the string template: the text between the embedded expressions (without quotes)
+// or the source of the expression '$name' or '${expr}'
+type StringPart struct {
+	Text       string
+	Expression bool
+	Offset     int           // offset of the expression source in the input
+	End        int           // end offset of the expression source in the input
+	Position   TokenPosition // position of the expression source
 }
 
 type Span struct {
@@ -122,6 +146,7 @@ var tokenSymbolNames = map[TokenType]string{
 	INT_LITERAL:      "integer",
 	FLOAT_LITERAL:    "float",
 	STRING_LITERAL:   "string",
+	STRING_TEMPLATE:  "string template",
 	IDENTIFIER:       "identifier",
 	ASSIGN:           "=",
 	PLUS:             "+",
@@ -150,6 +175,8 @@ var tokenSymbolNames = map[TokenType]string{
 	EXPONENT_ASSIGN:  "**=",
 	PIPE_ASSIGN:      "|=",
 	CARET_ASSIGN:     "^=",
+	INCREMENT:        "++",
+	DECREMENT:        "--",
 	ARROW:            "->", // function return type arrow
 	LEFT_PAREN:       "(",
 	RIGHT_PAREN:      ")",
@@ -164,9 +191,11 @@ var tokenSymbolNames = map[TokenType]string{
 	ELLIPSIS:         "...",
 	COLON:            ":",
 	SEMICOLON:        ";",
+	QUESTION:         "?",
 
 	VAR:      "let",
 	STRUCT:   "struct",
+	ENUM:     "enum",
 	CONST:    "const",
 	TYPE:     "type",
 	TRAIT:    "trait",
@@ -176,12 +205,18 @@ var tokenSymbolNames = map[TokenType]string{
 	RETURN:   "return",
 	IF:       "if",
 	ELSE:     "else",
+	WHEN:     "when",
 	FOR:      "for",
+	WHILE:    "while",
+	LOOP:     "loop",
 	BREAK:    "break",
 	CONTINUE: "continue",
 	IN:       "in",
 	TRUE:     "true",
 	FALSE:    "false",
+	PACKAGE:  "package",
+	IMPORT:   "import",
+	PUB:      "pub",
 }
 
 // Precedence levels for operators
@@ -285,6 +320,7 @@ var identTokens = map[string]TokenType{
 	"impl":     IMPL,
 	"by":       BY,
 	"struct":   STRUCT,
+	"enum":     ENUM,
 	"fn":       FUNCTION,
 	"return":   RETURN,
 	"if":       IF,
@@ -292,9 +328,16 @@ var identTokens = map[string]TokenType{
 	"continue": CONTINUE,
 	"in":       IN,
 	"else":     ELSE,
+	"when":     WHEN,
+	"match":    WHEN, // 'match x { ... }' is the same as 'when x { ... }'
 	"for":      FOR,
+	"while":    WHILE,
+	"loop":     LOOP,
 	"true":     TRUE,
 	"false":    FALSE,
+	"package":  PACKAGE,
+	"import":   IMPORT,
+	"pub":      PUB,
 }
 
 func (t Token) String() string {
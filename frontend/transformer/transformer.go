@@ -2,7 +2,6 @@
 package transformer
 
 import (
-	"fmt"
 	"math"
 	"strconv"
 
@@ -11,7 +10,6 @@ import (
 )
 
 func Transform(program *ast.Program) *ast.Program {
-	fmt.Printf("Transforming AST\n")
 	for idx, statement := range program.Statements {
 		program.Statements[idx] = evaluateConstant(statement)
 	}
@@ -63,12 +61,18 @@ func evaluateConstant(rawNode ast.Node) ast.Node {
 						Base:  10,
 					}
 				case token.SLASH:
+					if rightInt == 0 {
+						break // the division by zero is reported at runtime
+					}
 					return &ast.IntLiteral{
 						Span:  node.Span,
 						Value: strconv.FormatInt(leftInt/rightInt, 10),
 						Base:  10,
 					}
 				case token.PERCENT:
+					if rightInt == 0 {
+						break // the division by zero is reported at runtime
+					}
 					return &ast.IntLiteral{
 						Span:  node.Span,
 						Value: strconv.FormatInt(leftInt%rightInt, 10),
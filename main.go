@@ -1,17 +1,129 @@
+// The chlang command is the driver for the Chlang compiler and virtual machine.
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"os"
+)
 
-	"github.com/usein-abilev/chlang/frontend"
-	"github.com/usein-abilev/chlang/targets/vm"
+// Exit codes of the driver. Syntax, semantic and runtime failures are
+// distinguished, so scripts can tell which stage rejected the program.
+const (
+	exitOK            = 0
+	exitFailure       = 1 // I/O errors, unsupported operations
+	exitUsage         = 2 // invalid command line
+	exitSyntaxError   = 3
+	exitSemanticError = 4
+	exitRuntimeError  = 5
+	exitInternalError = 6 // the compiler crashed on a program accepted by the checker
 )
 
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "run", usage: "run [flags] <file.chl|file.chbc>", summary: "compile and execute a source or bytecode file", run: runCommand},
+		{name: "check", usage: "check [flags] <file.chl>", summary: "parse and type-check a source file", run: checkCommand},
+		{name: "build", usage: "build [flags] -o <out.chbc> <file.chl>", summary: "compile a source file to bytecode", run: buildCommand},
+		{name: "repl", usage: "repl [flags]", summary: "start an interactive session", run: replCommand},
+		{name: "disasm", usage: "disasm [flags] <file.chl|file.chbc>", summary: "print the compiled bytecode of a source or bytecode file", run: disasmCommand},
+		{name: "help", usage: "help [command]", summary: "show help for a command", run: helpCommand},
+	}
+}
+
 func main() {
-	program := frontend.Build("./examples/astdebug/basic.chl")
-	codegen := vm.NewRVMGenerator(program)
-	module := codegen.Generate()
-	machine := vm.NewVM(module, &vm.VMOptions{Debug: false})
-	fmt.Printf("\n======== Program output ========\n\n")
-	machine.Run()
+	if len(os.Args) < 2 {
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	name := os.Args[1]
+	if name == "-h" || name == "--help" || name == "-help" {
+		printUsage(os.Stdout)
+		os.Exit(exitOK)
+	}
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "chlang: unknown command '%s'\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: chlang <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nUse 'chlang help <command>' for more information about a command.\n")
+}
+
+func helpCommand(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "chlang: unknown command '%s'\n", args[0])
+		return exitUsage
+	}
+	if cmd.name == "help" {
+		fmt.Printf("Usage: chlang %s\n", cmd.usage)
+		return exitOK
+	}
+	// parsing '-h' prints the command usage with all its flags
+	cmd.run([]string{"-h"})
+	return exitOK
+}
+
+// newFlagSet creates a flag set for the command that reports errors instead of exiting
+func newFlagSet(cmd string) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	flags.Usage = func() {
+		usage := cmd
+		if c := lookupCommand(cmd); c != nil {
+			usage = c.usage
+		}
+		fmt.Fprintf(flags.Output(), "Usage: chlang %s\n", usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses command arguments and returns the single source file argument.
+// The returned exit code is non-zero if the command line is invalid.
+func parseFlags(flags *flag.FlagSet, args []string) (string, int) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return "", exitOK
+		}
+		return "", exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "chlang %s: expected exactly one source file\n", flags.Name())
+		flags.Usage()
+		return "", exitUsage
+	}
+	return flags.Arg(0), exitOK
 }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/usein-abilev/chlang/frontend"
+	"github.com/usein-abilev/chlang/frontend/ast"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
+	"github.com/usein-abilev/chlang/frontend/scanner"
+	"github.com/usein-abilev/chlang/targets/vm"
+)
+
+const (
+	replPrompt         = ">>> "
+	replContinuePrompt = "... "
+	replFilename       = "repl"
+)
+
+const replHelp = `Enter statements or expressions, the value of a bare expression is printed.
+Input continues on the next line while braces are unbalanced.
+
+Commands:
+  :type <expr>      print the type of the expression
+  :ast <expr>       print the AST of the expression
+  :bytecode <expr>  print the bytecode of the expression
+  :help             show this help
+  :quit             exit the REPL
+`
+
+// replSession keeps the state shared between the entered lines:
+// the checker environment, the generated module and the VM executing it
+type replSession struct {
+	env     *env.Env
+	codegen *vm.RVMGenerator
+	machine *vm.VM
+	out     io.Writer
+}
+
+func replCommand(args []string) int {
+	flags := newFlagSet("repl")
+	debug := flags.Bool("debug", false, "print VM call frames and the stack after each line")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	session := newReplSession(&vm.VMOptions{Debug: *debug}, os.Stdout)
+	fmt.Fprintln(os.Stdout, "Chlang REPL, type :help for help")
+
+	input := bufio.NewScanner(os.Stdin)
+	for {
+		source, ok := readReplInput(input, os.Stdout)
+		if !ok {
+			fmt.Fprintln(os.Stdout)
+			return exitOK
+		}
+		if strings.TrimSpace(source) == ":quit" || strings.TrimSpace(source) == ":q" {
+			return exitOK
+		}
+		session.eval(source)
+	}
+}
+
+func newReplSession(opts *vm.VMOptions, out io.Writer) *replSession {
+	symbols := env.NewEnv()
+	codegen := vm.NewRVMGenerator(&ast.Program{})
+	return &replSession{
+		env:     symbols,
+		codegen: codegen,
+		machine: vm.NewVM(codegen.Generate(), opts),
+		out:     out,
+	}
+}
+
+// readReplInput reads a line from the input, and continues reading while braces are unbalanced.
+// The code inspected by meta-commands like ':type f(1,' is continued too.
+func readReplInput(input *bufio.Scanner, out io.Writer) (string, bool) {
+	fmt.Fprint(out, replPrompt)
+	var lines []string
+	for input.Scan() {
+		lines = append(lines, input.Text())
+		source := strings.Join(lines, "\n")
+		if bracketDepth(source) <= 0 {
+			return source, true
+		}
+		fmt.Fprint(out, replContinuePrompt)
+	}
+	return strings.Join(lines, "\n"), len(lines) > 0
+}
+
+// bracketDepth returns the number of unclosed brackets in the source, ignoring strings and comments
+func bracketDepth(source string) int {
+	depth := 0
+	lexer, err := scanner.New(source)
+	if err != nil {
+		return 0
+	}
+	for tok := lexer.Scan(); ; tok = lexer.Scan() {
+		switch tok.Literal {
+		case "{", "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		}
+		if tok.Literal == "" || len(lexer.Errors()) > 0 {
+			break
+		}
+	}
+	return depth
+}
+
+func (s *replSession) eval(source string) {
+	command, argument, _ := strings.Cut(strings.TrimSpace(source), " ")
+	switch command {
+	case "":
+		return
+	case ":help":
+		fmt.Fprint(s.out, replHelp)
+		return
+	case ":ast":
+		s.printAST(argument)
+		return
+	case ":type":
+		// the inspected code is never run, so its declarations are discarded
+		program, envCheckpoint := s.compile(argument)
+		if program == nil {
+			return
+		}
+		s.env.Restore(envCheckpoint)
+		if stmt := lastExpressionStatement(program); stmt != nil {
+			fmt.Fprintf(s.out, "%s\n", stmt.Type)
+		}
+		return
+	case ":bytecode":
+		program, envCheckpoint := s.compile(argument)
+		if program == nil {
+			return
+		}
+		s.env.Restore(envCheckpoint)
+		checkpoint := s.codegen.Checkpoint()
+		_, start, err := s.codegen.Append(program)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return
+		}
+		s.codegen.Module().PrintInstructions(start)
+		s.codegen.Restore(checkpoint)
+		return
+	}
+	if strings.HasPrefix(command, ":") {
+		fmt.Fprintf(os.Stderr, "unknown command '%s', type :help for help\n", command)
+		return
+	}
+
+	program, envCheckpoint := s.compile(source)
+	if program == nil {
+		return
+	}
+	checkpoint := s.codegen.Checkpoint()
+	result, _, err := s.codegen.Append(program)
+	if err != nil {
+		s.env.Restore(envCheckpoint)
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return
+	}
+	if !s.run() {
+		// the declarations of the failed line are discarded, so they can't refer to the registers it didn't set
+		s.env.Restore(envCheckpoint)
+		s.codegen.Restore(checkpoint)
+		s.machine.Unwind()
+		return
+	}
+
+	stmt := lastExpressionStatement(program)
+	if stmt == nil || result < 0 || stmt.Type == env.SymbolTypeVoid {
+		return
+	}
+	// assignments don't produce a value, the result register is the assigned location
+	if _, ok := stmt.Expression.(*ast.AssignExpression); ok {
+		return
+	}
+	fmt.Fprintf(s.out, "%s : %s\n", s.machine.Register(result), stmt.Type)
+}
+
+// compile checks the source against the session environment and returns the checkpoint of the environment before it.
+// If the source has errors, its declarations are removed from the environment, so the line can be entered again.
+func (s *replSession) compile(source string) (*ast.Program, env.ScopeCheckpoint) {
+	checkpoint := s.env.Checkpoint()
+	program, diagnostics := frontend.Compile(source, &frontend.Options{
+		Filename: replFilename,
+		Env:      s.env,
+		Warnings: os.Stderr,
+	})
+	if len(diagnostics) > 0 {
+		// also leaves the nested scope the checker may have failed in
+		s.env.Restore(checkpoint)
+	}
+	for _, diagnostic := range diagnostics {
+		diagnostic.Write(os.Stderr)
+	}
+	return program, checkpoint
+}
+
+// run executes the code appended to the module, runtime failures don't terminate the session
+func (s *replSession) run() (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mruntime error:\033[0m %v\n", r)
+			s.machine.Unwind()
+			ok = false
+		}
+	}()
+	s.machine.Run()
+	return true
+}
+
+func (s *replSession) printAST(source string) {
+	lexer, err := scanner.New(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return
+	}
+	program, errors := ast.Init(lexer).Parse()
+	for _, err := range *errors {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+	if len(*errors) > 0 {
+		return
+	}
+	ast.PrintAST(program)
+}
+
+func lastExpressionStatement(program *ast.Program) *ast.ExpressionStatement {
+	if len(program.Statements) == 0 {
+		return nil
+	}
+	stmt, _ := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	return stmt
+}
@@ -0,0 +1,72 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/usein-abilev/chlang/frontend"
+)
+
+const benchFibonacciIterative = `
+fn fibonacci_iterative(n: i32) -> i32 {
+    let a = 0
+    let b = 1
+    for i in 0..(n) {
+        let temp = a
+        a = b
+        b = temp + b
+    }
+    return a
+}
+for i in 0..1000 {
+    fibonacci_iterative(40)
+}
+`
+
+const benchFibonacciRecursive = `
+fn fibonacci(n: i32) -> i32 {
+    if n <= 1 {
+        return n
+    }
+    return fibonacci(n - 1) + fibonacci(n - 2)
+}
+fibonacci(20)
+`
+
+const benchArraySum = `
+let arr = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]
+let sum = 0
+for i in 0..5000 {
+    for j in 0..10 {
+        sum += arr[j] * 2
+    }
+}
+`
+
+func compileBenchmark(b *testing.B, source string) *FunctionObject {
+	b.Helper()
+	program, diagnostics := frontend.Compile(source, &frontend.Options{Filename: b.Name()})
+	if len(diagnostics) > 0 {
+		b.Fatalf("compile: %s", diagnostics[0].Error())
+	}
+	return NewRVMGenerator(program).Generate()
+}
+
+func runBenchmark(b *testing.B, source string) {
+	module := compileBenchmark(b, source)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewVM(module, &VMOptions{}).Run()
+	}
+}
+
+func BenchmarkFibonacciIterative(b *testing.B) {
+	runBenchmark(b, benchFibonacciIterative)
+}
+
+func BenchmarkFibonacciRecursive(b *testing.B) {
+	runBenchmark(b, benchFibonacciRecursive)
+}
+
+func BenchmarkArraySum(b *testing.B) {
+	runBenchmark(b, benchArraySum)
+}
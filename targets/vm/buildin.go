@@ -3,13 +3,49 @@ package vm
 import (
 	"fmt"
 	"strconv"
+	"strings"
+
+	"github.com/usein-abilev/chlang/frontend/checker/env"
 )
 
-var BuildInFunctions = map[string]func([]*OperandValue){
-	"println": buildInPrintln,
+// Build-in functions get the arguments registers and return the result value, or nil if there is no result.
+// Methods of Option and Result are named 'Option.unwrap', the receiver is the first argument.
+var BuildInFunctions = map[string]func([]*OperandValue) *OperandValue{
+	"println":          buildInPrintln,
+	"Option.unwrap":    buildInUnwrap,
+	"Option.unwrap_or": buildInUnwrapOr,
+	"Option.is_some":   buildInIsVariant(0),
+	"Option.is_none":   buildInIsVariant(1),
+	"Result.unwrap":    buildInUnwrap,
+	"Result.unwrap_or": buildInUnwrapOr,
+	"Result.is_ok":     buildInIsVariant(0),
+	"Result.is_err":    buildInIsVariant(1),
+}
+
+// Some and Ok are the first variants of Option and Result (tag 0), None and Err are the second ones
+func buildInUnwrap(args []*OperandValue) *OperandValue {
+	object := args[0].Value.(*EnumObject)
+	if object.Tag != 0 {
+		panic(fmt.Sprintf("vm: called 'unwrap' on %s", stringifyOperandValue(args[0])))
+	}
+	return &object.Payload[0]
+}
+
+func buildInUnwrapOr(args []*OperandValue) *OperandValue {
+	object := args[0].Value.(*EnumObject)
+	if object.Tag != 0 {
+		return args[1]
+	}
+	return &object.Payload[0]
+}
+
+func buildInIsVariant(tag int) func([]*OperandValue) *OperandValue {
+	return func(args []*OperandValue) *OperandValue {
+		return &OperandValue{Kind: OperandTypeBool, Value: args[0].Value.(*EnumObject).Tag == tag}
+	}
 }
 
-func buildInPrintln(args []*OperandValue) {
+func buildInPrintln(args []*OperandValue) *OperandValue {
 	str := ""
 	for idx, arg := range args {
 		if arg == nil {
@@ -21,6 +57,11 @@ func buildInPrintln(args []*OperandValue) {
 		str += stringifyOperandValue(arg)
 	}
 	fmt.Printf("%s\n", str)
+	return nil
+}
+
+func (operand *OperandValue) String() string {
+	return stringifyOperandValue(operand)
 }
 
 func stringifyOperandValue(operand *OperandValue) string {
@@ -30,12 +71,14 @@ func stringifyOperandValue(operand *OperandValue) string {
 	switch operand.Kind {
 	case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64:
 		return fmt.Sprintf("%v", operand.Value)
+	case OperandTypeUint64:
+		return strconv.FormatUint(uint64(operand.Value.(int64)), 10)
 	case OperandTypeFloat32, OperandTypeFloat64:
 		return fmt.Sprintf("%v", operand.Value)
 	case OperandTypeBool:
 		return fmt.Sprintf("%v", operand.Value)
 	case OperandTypeString:
-		return parseStringLiteral(operand.Value.(string))
+		return operand.Value.(string)
 	case OperandTypeArray:
 		arr := operand.Value.([]OperandValue)
 		str := "["
@@ -47,7 +90,35 @@ func stringifyOperandValue(operand *OperandValue) string {
 		}
 		str += "]"
 		return str
-	case OperandTypeFunctionObject:
+	case OperandTypeStruct:
+		object := operand.Value.(*StructObject)
+		str := object.Name + " {"
+		for idx, name := range object.FieldNames {
+			if idx > 0 {
+				str += ","
+			}
+			str += " " + name + ": " + stringifyOperandValue(&object.Fields[idx])
+		}
+		str += " }"
+		return str
+	case OperandTypeEnum:
+		object := operand.Value.(*EnumObject)
+		str := object.Name + "." + object.Variant
+		if builtin := env.BuiltinVariantEnum(object.Variant); builtin != nil && builtin.Name == object.Name {
+			str = object.Variant // Some(1), None, Ok(1), Err(message)
+		}
+		if len(object.Payload) > 0 {
+			str += "("
+			for idx := range object.Payload {
+				if idx > 0 {
+					str += ", "
+				}
+				str += stringifyOperandValue(&object.Payload[idx])
+			}
+			str += ")"
+		}
+		return str
+	case OperandTypeFunctionObject, OperandTypeClosure:
 		return "function"
 	case OperandTypeBuildInFunction:
 		return "build-in-function"
@@ -55,10 +126,34 @@ func stringifyOperandValue(operand *OperandValue) string {
 	return "undefined"
 }
 
-func parseStringLiteral(value string) string {
-	value, err := strconv.Unquote(value)
+// parseStringLiteral returns the value of the quoted string literal, the single quoted literal is converted
+// to the double quoted one first: 'say "hi"' -> "say \"hi\""
+func parseStringLiteral(literal string) string {
+	quoted := literal
+	if strings.HasPrefix(literal, "'") && len(literal) >= 2 {
+		body := literal[1 : len(literal)-1]
+		var builder strings.Builder
+		builder.WriteByte('"')
+		for i := 0; i < len(body); i++ {
+			switch {
+			case body[i] == '\\' && i+1 < len(body):
+				if body[i+1] != '\'' {
+					builder.WriteByte('\\')
+				}
+				builder.WriteByte(body[i+1])
+				i++
+			case body[i] == '"':
+				builder.WriteString(`\"`)
+			default:
+				builder.WriteByte(body[i])
+			}
+		}
+		builder.WriteByte('"')
+		quoted = builder.String()
+	}
+	value, err := strconv.Unquote(quoted)
 	if err != nil {
-		panic(fmt.Sprintf("parseStringLiteral: invalid string literal: %v", value))
+		panic(fmt.Sprintf("parseStringLiteral: invalid string literal: %v", literal))
 	}
 	return value
 }
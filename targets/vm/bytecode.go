@@ -0,0 +1,465 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Layout of a compiled module (.chbc file). All integers are little-endian.
+//
+//	header:       magic "CHBC", version u16
+//	functions:    count u32, then every function object of the module (the module itself is the first one)
+//	function:     name str, parent i32 (-1 for the module), locals, upvalues, constants, instructions
+//	locals:       count u32, then name str, depth u32, temp u8
+//	upvalues:     count u32, then name str, local u8, index u32
+//	constants:    count u32, then name str, value
+//	value:        kind u8 followed by the payload of the kind (see writeValue)
+//	instructions: count u32, then every instruction word as u64 (see VMInstruction)
+//	str:          length u32 followed by the bytes
+//
+// Functions are referenced by their index in the function table, so recursive and nested functions are stored once.
+const (
+	BytecodeMagic     = "CHBC"
+	BytecodeVersion   = 10
+	BytecodeExtension = ".chbc"
+)
+
+// WriteTo serializes the module and all functions referenced by its constants in the bytecode format.
+func (fn *FunctionObject) WriteTo(w io.Writer) (int64, error) {
+	bw := &bytecodeWriter{w: bufio.NewWriter(w), index: map[*FunctionObject]int{}}
+	bw.collectFunctions(fn)
+
+	bw.bytes([]byte(BytecodeMagic))
+	bw.u16(BytecodeVersion)
+	bw.u32(uint32(len(bw.functions)))
+	for _, function := range bw.functions {
+		bw.function(function)
+	}
+	if bw.err == nil {
+		bw.err = bw.w.Flush()
+	}
+	return bw.n, bw.err
+}
+
+// LoadModule reads a module written by (*FunctionObject).WriteTo
+func LoadModule(r io.Reader) (*FunctionObject, error) {
+	br := &bytecodeReader{r: bufio.NewReader(r)}
+	magic := br.bytes(len(BytecodeMagic))
+	if br.err != nil || string(magic) != BytecodeMagic {
+		return nil, fmt.Errorf("invalid bytecode file: bad magic header")
+	}
+	if version := br.u16(); br.err == nil && version != BytecodeVersion {
+		return nil, fmt.Errorf("unsupported bytecode version %d (expected %d)", version, BytecodeVersion)
+	}
+
+	count := br.count()
+	if br.err != nil {
+		return nil, br.error()
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("invalid bytecode file: module has no functions")
+	}
+
+	// function objects are allocated on the first reference, so constants can reference functions declared later
+	br.functions = make([]*FunctionObject, count)
+	for i := range br.functions {
+		br.function(br.functionAt(uint32(i)))
+		if br.err != nil {
+			return nil, br.error()
+		}
+	}
+	return br.functions[0], nil
+}
+
+type bytecodeWriter struct {
+	w         *bufio.Writer
+	n         int64
+	err       error
+	functions []*FunctionObject
+	index     map[*FunctionObject]int
+}
+
+// collectFunctions numbers the function objects reachable from the constants of fn
+func (bw *bytecodeWriter) collectFunctions(fn *FunctionObject) {
+	if _, ok := bw.index[fn]; ok {
+		return
+	}
+	bw.index[fn] = len(bw.functions)
+	bw.functions = append(bw.functions, fn)
+	for _, constant := range fn.constants {
+		bw.collectValueFunctions(constant.Value)
+	}
+}
+
+func (bw *bytecodeWriter) collectValueFunctions(value *OperandValue) {
+	switch value.Kind {
+	case OperandTypeFunctionObject:
+		bw.collectFunctions(value.Value.(*FunctionObject))
+	case OperandTypeArray:
+		for i := range value.Value.([]OperandValue) {
+			bw.collectValueFunctions(&value.Value.([]OperandValue)[i])
+		}
+	case OperandTypeStruct:
+		object := value.Value.(*StructObject)
+		for i := range object.Fields {
+			bw.collectValueFunctions(&object.Fields[i])
+		}
+		for _, method := range object.Methods {
+			bw.collectFunctions(method)
+		}
+	case OperandTypeEnum:
+		object := value.Value.(*EnumObject)
+		for i := range object.Payload {
+			bw.collectValueFunctions(&object.Payload[i])
+		}
+	}
+}
+
+func (bw *bytecodeWriter) function(fn *FunctionObject) {
+	bw.str(fn.name)
+	parent := int32(-1)
+	if idx, ok := bw.index[fn.parent]; ok && fn.parent != nil {
+		parent = int32(idx)
+	}
+	bw.u32(uint32(parent))
+
+	bw.u32(uint32(len(fn.locals)))
+	for _, local := range fn.locals {
+		bw.str(local.name)
+		bw.u32(uint32(local.depth))
+		bw.bool(local.temp)
+	}
+
+	bw.u32(uint32(len(fn.upvalues)))
+	for _, upvalue := range fn.upvalues {
+		bw.str(upvalue.name)
+		bw.bool(upvalue.local)
+		bw.u32(uint32(upvalue.index))
+	}
+
+	bw.u32(uint32(len(fn.constants)))
+	for _, constant := range fn.constants {
+		bw.str(constant.Name)
+		bw.value(constant.Value)
+	}
+
+	bw.u32(uint32(len(fn.instructions)))
+	for _, instruction := range fn.instructions {
+		bw.u64(uint64(instruction))
+	}
+}
+
+// value writes the kind of the value and its payload:
+// integers as i64, floats as f64 bits, bool as u8, strings and build-in function names as str,
+// arrays as count u32 followed by the values, functions as index u32 in the function table,
+// structs as name str and fields count u32 followed by the field names str and values,
+// then methods count u32 followed by the method names str and function indexes u32,
+// enums as enum name str, variant name str, tag u32 and payload count u32 followed by the values
+func (bw *bytecodeWriter) value(value *OperandValue) {
+	bw.u8(uint8(value.Kind))
+	switch value.Kind {
+	case OperandTypeUndefined:
+	case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64, OperandTypeUint64:
+		bw.u64(uint64(value.Value.(int64)))
+	case OperandTypeFloat32, OperandTypeFloat64:
+		bw.u64(math.Float64bits(value.Value.(float64)))
+	case OperandTypeBool:
+		bw.bool(value.Value.(bool))
+	case OperandTypeString, OperandTypeBuildInFunction:
+		bw.str(value.Value.(string))
+	case OperandTypeArray:
+		items := value.Value.([]OperandValue)
+		bw.u32(uint32(len(items)))
+		for i := range items {
+			bw.value(&items[i])
+		}
+	case OperandTypeStruct:
+		object := value.Value.(*StructObject)
+		bw.str(object.Name)
+		bw.u32(uint32(len(object.Fields)))
+		for i := range object.Fields {
+			bw.str(object.FieldNames[i])
+			bw.value(&object.Fields[i])
+		}
+		names := make([]string, 0, len(object.Methods))
+		for name := range object.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names) // keep the output deterministic
+		bw.u32(uint32(len(names)))
+		for _, name := range names {
+			bw.str(name)
+			bw.u32(uint32(bw.index[object.Methods[name]]))
+		}
+	case OperandTypeEnum:
+		object := value.Value.(*EnumObject)
+		bw.str(object.Name)
+		bw.str(object.Variant)
+		bw.u32(uint32(object.Tag))
+		bw.u32(uint32(len(object.Payload)))
+		for i := range object.Payload {
+			bw.value(&object.Payload[i])
+		}
+	case OperandTypeFunctionObject:
+		bw.u32(uint32(bw.index[value.Value.(*FunctionObject)]))
+	default:
+		bw.fail(fmt.Errorf("unsupported constant kind '%s'", value.Kind))
+	}
+}
+
+func (bw *bytecodeWriter) fail(err error) {
+	if bw.err == nil {
+		bw.err = err
+	}
+}
+
+func (bw *bytecodeWriter) bytes(b []byte) {
+	if bw.err != nil {
+		return
+	}
+	n, err := bw.w.Write(b)
+	bw.n += int64(n)
+	bw.err = err
+}
+
+func (bw *bytecodeWriter) u8(v uint8) {
+	bw.bytes([]byte{v})
+}
+
+func (bw *bytecodeWriter) bool(v bool) {
+	if v {
+		bw.u8(1)
+	} else {
+		bw.u8(0)
+	}
+}
+
+func (bw *bytecodeWriter) u16(v uint16) {
+	bw.bytes(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (bw *bytecodeWriter) u32(v uint32) {
+	bw.bytes(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (bw *bytecodeWriter) u64(v uint64) {
+	bw.bytes(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func (bw *bytecodeWriter) str(s string) {
+	bw.u32(uint32(len(s)))
+	bw.bytes([]byte(s))
+}
+
+type bytecodeReader struct {
+	r         *bufio.Reader
+	err       error
+	functions []*FunctionObject
+}
+
+// error returns the read error, a truncated file is reported as invalid
+func (br *bytecodeReader) error() error {
+	if br.err == io.EOF || br.err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("invalid bytecode file: unexpected end of file")
+	}
+	return br.err
+}
+
+func (br *bytecodeReader) fail(format string, args ...any) {
+	if br.err == nil {
+		br.err = fmt.Errorf("invalid bytecode file: "+format, args...)
+	}
+}
+
+func (br *bytecodeReader) function(fn *FunctionObject) {
+	fn.name = br.str()
+	if parent := int32(br.u32()); parent >= 0 {
+		fn.parent = br.functionAt(uint32(parent))
+	}
+
+	fn.locals = make([]LocalRegister, 0, br.count())
+	for i := 0; i < cap(fn.locals) && br.err == nil; i++ {
+		fn.locals = append(fn.locals, LocalRegister{
+			name:    br.str(),
+			depth:   int(br.u32()),
+			temp:    br.bool(),
+			address: RegisterAddress(i),
+		})
+	}
+
+	fn.upvalues = make([]UpvalueInfo, 0, br.count())
+	for i := 0; i < cap(fn.upvalues) && br.err == nil; i++ {
+		fn.upvalues = append(fn.upvalues, UpvalueInfo{
+			name:  br.str(),
+			local: br.bool(),
+			index: int(br.u32()),
+		})
+	}
+
+	fn.constants = make([]ConstantValue, 0, br.count())
+	for i := 0; i < cap(fn.constants) && br.err == nil; i++ {
+		name := br.str()
+		fn.constants = append(fn.constants, ConstantValue{Name: name, Value: br.value()})
+	}
+
+	fn.instructions = make([]VMInstruction, 0, br.count())
+	for i := 0; i < cap(fn.instructions) && br.err == nil; i++ {
+		instruction := VMInstruction(br.u64())
+		if _, ok := opcodeNames[instruction.Opcode()]; !ok {
+			br.fail("unknown opcode %d in function '%s'", instruction.Opcode(), fn.name)
+			return
+		}
+		if instruction.Opcode().format() == formatAConst && instruction.Bx() >= len(fn.constants) {
+			br.fail("constant index %d out of range in function '%s'", instruction.Bx(), fn.name)
+			return
+		}
+		if instruction.Opcode() == OpcodeNewStruct && fn.constants[instruction.Bx()].Value.Kind != OperandTypeStruct {
+			br.fail("NewStruct operand const#%d is not a struct in function '%s'", instruction.Bx(), fn.name)
+			return
+		}
+		if instruction.Opcode() == OpcodeNewEnum && fn.constants[instruction.Bx()].Value.Kind != OperandTypeEnum {
+			br.fail("NewEnum operand const#%d is not an enum variant in function '%s'", instruction.Bx(), fn.name)
+			return
+		}
+		if instruction.Opcode() == OpcodeClosure && fn.constants[instruction.Bx()].Value.Kind != OperandTypeFunctionObject {
+			br.fail("Closure operand const#%d is not a function in function '%s'", instruction.Bx(), fn.name)
+			return
+		}
+		if instruction.Opcode().format() == formatUpval && instruction.B() >= len(fn.upvalues) {
+			br.fail("upvalue index %d out of range in function '%s'", instruction.B(), fn.name)
+			return
+		}
+		if instruction.Opcode() == OpcodeGetMethod &&
+			(instruction.C() >= len(fn.constants) || fn.constants[instruction.C()].Value.Kind != OperandTypeString) {
+			br.fail("GetMethod operand const#%d is not a method name in function '%s'", instruction.C(), fn.name)
+			return
+		}
+		fn.instructions = append(fn.instructions, instruction)
+	}
+}
+
+func (br *bytecodeReader) value() *OperandValue {
+	value := &OperandValue{Kind: OperandValueType(br.u8())}
+	switch value.Kind {
+	case OperandTypeUndefined:
+	case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64, OperandTypeUint64:
+		value.Value = int64(br.u64())
+	case OperandTypeFloat32, OperandTypeFloat64:
+		value.Value = math.Float64frombits(br.u64())
+	case OperandTypeBool:
+		value.Value = br.bool()
+	case OperandTypeString:
+		value.Value = br.str()
+	case OperandTypeBuildInFunction:
+		name := br.str()
+		if _, ok := BuildInFunctions[name]; !ok && br.err == nil {
+			br.fail("unknown build-in function '%s'", name)
+		}
+		value.Value = name
+	case OperandTypeArray:
+		items := make([]OperandValue, 0, br.count())
+		for i := 0; i < cap(items) && br.err == nil; i++ {
+			items = append(items, *br.value())
+		}
+		value.Value = items
+	case OperandTypeStruct:
+		object := &StructObject{Name: br.str()}
+		count := br.count()
+		for i := 0; i < count && br.err == nil; i++ {
+			object.FieldNames = append(object.FieldNames, br.str())
+			object.Fields = append(object.Fields, *br.value())
+		}
+		if count := br.count(); count > 0 {
+			object.Methods = make(map[string]*FunctionObject, count)
+			for i := 0; i < count && br.err == nil; i++ {
+				name := br.str()
+				object.Methods[name] = br.functionAt(br.u32())
+			}
+		}
+		value.Value = object
+	case OperandTypeEnum:
+		object := &EnumObject{Name: br.str(), Variant: br.str(), Tag: int(br.u32())}
+		count := br.count()
+		for i := 0; i < count && br.err == nil; i++ {
+			object.Payload = append(object.Payload, *br.value())
+		}
+		value.Value = object
+	case OperandTypeFunctionObject:
+		value.Value = br.functionAt(br.u32())
+	default:
+		br.fail("unknown constant kind %d", value.Kind)
+	}
+	return value
+}
+
+func (br *bytecodeReader) functionAt(idx uint32) *FunctionObject {
+	if idx >= uint32(len(br.functions)) {
+		br.fail("function index %d out of range", idx)
+		return nil
+	}
+	if br.functions[idx] == nil {
+		br.functions[idx] = &FunctionObject{}
+	}
+	return br.functions[idx]
+}
+
+func (br *bytecodeReader) bytes(n int) []byte {
+	if br.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, br.err = io.ReadFull(br.r, b)
+	return b
+}
+
+// count reads a length prefix, guarding the allocations from corrupted files
+func (br *bytecodeReader) count() int {
+	n := br.u32()
+	if n > maxBytecodeCount {
+		br.fail("length %d is too large", n)
+		return 0
+	}
+	return int(n)
+}
+
+const maxBytecodeCount = 1 << 24
+
+func (br *bytecodeReader) u8() uint8 {
+	if b := br.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (br *bytecodeReader) bool() bool {
+	return br.u8() != 0
+}
+
+func (br *bytecodeReader) u16() uint16 {
+	if b := br.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (br *bytecodeReader) u32() uint32 {
+	if b := br.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (br *bytecodeReader) u64() uint64 {
+	if b := br.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (br *bytecodeReader) str() string {
+	return string(br.bytes(br.count()))
+}
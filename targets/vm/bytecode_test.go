@@ -0,0 +1,201 @@
+package vm
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/usein-abilev/chlang/frontend"
+)
+
+var roundTripTests = []struct {
+	name   string
+	source string
+	output string
+}{
+	{
+		name: "closures",
+		source: `
+fn make_adder(k: i32) -> fn(i32) -> i32 {
+    let total = 0
+    return fn(x: i32) -> i32 {
+        total += x
+        return total + k
+    }
+}
+let adder = make_adder(10)
+adder(1)
+println(adder(2))
+`,
+		output: "13\n",
+	},
+	{
+		name: "struct methods",
+		source: `
+struct Counter { count: i32 }
+
+impl Counter {
+    fn add(self, n: i32) -> i32 {
+        self.count += n
+        return self.count
+    }
+}
+
+let counter = Counter { count: 1 }
+counter.add(2)
+println(counter.add(3), counter.count)
+`,
+		output: "6 6\n",
+	},
+	{
+		name: "enums",
+		source: `
+enum Shape { Circle(f64), Square(f64), Empty }
+
+fn area(shape: Shape) -> f64 {
+    return when shape {
+        Shape.Circle(r) -> 3.0 * r * r,
+        Shape.Square(s) -> s * s,
+        Shape.Empty -> 0.0,
+    }
+}
+println(area(Shape.Circle(2.0)), area(Shape.Square(3.0)), area(Shape.Empty))
+`,
+		output: "12 9 0\n",
+	},
+	{
+		name: "constant arrays",
+		source: `
+const SCALE = 2
+let limits = [1, 2, 3]
+let sum = 0
+for limit in limits {
+    sum += limit * SCALE
+}
+println(sum, limits[2])
+`,
+		output: "12 3\n",
+	},
+}
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	for _, test := range roundTripTests {
+		t.Run(test.name, func(t *testing.T) {
+			program, diagnostics := frontend.Compile(test.source, &frontend.Options{Filename: test.name})
+			if len(diagnostics) > 0 {
+				t.Fatalf("compile: %s", diagnostics[0].Error())
+			}
+			module := NewRVMGenerator(program).Generate()
+			loaded, encoded := roundTrip(t, module)
+
+			// the loaded module is written back byte for byte, so every serialized field survived the round trip
+			var reencoded bytes.Buffer
+			if _, err := loaded.WriteTo(&reencoded); err != nil {
+				t.Fatalf("write loaded module: %s", err)
+			}
+			if offset := firstDifference(encoded, reencoded.Bytes()); offset >= 0 {
+				t.Fatalf("loaded module is encoded differently at byte %d (%d bytes, want %d)", offset, reencoded.Len(), len(encoded))
+			}
+
+			if output := runModule(t, module); output != test.output {
+				t.Fatalf("compiled module printed %q, want %q", output, test.output)
+			}
+			if output := runModule(t, loaded); output != test.output {
+				t.Fatalf("loaded module printed %q, want %q", output, test.output)
+			}
+		})
+	}
+}
+
+// The code generator doesn't emit array constants, the module is built by hand
+func TestBytecodeRoundTripArrayConstant(t *testing.T) {
+	array := &OperandValue{Kind: OperandTypeArray, Value: []OperandValue{
+		{Kind: OperandTypeInt32, Value: int64(-1)},
+		{Kind: OperandTypeUint64, Value: int64(-1)},
+		{Kind: OperandTypeFloat64, Value: 2.5},
+		{Kind: OperandTypeString, Value: "text"},
+		{Kind: OperandTypeArray, Value: []OperandValue{{Kind: OperandTypeBool, Value: true}}},
+	}}
+	module := &FunctionObject{name: "main", constants: []ConstantValue{{Name: "values", Value: array}}}
+
+	loaded, _ := roundTrip(t, module)
+	if loaded.name != module.name || len(loaded.constants) != 1 {
+		t.Fatalf("loaded module '%s' has %d constants, want '%s' with 1", loaded.name, len(loaded.constants), module.name)
+	}
+	constant := loaded.constants[0]
+	if constant.Name != "values" || !operandValuesEqual(constant.Value, array) {
+		t.Fatalf("loaded constant %s = %s, want values = %s", constant.Name, constant.Value, array)
+	}
+}
+
+func TestLoadModuleInvalid(t *testing.T) {
+	files := map[string]string{
+		"empty":          "",
+		"bad magic":      "CHBX\x0a\x00",
+		"no functions":   "CHBC\x0a\x00\x00\x00\x00\x00",
+		"huge count":     "CHBC\x0a\x00\xff\xff\xff\xff",
+		"truncated body": "CHBC\x0a\x00\x01\x00\x00\x01",
+	}
+	for name, file := range files {
+		if _, err := LoadModule(bytes.NewReader([]byte(file))); err == nil {
+			t.Errorf("%s: LoadModule succeeded, want an error", name)
+		}
+	}
+}
+
+// roundTrip writes the module and loads it back, it returns the loaded module and the encoded bytes
+func roundTrip(t *testing.T, module *FunctionObject) (*FunctionObject, []byte) {
+	t.Helper()
+	var buffer bytes.Buffer
+	n, err := module.WriteTo(&buffer)
+	if err != nil {
+		t.Fatalf("write module: %s", err)
+	}
+	if n != int64(buffer.Len()) {
+		t.Fatalf("WriteTo reported %d bytes, but wrote %d", n, buffer.Len())
+	}
+	encoded := bytes.Clone(buffer.Bytes())
+	loaded, err := LoadModule(&buffer)
+	if err != nil {
+		t.Fatalf("load module: %s", err)
+	}
+	return loaded, encoded
+}
+
+// runModule runs the module in a new VM and returns what the program printed to stdout
+func runModule(t *testing.T, module *FunctionObject) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %s", err)
+	}
+	defer reader.Close()
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	func() {
+		defer func() { os.Stdout = stdout }()
+		NewVM(module, &VMOptions{}).Run()
+	}()
+	writer.Close()
+	return <-output
+}
+
+// firstDifference returns the offset of the first byte that differs in a and b, or -1 if they are equal
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
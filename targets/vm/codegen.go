@@ -1,14 +1,23 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/usein-abilev/chlang/frontend/ast"
-	"github.com/usein-abilev/chlang/frontend/ast/symbols"
+	"github.com/usein-abilev/chlang/frontend/checker/env"
 	"github.com/usein-abilev/chlang/frontend/token"
 )
 
+// Generic function declaration and the function declaring it, the instantiations are stored in its constants
+type genericFunction struct {
+	decl   *ast.FuncDeclarationStatement
+	parent *FunctionObject
+}
+
 type ForLoopContext struct {
 	conditionAddress  int
 	endBranches       []int // jump instructions to the end of loop
@@ -26,6 +35,27 @@ type RVMGenerator struct {
 	function   *FunctionObject
 	forContext *ForLoopContext
 
+	// struct constants (field names and zero values) copied by NewStruct instruction
+	structPrototypes map[*env.ChlangStructType]*OperandValue
+
+	// enum variant constants (tag and names) copied by NewEnum instruction
+	enumPrototypes map[*env.ChlangEnumVariant]*OperandValue
+
+	// compiled default methods of traits, they are added to method tables of structs implementing the trait
+	traitMethods map[*env.EnvSymbolEntity]*FunctionObject
+
+	// generic functions are compiled for every list of type arguments they are called with
+	generics map[*env.EnvSymbolEntity]*genericFunction
+
+	// function objects of the declarations, they are added to the constants before any body is compiled
+	functions map[*ast.FuncDeclarationStatement]*FunctionObject
+
+	// type arguments of the generic function instantiation being compiled
+	typeBindings map[*env.ChlangTypeParam]env.ChlangType
+
+	// number of values returned by the function being compiled
+	results int
+
 	lastBlockExpressionRegister RegisterAddress
 }
 
@@ -42,9 +72,7 @@ var mappedBinaryOperatorsToOpcodes = map[token.TokenType]Opcode{
 	token.LESS:           OpcodeLt,
 	token.LESS_EQUALS:    OpcodeLte,
 	token.NOT_EQUALS:     OpcodeNeq,
-	token.AND:            OpcodeAnd,
 	token.AMPERSAND:      OpcodeAnd,
-	token.OR:             OpcodeOr,
 	token.PIPE:           OpcodeOr,
 	token.CARET:          OpcodeXor,
 	token.LEFT_SHIFT:     OpcodeShl,
@@ -74,8 +102,13 @@ func NewRVMGenerator(program *ast.Program) *RVMGenerator {
 		constants:    []ConstantValue{},
 	}
 
-	// add build-in functions
+	// add build-in functions, sorted to keep the constant indices and the compiled modules reproducible
+	names := make([]string, 0, len(BuildInFunctions))
 	for name := range BuildInFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
 		moduleFunction.addConstant(name, &OperandValue{
 			Kind:  OperandTypeBuildInFunction,
 			Value: name,
@@ -83,19 +116,124 @@ func NewRVMGenerator(program *ast.Program) *RVMGenerator {
 	}
 
 	return &RVMGenerator{
-		program:  program,
-		function: moduleFunction,
+		program:          program,
+		function:         moduleFunction,
+		structPrototypes: make(map[*env.ChlangStructType]*OperandValue),
+		enumPrototypes:   make(map[*env.ChlangEnumVariant]*OperandValue),
+		traitMethods:     make(map[*env.EnvSymbolEntity]*FunctionObject),
+		generics:         make(map[*env.EnvSymbolEntity]*genericFunction),
+		functions:        make(map[*ast.FuncDeclarationStatement]*FunctionObject),
 	}
 }
 
 func (g *RVMGenerator) Generate() *FunctionObject {
+	g.declareFunctions(g.program.Statements)
 	for _, statement := range g.program.Statements {
 		g.emitStatement(statement)
 	}
-	g.function.Print()
 	return g.function
 }
 
+// Link makes the package generated by another generator accessible to the program by the package name.
+// It must be called before the program is generated. The module function of the package is stored as a constant
+// of the program module and the exported functions and constants are resolved in it: geometry.area, math.PI.
+// The struct, enum and trait tables are shared, so the values of the package types have the same methods in the program.
+func (g *RVMGenerator) Link(name string, pkg *RVMGenerator) {
+	module := pkg.Module()
+	module.name = fmt.Sprintf("<package %s>", name)
+	g.Module().addConstant(name, &OperandValue{
+		Kind:  OperandTypeFunctionObject,
+		Value: module,
+	})
+	for structType, prototype := range pkg.structPrototypes {
+		g.structPrototypes[structType] = prototype
+	}
+	for variant, prototype := range pkg.enumPrototypes {
+		g.enumPrototypes[variant] = prototype
+	}
+	for symbol, method := range pkg.traitMethods {
+		g.traitMethods[symbol] = method
+	}
+	for symbol, generic := range pkg.generics {
+		g.generics[symbol] = generic
+	}
+}
+
+// packageMember returns the constant exported by the linked package: the function 'geometry.area' or the constant 'math.PI'
+func (g *RVMGenerator) packageMember(expr *ast.MemberExpression) *OperandValue {
+	pkg := expr.Left.(*ast.Identifier).Value
+	module := g.function.lookupConstant(pkg)
+	if module == nil || module.Kind != OperandTypeFunctionObject {
+		panic(fmt.Sprintf("error: package '%s' is not linked", pkg))
+	}
+	for _, constant := range module.Value.(*FunctionObject).constants {
+		if constant.Name == expr.Member.Value {
+			return constant.Value
+		}
+	}
+	panic(fmt.Sprintf("error: unresolved symbol '%s.%s'", pkg, expr.Member.Value))
+}
+
+// GeneratorCheckpoint is a snapshot of the module function state.
+// Restoring a checkpoint discards the code compiled after it was taken.
+type GeneratorCheckpoint struct {
+	instructions int
+	locals       int
+	constants    int
+}
+
+func (g *RVMGenerator) Checkpoint() GeneratorCheckpoint {
+	module := g.Module()
+	return GeneratorCheckpoint{
+		instructions: len(module.instructions),
+		locals:       len(module.locals),
+		constants:    len(module.constants),
+	}
+}
+
+func (g *RVMGenerator) Restore(checkpoint GeneratorCheckpoint) {
+	module := g.Module()
+	module.instructions = module.instructions[:checkpoint.instructions]
+	module.locals = module.locals[:checkpoint.locals]
+	module.constants = module.constants[:checkpoint.constants]
+	module.scopeDepth = 0
+	module.scopes = nil
+	g.function = module
+	g.forContext = nil
+	g.results = 0
+}
+
+// Append compiles the program at the end of the module function generated before.
+// Locals and constants of the previously compiled programs remain visible, so code can be compiled incrementally (REPL).
+// It returns the register holding the value of the last expression statement (or -1) and the first appended instruction.
+// If the compilation fails, the module is restored to its previous state.
+func (g *RVMGenerator) Append(program *ast.Program) (result RegisterAddress, start int, err error) {
+	checkpoint := g.Checkpoint()
+	defer func() {
+		if r := recover(); r != nil {
+			g.Restore(checkpoint)
+			// the panics of the generator are prefixed for the uncaught case
+			err = errors.New(strings.TrimPrefix(fmt.Sprint(r), "error: "))
+		}
+	}()
+
+	g.lastBlockExpressionRegister = -1
+	g.declareFunctions(program.Statements)
+	for _, statement := range program.Statements {
+		g.emitStatement(statement)
+	}
+	return g.lastBlockExpressionRegister, checkpoint.instructions, nil
+}
+
+// Module returns the root function object of the generator
+func (g *RVMGenerator) Module() *FunctionObject {
+	module := g.function
+	for module.parent != nil {
+		module = module.parent
+	}
+	return module
+}
+
 func (g *RVMGenerator) emitStatement(statement ast.Statement) {
 	switch statement := statement.(type) {
 	case *ast.ConstDeclarationStatement:
@@ -103,17 +241,29 @@ func (g *RVMGenerator) emitStatement(statement ast.Statement) {
 		g.function.addConstant(statement.Name.Value, value)
 	case *ast.VarDeclarationStatement:
 		g.visitVarDeclaration(statement)
-	case *ast.TypeDeclarationStatement:
+	case *ast.TupleDeclarationStatement:
+		g.visitTupleDeclaration(statement)
+	case *ast.TypeDeclarationStatement, *ast.StructDeclarationStatement, *ast.EnumDeclarationStatement:
 		return // ignore type declarations
 	case *ast.FuncDeclarationStatement:
-		g.visitFuncDeclaration(statement)
+		if isGenericFunction(statement) {
+			break // compiled for the type arguments of the calls
+		}
+		g.visitFuncDeclaration(statement.Signature.Name.Value, statement)
+	case *ast.TraitDeclarationStatement:
+		for _, method := range statement.MethodDeclarations {
+			name := methodName(statement.Name.Value, method.Signature.Name.Value)
+			g.traitMethods[method.Symbol.(*env.EnvSymbolEntity)] = g.visitFuncDeclaration(name, method)
+		}
+	case *ast.ImplStatement:
+		g.visitImplStatement(statement)
 	case *ast.ForRangeStatement:
 		g.function.enterScope()
 
 		// prologue
 		loopVar := g.function.addLocal(statement.Identifier.Value)
 		startReg := g.emitExpressionAligned(statement.Range.Start)
-		g.function.emit(OpcodeMove, loopVar, startReg)
+		g.function.emitABC(OpcodeMove, loopVar, int(startReg), 0)
 
 		// condition
 		endReg := g.emitExpression(statement.Range.End)
@@ -122,67 +272,87 @@ func (g *RVMGenerator) emitStatement(statement ast.Statement) {
 		condReg := g.function.addTemp()
 		var conditionAddress int
 		if statement.Range.Inclusive {
-			conditionAddress = g.function.emit(OpcodeLte, condReg, loopVar, endReg)
+			conditionAddress = g.function.emitABC(OpcodeLte, condReg, int(loopVar), int(endReg))
 		} else {
-			conditionAddress = g.function.emit(OpcodeLt, condReg, loopVar, endReg)
+			conditionAddress = g.function.emitABC(OpcodeLt, condReg, int(loopVar), int(endReg))
 		}
-		falseBranch := g.function.emit(OpcodeJumpIf)
+		falseBranch := g.function.emitPlaceholder(OpcodeJumpIf)
 		g.function.popTempRegister() // free condition register
 
-		g.forContext = &ForLoopContext{
-			conditionAddress:  conditionAddress,
-			endBranches:       []int{},
-			conditionBranches: []int{},
-			parent:            g.forContext,
-		}
-
-		for _, statement := range statement.Body.Statements {
-			g.emitStatement(statement)
-		}
-
-		// incrementing for variable and jumping to condition
-		oneReg := g.function.addTemp()
-		g.function.popTempRegister() // TODO: Need to optimize these calls by merging 'addTemp' and 'popTempRegister' into one method
-		incrementAddr := g.function.emit(OpcodeLoadConst, oneReg, g.function.emitConstantValue(
-			&OperandValue{
-				Kind:  OperandTypeInt64,
-				Value: int64(1),
-			}),
-		)
-		g.function.emit(OpcodeAdd, loopVar, loopVar, oneReg)
-		g.function.emit(OpcodeJump, conditionAddress)
-
-		for _, instruction := range g.forContext.conditionBranches {
-			g.function.PatchInstruction(instruction, incrementAddr)
+		// the loop variable is incremented after every iteration
+		g.emitLoopBody(conditionAddress, statement.Body, func() {
+			oneReg := g.function.addTemp()
+			g.function.popTempRegister() // TODO: Need to optimize these calls by merging 'addTemp' and 'popTempRegister' into one method
+			g.function.emitABx(OpcodeLoadConst, oneReg, int(g.function.emitConstantValue(
+				&OperandValue{
+					Kind:  OperandTypeInt64,
+					Value: int64(1),
+				}),
+			))
+			g.function.emitABC(OpcodeAdd, loopVar, int(loopVar), int(oneReg))
+		}, loopVar)
+		g.function.PatchInstruction(falseBranch, newInstructionABx(OpcodeJumpIf, condReg, len(g.function.instructions)).WithK(false))
+		g.function.leaveScope()
+	case *ast.WhileStatement:
+		conditionAddress := len(g.function.instructions)
+		condReg := g.emitExpressionAligned(statement.Condition)
+		falseBranch := g.function.emitPlaceholder(OpcodeJumpIf)
+		g.emitLoopBody(conditionAddress, statement.Body, nil, RegisterAddress(len(g.function.locals)))
+		g.function.PatchInstruction(falseBranch, newInstructionABx(OpcodeJumpIf, condReg, len(g.function.instructions)).WithK(false))
+	case *ast.LoopStatement:
+		g.emitLoopBody(len(g.function.instructions), statement.Body, nil, RegisterAddress(len(g.function.locals)))
+	case *ast.ForStatement:
+		g.function.enterScope()
+		scopeStart := RegisterAddress(len(g.function.locals))
+		if statement.Init != nil {
+			g.emitStatement(statement.Init)
 		}
-
-		// patch all instructions that should jump to the end of loop
-		endLoopAddress := len(g.function.instructions)
-		g.function.PatchInstruction(falseBranch, condReg, false, endLoopAddress)
-		for _, instruction := range g.forContext.endBranches {
-			g.function.PatchInstruction(instruction, endLoopAddress)
+		conditionAddress := len(g.function.instructions)
+		if statement.Condition == nil {
+			g.emitLoopBody(conditionAddress, statement.Body, func() {
+				if statement.Post != nil {
+					g.emitStatement(statement.Post)
+				}
+			}, scopeStart)
+		} else {
+			condReg := g.emitExpressionAligned(statement.Condition)
+			falseBranch := g.function.emitPlaceholder(OpcodeJumpIf)
+			g.emitLoopBody(conditionAddress, statement.Body, func() {
+				if statement.Post != nil {
+					g.emitStatement(statement.Post)
+				}
+			}, scopeStart)
+			g.function.PatchInstruction(falseBranch, newInstructionABx(OpcodeJumpIf, condReg, len(g.function.instructions)).WithK(false))
 		}
-		g.forContext = g.forContext.parent
-
-		// g.function.Print()
 		g.function.leaveScope()
+	case *ast.IncDecStatement:
+		g.emitExpressionAligned(statement.Assign)
+	case *ast.ForInStatement:
+		g.visitForInStatement(statement)
 	case *ast.BreakStatement:
 		if g.forContext == nil {
 			panic("break statement outside of loop")
 		}
-		g.forContext.endBranches = append(g.forContext.endBranches, g.function.emit(OpcodeJump))
+		g.forContext.endBranches = append(g.forContext.endBranches, g.function.emitPlaceholder(OpcodeJump))
 	case *ast.ContinueStatement:
 		if g.forContext == nil {
 			panic("continue statement outside of loop")
 		}
-		g.forContext.conditionBranches = append(g.forContext.conditionBranches, g.function.emit(OpcodeJump))
+		g.forContext.conditionBranches = append(g.forContext.conditionBranches, g.function.emitPlaceholder(OpcodeJump))
 	case *ast.ReturnStatement:
+		if g.results > 1 {
+			first := g.emitValues(statement.Expression, g.results)
+			g.function.freeAllTempRegister()
+			g.function.emitABC(OpcodeReturn, first, g.results, 0)
+			break
+		}
 		returnRegister := g.emitExpressionAligned(statement.Expression)
-		g.function.emit(OpcodeReturn, returnRegister, 1)
+		g.function.emitABC(OpcodeReturn, returnRegister, 1, 0)
 	case *ast.ExpressionStatement:
 		g.lastBlockExpressionRegister = g.emitExpressionAligned(statement.Expression)
 	case *ast.BlockStatement:
 		g.function.enterScope()
+		g.declareFunctions(statement.Statements)
 		for _, statement := range statement.Statements {
 			g.emitStatement(statement)
 		}
@@ -192,6 +362,93 @@ func (g *RVMGenerator) emitStatement(statement ast.Statement) {
 	}
 }
 
+// visitForInStatement compiles the array iteration into the index loop:
+//
+//	array, length, counter = <iterable>, len(array), 0
+//	while counter < length { value = array[counter]; <body>; counter++ }
+func (g *RVMGenerator) visitForInStatement(statement *ast.ForInStatement) {
+	g.function.enterScope()
+
+	// prologue, the iterable is evaluated once
+	arrayReg := g.emitExpressionAligned(statement.Iterable)
+	if !g.function.bindLocal(arrayReg, "<for_in_array>") {
+		iterableReg := arrayReg
+		arrayReg = g.function.addLocal("<for_in_array>")
+		if arrayReg != iterableReg {
+			g.function.emitABC(OpcodeMove, arrayReg, int(iterableReg), 0)
+		}
+	}
+	lengthReg := g.function.addLocal("<for_in_length>")
+	g.function.emitABC(OpcodeArrayLen, lengthReg, int(arrayReg), 0)
+	counterReg := g.function.addLocal("<for_in_counter>")
+	g.function.emit(newInstructionASBx(OpcodeLoadImm32, counterReg, 0))
+	indexReg := RegisterAddress(-1)
+	if statement.Index != nil {
+		indexReg = g.function.addLocal(statement.Index.Value)
+	}
+	valueReg := g.function.addLocal(statement.Value.Value)
+
+	// condition
+	conditionAddress := len(g.function.instructions)
+	condReg := g.function.addTemp()
+	g.function.emitABC(OpcodeLt, condReg, int(counterReg), int(lengthReg))
+	falseBranch := g.function.emitPlaceholder(OpcodeJumpIf)
+	g.function.popTempRegister() // free condition register
+
+	// the loop variables are copied, so the body can't break the iteration by assigning them
+	g.function.emitABC(OpcodeArrayGet, valueReg, int(arrayReg), int(counterReg))
+	if statement.Index != nil {
+		g.function.emitABC(OpcodeMove, indexReg, int(counterReg), 0)
+	}
+
+	g.emitLoopBody(conditionAddress, statement.Body, func() {
+		oneReg := g.function.addTemp()
+		g.function.emit(newInstructionASBx(OpcodeLoadImm32, oneReg, 1))
+		g.function.emitABC(OpcodeAdd, counterReg, int(counterReg), int(oneReg))
+		g.function.popTempRegister()
+	}, arrayReg)
+	g.function.PatchInstruction(falseBranch, newInstructionABx(OpcodeJumpIf, condReg, len(g.function.instructions)).WithK(false))
+
+	g.function.leaveScope()
+}
+
+// emitLoopBody emits the loop body and the post code (if any) followed by the jump to the condition (or the body start).
+// 'continue' jumps to the post code, 'break' jumps to the end of loop.
+// If the closures created in the body captured the loop variables (registers starting from closeFrom),
+// the upvalues are closed at the end of every iteration, so each iteration has its own copy of the variables.
+func (g *RVMGenerator) emitLoopBody(conditionAddress int, body *ast.BlockStatement, post func(), closeFrom RegisterAddress) {
+	g.forContext = &ForLoopContext{
+		conditionAddress:  conditionAddress,
+		endBranches:       []int{},
+		conditionBranches: []int{},
+		parent:            g.forContext,
+	}
+
+	captures := g.function.captures
+	g.emitStatement(body)
+	continueAddress := len(g.function.instructions)
+	captured := g.function.captures != captures
+	if captured {
+		g.function.emitABC(OpcodeClose, closeFrom, 0, 0)
+	}
+	if post != nil {
+		post()
+	}
+	g.function.emitABx(OpcodeJump, 0, conditionAddress)
+
+	for _, instruction := range g.forContext.conditionBranches {
+		g.function.PatchInstruction(instruction, newInstructionABx(OpcodeJump, 0, continueAddress))
+	}
+	endLoopAddress := len(g.function.instructions)
+	if captured && len(g.forContext.endBranches) > 0 {
+		g.function.emitABC(OpcodeClose, closeFrom, 0, 0)
+	}
+	for _, instruction := range g.forContext.endBranches {
+		g.function.PatchInstruction(instruction, newInstructionABx(OpcodeJump, 0, endLoopAddress))
+	}
+	g.forContext = g.forContext.parent
+}
+
 func (g *RVMGenerator) visitVarDeclaration(decl *ast.VarDeclarationStatement) {
 	if decl.Value == nil {
 		g.function.addLocal(decl.Name.Value)
@@ -205,36 +462,201 @@ func (g *RVMGenerator) visitVarDeclaration(decl *ast.VarDeclarationStatement) {
 		// so binding register r(x) = r(y) will override each other and we lose access to variable 'y'
 		registerId := g.function.addLocal(decl.Name.Value)
 		if leftRegister != registerId {
-			g.function.emit(OpcodeMove, registerId, leftRegister)
+			g.function.emitABC(OpcodeMove, registerId, int(leftRegister), 0)
+		}
+	}
+}
+
+// visitTupleDeclaration binds the consecutive registers holding the values to the variables: let a, b = f()
+func (g *RVMGenerator) visitTupleDeclaration(decl *ast.TupleDeclarationStatement) {
+	first := g.emitValues(decl.Value, len(decl.Names))
+	for idx, name := range decl.Names {
+		register := first + RegisterAddress(idx)
+		if !g.function.bindLocal(register, name.Value) {
+			local := g.function.addLocal(name.Value)
+			g.function.emitABC(OpcodeMove, local, int(register), 0)
+		}
+	}
+}
+
+// methodName returns the name of the method function object, e.g. 'Point.len'
+func methodName(receiver, method string) string {
+	return receiver + "." + method
+}
+
+// visitImplStatement compiles the methods and fills the method table of the struct type,
+// methods inherited from the traits defaults are added to the table unless the struct declares its own
+func (g *RVMGenerator) visitImplStatement(impl *ast.ImplStatement) {
+	structType := impl.Type.(*env.ChlangStructType)
+	methods := g.structPrototype(structType).Value.(*StructObject).Methods
+	for _, method := range impl.Methods {
+		name := method.Signature.Name.Value
+		methods[name] = g.visitFuncDeclaration(methodName(structType.Name, name), method)
+	}
+	for _, trait := range structType.Traits {
+		for _, method := range trait.Methods {
+			if _, declared := structType.Methods[method.Name]; declared || !method.HasDefault {
+				continue
+			}
+			methods[method.Name] = g.traitMethods[method.Symbol]
 		}
 	}
 }
 
-func (g *RVMGenerator) visitFuncDeclaration(decl *ast.FuncDeclarationStatement) {
-	parentFunction := g.function
-	g.function = &FunctionObject{
-		name:         decl.Name.Value,
-		parent:       parentFunction,
+// declareFunctions adds the functions, methods and default trait methods declared by the statements
+// to the constants of the function being compiled. It runs before the statements are compiled,
+// so the bodies can call the functions declared after them, the order of the declarations doesn't matter.
+func (g *RVMGenerator) declareFunctions(statements []ast.Statement) {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *ast.FuncDeclarationStatement:
+			if isGenericFunction(statement) {
+				g.generics[statement.Symbol.(*env.EnvSymbolEntity)] = &genericFunction{decl: statement, parent: g.function}
+				continue
+			}
+			g.declareFunction(statement.Signature.Name.Value, statement)
+		case *ast.TraitDeclarationStatement:
+			for _, method := range statement.MethodDeclarations {
+				name := methodName(statement.Name.Value, method.Signature.Name.Value)
+				g.traitMethods[method.Symbol.(*env.EnvSymbolEntity)] = g.declareFunction(name, method)
+			}
+		case *ast.ImplStatement:
+			structType := statement.Type.(*env.ChlangStructType)
+			methods := g.structPrototype(structType).Value.(*StructObject).Methods
+			for _, method := range statement.Methods {
+				name := method.Signature.Name.Value
+				methods[name] = g.declareFunction(methodName(structType.Name, name), method)
+			}
+		}
+	}
+}
+
+// declareFunction stores the empty function object as a constant of the parent function with the given name,
+// its body is compiled by visitFuncDeclaration
+func (g *RVMGenerator) declareFunction(name string, decl *ast.FuncDeclarationStatement) *FunctionObject {
+	function := &FunctionObject{
+		name:         name,
+		parent:       g.function,
 		instructions: []VMInstruction{},
 		locals:       []LocalRegister{},
 		constants:    []ConstantValue{},
 		scopeDepth:   0,
 	}
-	parentFunction.addConstant(decl.Name.Value, &OperandValue{
+	g.function.addConstant(name, &OperandValue{
 		Kind:  OperandTypeFunctionObject,
-		Value: g.function,
+		Value: function,
 	})
+	g.functions[decl] = function
+	return function
+}
 
-	for _, argument := range decl.Params {
+// visitFuncDeclaration compiles the body of the function declared by declareFunctions
+func (g *RVMGenerator) visitFuncDeclaration(name string, decl *ast.FuncDeclarationStatement) *FunctionObject {
+	function, ok := g.functions[decl]
+	if !ok {
+		function = g.declareFunction(name, decl)
+	}
+	g.compileFunction(function, decl.Signature, decl.Body, decl.Symbol)
+	return function
+}
+
+// isGenericFunction returns true if the function declares type parameters
+func isGenericFunction(decl *ast.FuncDeclarationStatement) bool {
+	return len(decl.Symbol.(*env.EnvSymbolEntity).Type.(*env.ChlangFunctionType).TypeParams) > 0
+}
+
+// instantiateFunction compiles the generic function for the type arguments of the call once and returns the instantiation,
+// e.g. 'max<i32>' and 'max<f64>' are separate function objects
+func (g *RVMGenerator) instantiateFunction(symbol *env.EnvSymbolEntity, typeArgs []ast.NodeLiteralType) *OperandValue {
+	generic, ok := g.generics[symbol]
+	if !ok {
+		panic(fmt.Sprintf("error: unresolved generic function '%s'", symbol.Name))
+	}
+
+	// the generic function declared in the body of another instantiation sees its type arguments too
+	bindings := make(map[*env.ChlangTypeParam]env.ChlangType, len(g.typeBindings)+len(typeArgs))
+	for param, arg := range g.typeBindings {
+		bindings[param] = arg
+	}
+	names := make([]string, 0, len(typeArgs))
+	for idx, param := range symbol.Type.(*env.ChlangFunctionType).TypeParams {
+		arg := g.concreteType(typeArgs[idx].(env.ChlangType))
+		bindings[param] = arg
+		names = append(names, env.TypeArgName(arg))
+	}
+	name := fmt.Sprintf("%s<%s>", symbol.Name, strings.Join(names, ", "))
+	if instance := generic.parent.lookupConstant(name); instance != nil {
+		return instance
+	}
+
+	function := &FunctionObject{
+		name:         name,
+		parent:       generic.parent,
+		instructions: []VMInstruction{},
+		locals:       []LocalRegister{},
+		constants:    []ConstantValue{},
+		scopeDepth:   0,
+	}
+	// the instantiation is added before its body is compiled, so the recursive calls find it
+	instance := &OperandValue{
+		Kind:  OperandTypeFunctionObject,
+		Value: function,
+	}
+	generic.parent.addConstant(name, instance)
+	parentBindings := g.typeBindings
+	g.typeBindings = bindings
+	g.compileFunction(function, generic.decl.Signature, generic.decl.Body, generic.decl.Symbol)
+	g.typeBindings = parentBindings
+	return instance
+}
+
+// concreteType substitutes the type arguments of the generic function instantiation being compiled
+func (g *RVMGenerator) concreteType(t env.ChlangType) env.ChlangType {
+	return env.Substitute(t, g.typeBindings)
+}
+
+// emitClosure compiles the anonymous function and emits the creation of its closure,
+// the function object is stored as a constant of the enclosing function
+func (g *RVMGenerator) emitClosure(expr *ast.FunctionExpression) RegisterAddress {
+	function := &FunctionObject{
+		name:         fmt.Sprintf("<closure#%d>", len(g.function.constants)),
+		parent:       g.function,
+		instructions: []VMInstruction{},
+		locals:       []LocalRegister{},
+		constants:    []ConstantValue{},
+		scopeDepth:   0,
+	}
+	g.compileFunction(function, expr.Signature, expr.Body, expr.Symbol)
+	targetReg := g.function.addTemp()
+	g.function.emitABx(OpcodeClosure, targetReg, int(g.function.addConstant(function.name, &OperandValue{
+		Kind:  OperandTypeFunctionObject,
+		Value: function,
+	})))
+	return targetReg
+}
+
+// compileFunction emits the body of the function declared inside the function being compiled
+func (g *RVMGenerator) compileFunction(function *FunctionObject, signature *ast.FunctionSignature, body *ast.BlockStatement, symbol ast.NodeSymbolRef) {
+	parentFunction, parentResults, parentLoop, parentBlockRegister := g.function, g.results, g.forContext, g.lastBlockExpressionRegister
+	g.function = function
+	g.results = returnCount(symbol.(*env.EnvSymbolEntity).Type.(*env.ChlangFunctionType).Return)
+	g.forContext = nil
+
+	// the method receiver is passed in the first register
+	if signature.SelfArg != nil {
+		g.function.addLocal(signature.SelfArg.Name.Value)
+	}
+	for _, argument := range signature.Args {
 		g.function.addLocal(argument.Name.Value)
 	}
 
-	for _, bodyStatement := range decl.Body.Statements {
+	g.declareFunctions(body.Statements)
+	for _, bodyStatement := range body.Statements {
 		g.emitStatement(bodyStatement)
 	}
 
-	g.function.emit(OpcodeReturn, RegisterAddress(0), 0) // emit default return statement at the end to prevent missing return statement
-	g.function = parentFunction
+	g.function.emitABC(OpcodeReturn, 0, 0, 0) // emit default return statement at the end to prevent missing return statement
+	g.function, g.results, g.forContext, g.lastBlockExpressionRegister = parentFunction, parentResults, parentLoop, parentBlockRegister
 }
 
 func (g *RVMGenerator) emitExpressionAligned(expression ast.Expression) RegisterAddress {
@@ -247,73 +669,145 @@ func (g *RVMGenerator) emitExpression(expression ast.Expression) RegisterAddress
 	switch expr := expression.(type) {
 	case *ast.IfExpression:
 		condRegister := g.emitExpressionAligned(expr.Condition)
-		falseBranch := g.function.emit(OpcodeJumpIf)
+		falseBranch := g.function.emitPlaceholder(OpcodeJumpIf)
 		resultRegister := g.function.addTemp()
 
 		g.lastBlockExpressionRegister = -1
 		g.emitStatement(expr.ThenBlock)
 		if g.lastBlockExpressionRegister != -1 {
-			g.function.emit(OpcodeMove, resultRegister, g.lastBlockExpressionRegister)
+			g.function.emitABC(OpcodeMove, resultRegister, int(g.lastBlockExpressionRegister), 0)
 		}
 
-		thenBranch := g.function.emit(OpcodeJump)
-		g.function.PatchInstruction(falseBranch, condRegister, false, len(g.function.instructions))
+		thenBranch := g.function.emitPlaceholder(OpcodeJump)
+		g.function.PatchInstruction(falseBranch, newInstructionABx(OpcodeJumpIf, condRegister, len(g.function.instructions)).WithK(false))
 		if expr.ElseBlock != nil {
 			switch expr.ElseBlock.(type) {
 			case *ast.BlockStatement:
 				g.lastBlockExpressionRegister = -1
 				g.emitStatement(expr.ElseBlock)
 				if g.lastBlockExpressionRegister != -1 {
-					g.function.emit(OpcodeMove, resultRegister, g.lastBlockExpressionRegister)
+					g.function.emitABC(OpcodeMove, resultRegister, int(g.lastBlockExpressionRegister), 0)
 				}
 			case *ast.IfExpression:
 				g.emitExpressionAligned(expr.ElseBlock)
 			}
 		}
-		g.function.PatchInstruction(thenBranch, len(g.function.instructions))
+		g.function.PatchInstruction(thenBranch, newInstructionABx(OpcodeJump, 0, len(g.function.instructions)))
 		return resultRegister
+	case *ast.WhenExpression:
+		return g.emitWhenExpression(expr)
+	case *ast.TryExpression:
+		return g.emitTryExpression(expr)
+	case *ast.FunctionExpression:
+		return g.emitClosure(expr)
 	case *ast.UnaryExpression:
 		targetReg := g.function.addTemp()
 		operandReg := g.emitExpression(expr.Right)
 		switch expr.Operator.Type {
 		case token.BANG:
-			g.function.emit(OpcodeNot, targetReg, operandReg)
+			g.function.emitABC(OpcodeNot, targetReg, int(operandReg), 0)
 		case token.MINUS:
-			g.function.emit(OpcodeNeg, targetReg, operandReg)
+			g.function.emitABC(OpcodeNeg, targetReg, int(operandReg), 0)
 		case token.PLUS:
+			g.function.emitABC(OpcodeMove, targetReg, int(operandReg), 0)
 		default:
 			panic(fmt.Sprintf("error: unknown unary operator '%s': %s", expr.Operator.Literal, expr.Span))
 		}
+		g.function.freeTempRegistersAfter(targetReg)
 		return targetReg
 	case *ast.CallExpression:
+		switch callee := expr.Function.(type) {
+		case *ast.MemberExpression:
+			if enumType, isEnum := callee.LeftType.(*env.ChlangEnumType); isEnum {
+				return g.emitEnumValue(enumType, callee.Member.Value, expr.Args)
+			}
+		case *ast.Identifier:
+			if isBuiltinVariant(callee) {
+				return g.emitEnumValue(env.BuiltinVariantEnum(callee.Value), callee.Value, expr.Args)
+			}
+			if target, ok := expr.Conversion.(env.ChlangPrimitiveType); ok {
+				return g.emitConversion(target, expr.Args[0])
+			}
+		}
 		calleeReg := g.function.addTemp() // callee register also can be as a return register
 
-		functionRef := g.function.lookupConstant(expr.Function.Symbol.Name)
-		if functionRef == nil {
-			panic(fmt.Sprintf("error: unresolved function '%s'", expr.Function.Symbol.Name))
+		var functionName string
+		var function *OperandValue // the called function, it is looked up by the function name if nil
+		dynamic := false           // the method is looked up in the method table of the receiver
+		args, spread := variadicArguments(expr)
+		switch callee := expr.Function.(type) {
+		case *ast.Identifier:
+			if isFunctionValue(callee.Symbol) {
+				g.emitCalleeValue(calleeReg, callee)
+				break
+			}
+			functionName = callee.Value
+			if len(expr.TypeArgs) > 0 {
+				function = g.instantiateFunction(callee.Symbol.(*env.EnvSymbolEntity), expr.TypeArgs)
+			}
+		case *ast.MemberExpression:
+			if _, ok := callee.LeftType.(*env.ChlangPackageType); ok {
+				if len(expr.TypeArgs) > 0 {
+					function = g.instantiateFunction(callee.Symbol.(*env.EnvSymbolEntity), expr.TypeArgs)
+				} else {
+					function = g.packageMember(callee)
+				}
+				break
+			}
+			if isFunctionValue(callee.Symbol) {
+				// the function stored in the struct field
+				g.emitCalleeValue(calleeReg, callee)
+				break
+			}
+			// method call, the receiver is the first argument
+			functionName = callee.Member.Value
+			switch receiverType := g.concreteType(callee.LeftType.(env.ChlangType)).(type) {
+			case *env.ChlangStructType:
+				if receiverType.IsInherited(functionName) {
+					dynamic = true
+				} else {
+					function = g.structMethod(receiverType, functionName)
+				}
+			case *env.ChlangTraitType:
+				dynamic = true
+			case *env.ChlangOptionType:
+				functionName = methodName(env.OptionEnum.Name, functionName)
+			case *env.ChlangResultType:
+				functionName = methodName(env.ResultEnum.Name, functionName)
+			}
+			args = append([]ast.Expression{callee.Left}, args...)
+		default:
+			g.emitCalleeValue(calleeReg, callee)
 		}
-		g.function.emit(OpcodeLoadConst, calleeReg, g.function.emitConstantValue(functionRef))
-
-		for _, argumentExpr := range expr.Args {
-			register := g.emitExpression(argumentExpr)
-			if int(register) < len(g.function.locals) && !g.function.locals[register].temp {
-				tempRegister := g.function.addTemp()
-				g.function.emit(OpcodeMove, tempRegister, register)
+		if function == nil && functionName != "" && !dynamic {
+			function = g.function.lookupConstant(functionName)
+			if function == nil {
+				panic(fmt.Sprintf("error: unresolved function '%s'", functionName))
 			}
 		}
+		if function != nil {
+			g.function.emitABx(OpcodeLoadConst, calleeReg, int(g.function.emitConstantValue(function)))
+		}
+
+		g.emitArguments(args)
 
-		returns := 0
-		if expr.Function.Symbol.Function.ReturnType != symbols.SymbolTypeVoid {
-			returns = 1
+		returns := returnCount(env.Underlying(expr.Symbol.(*env.EnvSymbolEntity).Type).(*env.ChlangFunctionType).Return)
+		if dynamic {
+			// the receiver is already evaluated in the first argument register
+			nameIdx := g.function.emitConstantValue(&OperandValue{Kind: OperandTypeString, Value: functionName})
+			g.function.emitABC(OpcodeGetMethod, calleeReg, int(calleeReg)+1, int(nameIdx))
 		}
-		g.function.emit(OpcodeCall, calleeReg, len(expr.Args), returns)
+		g.function.emit(newInstructionABC(OpcodeCall, calleeReg, len(args), returns).WithK(spread))
 
-		for i := 0; i < len(expr.Args); i++ {
+		for i := 0; i < len(args); i++ {
 			g.function.popTempRegister()
 		}
 
 		return calleeReg
 	case *ast.AssignExpression:
+		if _, ok := expr.Left.(*ast.TupleExpression); ok {
+			return g.emitTupleAssignment(expr)
+		}
 		opcode, ok := mappedAssignOperatorsToOpcodes[expr.Operator.Type]
 		if !ok {
 			panic(fmt.Sprintf("unknown assign expression operator '%s'", expr.Operator.Literal))
@@ -322,56 +816,110 @@ func (g *RVMGenerator) emitExpression(expression ast.Expression) RegisterAddress
 		rightReg := g.emitExpression(expr.Right)
 		switch leftExpr := expr.Left.(type) {
 		case *ast.Identifier:
+			if upvalue := g.upvalueOf(leftExpr); upvalue >= 0 {
+				valueReg := rightReg
+				if expr.Operator.Type != token.ASSIGN {
+					valueReg = g.emitExpression(leftExpr)
+					g.function.emitABC(opcode, valueReg, int(valueReg), int(rightReg))
+				}
+				g.function.emitABC(OpcodeSetUpval, valueReg, upvalue, 0)
+				return valueReg
+			}
 			leftReg := g.emitExpression(expr.Left)
 			if expr.Operator.Type == token.ASSIGN {
-				g.function.emit(OpcodeMove, leftReg, rightReg)
+				g.function.emitABC(OpcodeMove, leftReg, int(rightReg), 0)
 				return leftReg
 			}
-			g.function.emit(opcode, leftReg, leftReg, rightReg)
+			g.function.emitABC(opcode, leftReg, int(leftReg), int(rightReg))
 			return leftReg
 		case *ast.IndexExpression:
 			arrayReg := g.emitExpression(leftExpr.Left)
 			indexReg := g.emitExpression(leftExpr.Index)
-			g.function.emit(OpcodeArraySet, arrayReg, indexReg, rightReg)
-			return arrayReg
+			if expr.Operator.Type != token.ASSIGN {
+				elementReg := g.function.addTemp()
+				g.function.emitABC(OpcodeArrayGet, elementReg, int(arrayReg), int(indexReg))
+				g.function.emitABC(opcode, elementReg, int(elementReg), int(rightReg))
+				rightReg = elementReg
+			}
+			g.function.emitABC(OpcodeArraySet, arrayReg, int(indexReg), int(rightReg))
+			return rightReg
+		case *ast.MemberExpression:
+			structReg := g.emitExpression(leftExpr.Left)
+			fieldIdx := g.structFieldIndex(leftExpr)
+			if expr.Operator.Type != token.ASSIGN {
+				fieldReg := g.function.addTemp()
+				g.function.emitABC(OpcodeGetField, fieldReg, int(structReg), fieldIdx)
+				g.function.emitABC(opcode, fieldReg, int(fieldReg), int(rightReg))
+				rightReg = fieldReg
+			}
+			g.function.emitABC(OpcodeSetField, structReg, fieldIdx, int(rightReg))
+			return rightReg
 		default:
 			panic(fmt.Sprintf("error: invalid left expression type: %T", leftExpr))
 		}
 	case *ast.BinaryExpression:
+		if expr.Operator.Type == token.AND || expr.Operator.Type == token.OR {
+			return g.emitLogicalExpression(expr)
+		}
 		targetReg := g.function.addTemp()
 
 		leftReg := g.emitExpression(expr.Left)
 		rightReg := g.emitExpression(expr.Right)
 
-		g.function.popTempRegister() // pop last register
-
 		if opcode, ok := mappedBinaryOperatorsToOpcodes[expr.Operator.Type]; ok {
-			g.function.emit(opcode, targetReg, leftReg, rightReg)
+			g.function.emitABC(opcode, targetReg, int(leftReg), int(rightReg))
+			g.function.freeTempRegistersAfter(targetReg)
 			return targetReg
 		}
 
 		panic(fmt.Sprintf("error: unknown operator '%s'", expr.Operator.Literal))
 	case *ast.ArrayExpression:
 		arrayReg := g.function.addTemp()
-		g.function.emit(OpcodeAllocArray, arrayReg, len(expr.Elements))
+		g.function.emitABx(OpcodeAllocArray, arrayReg, len(expr.Elements))
 		for i, element := range expr.Elements {
 			elementReg := g.emitExpression(element)
-			g.function.emit(OpcodeArraySet, arrayReg, i, elementReg)
+			g.function.emit(newInstructionABC(OpcodeArraySet, arrayReg, i, int(elementReg)).WithK(true))
+			g.function.freeTempRegistersAfter(arrayReg)
 		}
 		return arrayReg
 	case *ast.IndexExpression:
 		tempReg := g.function.addTemp()
 		arrayReg := g.emitExpression(expr.Left)
-		indexReg := g.emitExpressionAligned(expr.Index)
-		g.function.emit(OpcodeArrayGet, tempReg, arrayReg, indexReg)
+		indexReg := g.emitExpression(expr.Index)
+		g.function.emitABC(OpcodeArrayGet, tempReg, int(arrayReg), int(indexReg))
+		g.function.freeTempRegistersAfter(tempReg)
 		return tempReg
+	case *ast.InitStructExpression:
+		structType := g.concreteType(expr.Type.(env.ChlangType)).(*env.ChlangStructType)
+		structReg := g.function.addTemp()
+		g.function.emitABx(OpcodeNewStruct, structReg, int(g.function.emitConstantValue(g.structPrototype(structType))))
+		for _, field := range expr.Fields {
+			valueReg := g.emitExpression(field.Value)
+			g.function.emitABC(OpcodeSetField, structReg, structType.FieldIndex(field.Name.Value), int(valueReg))
+			g.function.freeTempRegistersAfter(structReg)
+		}
+		return structReg
+	case *ast.MemberExpression:
+		if enumType, ok := expr.LeftType.(*env.ChlangEnumType); ok {
+			return g.emitEnumValue(enumType, expr.Member.Value, nil)
+		}
+		if _, ok := expr.LeftType.(*env.ChlangPackageType); ok {
+			targetReg := g.function.addTemp()
+			g.function.emitABx(OpcodeLoadConst, targetReg, int(g.function.emitConstantValue(g.packageMember(expr))))
+			return targetReg
+		}
+		targetReg := g.function.addTemp()
+		structReg := g.emitExpression(expr.Left)
+		g.function.emitABC(OpcodeGetField, targetReg, int(structReg), g.structFieldIndex(expr))
+		g.function.freeTempRegistersAfter(targetReg)
+		return targetReg
 	case *ast.IntLiteral:
 		targetReg := g.function.addTemp()
-		g.function.emit(OpcodeLoadConst, targetReg, g.function.emitConstantValue(getOperandValueFromConstant(expr)))
+		g.function.emitABx(OpcodeLoadConst, targetReg, int(g.function.emitConstantValue(getOperandValueFromConstant(expr))))
 		return targetReg
 	case *ast.FloatLiteral:
 		targetReg := g.function.addTemp()
-		g.function.emit(OpcodeLoadConst, targetReg, g.function.emitConstantValue(getOperandValueFromConstant(expr)))
+		g.function.emitABx(OpcodeLoadConst, targetReg, int(g.function.emitConstantValue(getOperandValueFromConstant(expr))))
 		return targetReg
 	case *ast.BoolLiteral:
 		var value bool
@@ -384,20 +932,36 @@ func (g *RVMGenerator) emitExpression(expression ast.Expression) RegisterAddress
 			panic(fmt.Sprintf("error: invalid boolean value: %s", expr.Value))
 		}
 		reg := g.function.addTemp()
-		g.function.emit(OpcodeLoadBool, reg, value)
+		g.function.emit(newInstructionABx(OpcodeLoadBool, reg, 0).WithK(value))
 		return reg
 	case *ast.StringLiteral:
 		reg := g.function.addTemp()
-		g.function.emit(OpcodeLoadString, reg, expr.Value)
+		g.function.emitABx(OpcodeLoadConst, reg, int(g.function.emitConstantValue(getOperandValueFromConstant(expr))))
 		return reg
+	case *ast.StringTemplate:
+		targetReg := g.function.addTemp()
+		first := RegisterAddress(len(g.function.locals))
+		g.emitArguments(expr.Parts)
+		g.function.emitABC(OpcodeConcat, targetReg, int(first), len(expr.Parts))
+		g.function.freeTempRegistersAfter(targetReg)
+		return targetReg
 	case *ast.Identifier:
+		if isBuiltinVariant(expr) {
+			return g.emitEnumValue(env.BuiltinVariantEnum(expr.Value), expr.Value, nil)
+		}
+		if upvalue := g.upvalueOf(expr); upvalue >= 0 {
+			registerId := g.function.addTemp()
+			g.function.emitABC(OpcodeGetUpval, registerId, upvalue, 0)
+			return registerId
+		}
 		local := g.function.lookupLocal(expr.Value)
 		if local == nil {
-			if constant := g.function.lookupConstant(expr.Value); constant == nil {
+			constant := g.function.lookupConstant(expr.Value)
+			if constant == nil {
 				panic(fmt.Sprintf("error: unresolved symbol '%s' at %s\n", expr.Value, expr.Token.Position))
 			}
 			registerId := g.function.addTemp()
-			g.function.emit(OpcodeLoadConst, registerId, expr.Value)
+			g.function.emitABx(OpcodeLoadConst, registerId, int(g.function.emitConstantValue(constant)))
 			return registerId
 		} else {
 			return local.address
@@ -407,12 +971,397 @@ func (g *RVMGenerator) emitExpression(expression ast.Expression) RegisterAddress
 	panic(fmt.Sprintf("error: unknown expression type: %T", expression))
 }
 
+// upvalueOf returns the upvalue index of the variable declared in the enclosing function, or -1 for the local variables and constants
+func (g *RVMGenerator) upvalueOf(ident *ast.Identifier) int {
+	if !isVariable(ident.Symbol) {
+		return -1
+	}
+	if g.function.lookupLocal(ident.Value) != nil {
+		return -1
+	}
+	return g.function.resolveUpvalue(ident.Value)
+}
+
+// isVariable reports whether the symbol is a variable or a parameter
+func isVariable(symbol ast.NodeSymbolRef) bool {
+	entity, ok := symbol.(*env.EnvSymbolEntity)
+	return ok && entity.EntityType == env.SymbolEntityVariable
+}
+
+// isFunctionValue reports whether the symbol is a variable holding a function, it is called by the value instead of the constant
+func isFunctionValue(symbol ast.NodeSymbolRef) bool {
+	if !isVariable(symbol) {
+		return false
+	}
+	_, ok := env.Underlying(symbol.(*env.EnvSymbolEntity).Type).(*env.ChlangFunctionType)
+	return ok
+}
+
+// emitCalleeValue evaluates the function value into the callee register
+func (g *RVMGenerator) emitCalleeValue(calleeReg RegisterAddress, callee ast.Expression) {
+	valueReg := g.emitExpression(callee)
+	if valueReg != calleeReg {
+		g.function.emitABC(OpcodeMove, calleeReg, int(valueReg), 0)
+	}
+	g.function.freeTempRegistersAfter(calleeReg)
+}
+
+// emitArguments evaluates the expressions into consecutive temp registers allocated after the last one
+func (g *RVMGenerator) emitArguments(args []ast.Expression) {
+	for _, argumentExpr := range args {
+		register := g.emitExpression(argumentExpr)
+		if int(register) < len(g.function.locals) && !g.function.locals[register].temp {
+			tempRegister := g.function.addTemp()
+			g.function.emitABC(OpcodeMove, tempRegister, int(register), 0)
+		}
+	}
+}
+
+// variadicArguments packs the variadic arguments of the call into the array passed as the last argument,
+// the spread array is passed as is: f(1, 2, 3) -> f(1, [2, 3]), f(1, ...arr) -> f(1, arr).
+// The array spread into the built-in function is unpacked by the VM, spread is set for it: println(...arr)
+func variadicArguments(expr *ast.CallExpression) (args []ast.Expression, spread bool) {
+	functionType := env.Underlying(expr.Symbol.(*env.EnvSymbolEntity).Type).(*env.ChlangFunctionType)
+	if !functionType.IsVariadic() {
+		if last := len(expr.Args) - 1; last >= 0 {
+			if spreadExpr, ok := expr.Args[last].(*ast.SpreadExpression); ok {
+				args = append([]ast.Expression{}, expr.Args[:last]...)
+				return append(args, spreadExpr.Expression), true
+			}
+		}
+		return expr.Args, false
+	}
+	fixed := len(functionType.Args)
+	args = append([]ast.Expression{}, expr.Args[:fixed]...)
+	if len(expr.Args) == fixed+1 {
+		if spreadExpr, ok := expr.Args[fixed].(*ast.SpreadExpression); ok {
+			return append(args, spreadExpr.Expression), false
+		}
+	}
+	return append(args, &ast.ArrayExpression{Elements: expr.Args[fixed:]}), false
+}
+
+// returnCount returns the number of values returned by the function with the given return type
+func returnCount(returnType env.ChlangType) int {
+	if returnType == env.SymbolTypeVoid {
+		return 0
+	}
+	if tuple, ok := env.Underlying(returnType).(*env.ChlangTupleType); ok {
+		return len(tuple.Elements)
+	}
+	return 1
+}
+
+// emitValues evaluates the comma separated values or the results of the call into consecutive temp registers
+// and returns the first one, the call results are stored in the registers starting from the callee register
+func (g *RVMGenerator) emitValues(expr ast.Expression, count int) RegisterAddress {
+	if tuple, ok := expr.(*ast.TupleExpression); ok {
+		first := RegisterAddress(len(g.function.locals))
+		g.emitArguments(tuple.Elements)
+		return first
+	}
+	first := g.emitExpression(expr)
+	for i := 1; i < count; i++ {
+		if register := g.function.addTemp(); register != first+RegisterAddress(i) {
+			panic(fmt.Sprintf("error: results of the call are not in consecutive registers: %s", expr.GetSpan()))
+		}
+	}
+	return first
+}
+
+// emitTupleAssignment compiles the parallel assignment 'a, b = b, a',
+// all values are evaluated into temp registers before any target is assigned
+func (g *RVMGenerator) emitTupleAssignment(expr *ast.AssignExpression) RegisterAddress {
+	targets := expr.Left.(*ast.TupleExpression)
+	first := g.emitValues(expr.Right, len(targets.Elements))
+	last := first + RegisterAddress(len(targets.Elements)-1)
+	for idx, target := range targets.Elements {
+		valueReg := first + RegisterAddress(idx)
+		switch target := target.(type) {
+		case *ast.Identifier:
+			if upvalue := g.upvalueOf(target); upvalue >= 0 {
+				g.function.emitABC(OpcodeSetUpval, valueReg, upvalue, 0)
+				break
+			}
+			g.function.emitABC(OpcodeMove, g.emitExpression(target), int(valueReg), 0)
+		case *ast.IndexExpression:
+			arrayReg := g.emitExpression(target.Left)
+			indexReg := g.emitExpression(target.Index)
+			g.function.emitABC(OpcodeArraySet, arrayReg, int(indexReg), int(valueReg))
+		case *ast.MemberExpression:
+			structReg := g.emitExpression(target.Left)
+			g.function.emitABC(OpcodeSetField, structReg, g.structFieldIndex(target), int(valueReg))
+		default:
+			panic(fmt.Sprintf("error: invalid left expression type: %T", target))
+		}
+		g.function.freeTempRegistersAfter(last)
+	}
+	return first
+}
+
+// patternBranch is the conditional jump to the next arm of the when expression, taken if the pattern doesn't match
+type patternBranch struct {
+	address  int
+	register RegisterAddress
+}
+
+// emitWhenExpression compiles the when expression into the compare-and-jump chain:
+//
+//	subject, tag = <subject>, EnumTag(subject) ; the tag is loaded for enum subjects only
+//	arm:      cond = subject == pattern; JumpIf cond, false, [next arm]
+//	          <payload bindings>; <body>; result = <value>; Jump [end]
+//	next arm: ...
+//	end:
+func (g *RVMGenerator) emitWhenExpression(expr *ast.WhenExpression) RegisterAddress {
+	resultReg := g.function.addTemp()
+	g.function.enterScope()
+
+	// the subject is evaluated once, the local keeps it from being freed by the arms bodies
+	subjectReg := g.emitExpression(expr.Subject)
+	if !g.function.bindLocal(subjectReg, "<when_subject>") {
+		valueReg := subjectReg
+		subjectReg = g.function.addLocal("<when_subject>")
+		if subjectReg != valueReg {
+			g.function.emitABC(OpcodeMove, subjectReg, int(valueReg), 0)
+		}
+	}
+	tagReg := RegisterAddress(-1)
+	switch expr.SubjectType.(type) {
+	case *env.ChlangEnumType, *env.ChlangOptionType, *env.ChlangResultType:
+		tagReg = g.function.addLocal("<when_tag>")
+		g.function.emitABC(OpcodeEnumTag, tagReg, int(subjectReg), 0)
+	}
+
+	endBranches := make([]int, 0, len(expr.Arms))
+	for _, arm := range expr.Arms {
+		var branches []patternBranch
+		switch pattern := arm.Pattern.(type) {
+		case nil: // else
+		case *ast.RangeExpr:
+			endOpcode := OpcodeLt
+			if pattern.Inclusive {
+				endOpcode = OpcodeLte
+			}
+			branches = append(branches,
+				g.emitPatternTest(OpcodeGte, subjectReg, pattern.Start),
+				g.emitPatternTest(endOpcode, subjectReg, pattern.End),
+			)
+		case *ast.MemberExpression, *ast.Identifier:
+			branches = append(branches, g.emitVariantTest(tagReg, pattern))
+		case *ast.CallExpression:
+			branches = append(branches, g.emitVariantTest(tagReg, pattern.Function))
+		default:
+			branches = append(branches, g.emitPatternTest(OpcodeEq, subjectReg, pattern))
+		}
+
+		g.function.enterScope()
+		if call, ok := arm.Pattern.(*ast.CallExpression); ok {
+			for idx, arg := range call.Args {
+				binding := arg.(*ast.Identifier)
+				if binding.Value == "_" {
+					continue
+				}
+				g.function.emitABC(OpcodeGetPayload, g.function.addLocal(binding.Value), int(subjectReg), idx)
+			}
+		}
+		g.lastBlockExpressionRegister = -1
+		g.emitStatement(arm.Body)
+		if g.lastBlockExpressionRegister != -1 {
+			g.function.emitABC(OpcodeMove, resultReg, int(g.lastBlockExpressionRegister), 0)
+		}
+		g.function.leaveScope()
+		endBranches = append(endBranches, g.function.emitPlaceholder(OpcodeJump))
+
+		nextArm := len(g.function.instructions)
+		for _, branch := range branches {
+			g.function.PatchInstruction(branch.address, newInstructionABx(OpcodeJumpIf, branch.register, nextArm).WithK(false))
+		}
+	}
+
+	end := len(g.function.instructions)
+	for _, branch := range endBranches {
+		g.function.PatchInstruction(branch, newInstructionABx(OpcodeJump, 0, end))
+	}
+	g.function.leaveScope()
+	return resultReg
+}
+
+// emitPatternTest compares the subject with the pattern value and emits the jump taken if the comparison is false
+func (g *RVMGenerator) emitPatternTest(opcode Opcode, subjectReg RegisterAddress, value ast.Expression) patternBranch {
+	condReg := g.function.addTemp()
+	valueReg := g.emitExpression(value)
+	g.function.emitABC(opcode, condReg, int(subjectReg), int(valueReg))
+	address := g.function.emitPlaceholder(OpcodeJumpIf)
+	g.function.freeTempRegistersAfter(condReg)
+	g.function.popTempRegister()
+	return patternBranch{address: address, register: condReg}
+}
+
+// emitVariantTest compares the tag of the subject with the tag of the variant: 'Enum.Variant' or the built-in 'Some', 'None', 'Ok', 'Err'
+func (g *RVMGenerator) emitVariantTest(tagReg RegisterAddress, variant ast.Expression) patternBranch {
+	var tag int
+	switch v := variant.(type) {
+	case *ast.MemberExpression:
+		tag = v.LeftType.(*env.ChlangEnumType).VariantIndex(v.Member.Value)
+	case *ast.Identifier:
+		tag = env.BuiltinVariantEnum(v.Value).VariantIndex(v.Value)
+	}
+	condReg := g.function.addTemp()
+	variantTagReg := g.function.addTemp()
+	g.function.emit(newInstructionASBx(OpcodeLoadImm32, variantTagReg, int32(tag)))
+	g.function.emitABC(OpcodeEq, condReg, int(tagReg), int(variantTagReg))
+	address := g.function.emitPlaceholder(OpcodeJumpIf)
+	g.function.popTempRegister()
+	g.function.popTempRegister()
+	return patternBranch{address: address, register: condReg}
+}
+
+// emitTryExpression compiles the '?' operator, the Err (None) value is returned from the function as is:
+//
+//	value = <expr>; ok = EnumTag(value) == 0; JumpIf ok, true, [unwrap]
+//	Return value, 1
+//	unwrap: target = GetPayload value, 0
+func (g *RVMGenerator) emitTryExpression(expr *ast.TryExpression) RegisterAddress {
+	targetReg := g.function.addTemp()
+	valueReg := g.emitExpression(expr.Expression)
+	condReg := g.function.addTemp()
+	zeroReg := g.function.addTemp()
+	g.function.emitABC(OpcodeEnumTag, condReg, int(valueReg), 0)
+	g.function.emit(newInstructionASBx(OpcodeLoadImm32, zeroReg, 0)) // Ok and Some are the first variants
+	g.function.emitABC(OpcodeEq, condReg, int(condReg), int(zeroReg))
+	unwrapBranch := g.function.emitPlaceholder(OpcodeJumpIf)
+	g.function.emitABC(OpcodeReturn, valueReg, 1, 0)
+	g.function.PatchInstruction(unwrapBranch, newInstructionABx(OpcodeJumpIf, condReg, len(g.function.instructions)).WithK(true))
+	g.function.emitABC(OpcodeGetPayload, targetReg, int(valueReg), 0)
+	g.function.freeTempRegistersAfter(targetReg)
+	return targetReg
+}
+
+// isBuiltinVariant reports whether the identifier refers to the constructor of the built-in variant: Some, None, Ok or Err
+func isBuiltinVariant(ident *ast.Identifier) bool {
+	symbol, ok := ident.Symbol.(*env.EnvSymbolEntity)
+	return ok && symbol.EntityType == env.SymbolEntityVariant
+}
+
+// emitConversion emits the conversion of the number to the numeric type: f64(x), u8(y)
+func (g *RVMGenerator) emitConversion(target env.ChlangPrimitiveType, value ast.Expression) RegisterAddress {
+	targetReg := g.function.addTemp()
+	valueReg := g.emitExpression(value)
+	if target.IsFloat() {
+		g.function.emitABC(OpcodeToFloat, targetReg, int(valueReg), target.GetNumberBitSize())
+	} else {
+		g.function.emit(newInstructionABC(OpcodeTrunc, targetReg, int(valueReg), target.GetNumberBitSize()).WithK(target.IsSigned()))
+	}
+	g.function.freeTempRegistersAfter(targetReg)
+	return targetReg
+}
+
+// emitEnumValue emits the construction of the enum variant with the payload values (if any)
+func (g *RVMGenerator) emitEnumValue(enumType *env.ChlangEnumType, variantName string, payload []ast.Expression) RegisterAddress {
+	targetReg := g.function.addTemp()
+	g.emitArguments(payload)
+	prototype := g.enumPrototype(enumType, variantName)
+	g.function.emitABx(OpcodeNewEnum, targetReg, int(g.function.emitConstantValue(prototype)))
+	g.function.freeTempRegistersAfter(targetReg)
+	return targetReg
+}
+
+// enumPrototype returns the constant of the enum variant used to allocate its values
+func (g *RVMGenerator) enumPrototype(enumType *env.ChlangEnumType, variantName string) *OperandValue {
+	tag := enumType.VariantIndex(variantName)
+	if tag < 0 {
+		panic(fmt.Sprintf("error: unknown variant '%s' of enum '%s'", variantName, enumType.Name))
+	}
+	variant := enumType.Variants[tag]
+	if prototype, ok := g.enumPrototypes[variant]; ok {
+		return prototype
+	}
+	prototype := &OperandValue{Kind: OperandTypeEnum, Value: &EnumObject{
+		Name:    enumType.Name,
+		Variant: variant.Name,
+		Tag:     tag,
+		Payload: make([]OperandValue, len(variant.Payload)),
+	}}
+	g.enumPrototypes[variant] = prototype
+	return prototype
+}
+
+// structPrototype returns the struct constant used to allocate values of the struct type
+func (g *RVMGenerator) structPrototype(structType *env.ChlangStructType) *OperandValue {
+	if prototype, ok := g.structPrototypes[structType]; ok {
+		return prototype
+	}
+	object := &StructObject{
+		Name:       structType.Name,
+		FieldNames: make([]string, len(structType.Fields)),
+		Fields:     make([]OperandValue, len(structType.Fields)),
+		Methods:    make(map[string]*FunctionObject),
+	}
+	for idx, field := range structType.Fields {
+		object.FieldNames[idx] = field.Name
+	}
+	prototype := &OperandValue{Kind: OperandTypeStruct, Value: object}
+	g.structPrototypes[structType] = prototype
+	return prototype
+}
+
+// structMethod returns the method compiled from the impl block of the struct, the struct may be declared by the linked package
+func (g *RVMGenerator) structMethod(structType *env.ChlangStructType, name string) *OperandValue {
+	if method, ok := g.structPrototype(structType).Value.(*StructObject).Methods[name]; ok {
+		return &OperandValue{Kind: OperandTypeFunctionObject, Value: method}
+	}
+	// the recursive call of the method being compiled, it is added to the table after its body
+	if method := g.function.lookupConstant(methodName(structType.Name, name)); method != nil {
+		return method
+	}
+	panic(fmt.Sprintf("error: unresolved function '%s'", methodName(structType.Name, name)))
+}
+
+func (g *RVMGenerator) structFieldIndex(expr *ast.MemberExpression) int {
+	structType, ok := expr.LeftType.(*env.ChlangStructType)
+	if !ok {
+		panic(fmt.Sprintf("error: member access on non-struct type at %s", expr.Span))
+	}
+	idx := structType.FieldIndex(expr.Member.Value)
+	if idx < 0 {
+		panic(fmt.Sprintf("error: unknown field '%s' of struct '%s'", expr.Member.Value, structType.Name))
+	}
+	return idx
+}
+
+// emitLogicalExpression emits short-circuit evaluation of '&&' and '||'.
+// The right operand is evaluated only if the left one doesn't decide the result:
+//
+//	Move   R(target), R(left)
+//	JumpIf R(target), k, [end]   ; k = false for '&&', true for '||'
+//	Move   R(target), R(right)
+//	end:
+func (g *RVMGenerator) emitLogicalExpression(expr *ast.BinaryExpression) RegisterAddress {
+	targetReg := g.function.addTemp()
+
+	leftReg := g.emitExpression(expr.Left)
+	g.function.emitABC(OpcodeMove, targetReg, int(leftReg), 0)
+	g.function.freeTempRegistersAfter(targetReg)
+	skipBranch := g.function.emitPlaceholder(OpcodeJumpIf)
+
+	rightReg := g.emitExpression(expr.Right)
+	g.function.emitABC(OpcodeMove, targetReg, int(rightReg), 0)
+	g.function.freeTempRegistersAfter(targetReg)
+
+	decidesResult := expr.Operator.Type == token.OR
+	g.function.PatchInstruction(skipBranch, newInstructionABx(OpcodeJumpIf, targetReg, len(g.function.instructions)).WithK(decidesResult))
+	return targetReg
+}
+
 func getOperandValueFromConstant(expr ast.Expression) *OperandValue {
 	switch expr := expr.(type) {
 	case *ast.IntLiteral:
 		// TODO: handle overflow
 		// TODO: Add unsigned integers support
-		bitSize := expr.Type.GetNumberBitSize()
+		bitSize := 64
+		if intType, ok := expr.Type.(env.ChlangPrimitiveType); ok && intType.IsInteger() {
+			bitSize = intType.GetNumberBitSize()
+		}
 		value, err := strconv.ParseInt(expr.Value, 0, bitSize)
 		if err != nil {
 			panic(fmt.Sprintf("getOperandValueFromConstant: invalid integer literal: %s (base=%d)", expr.Value, expr.Base))
@@ -422,7 +1371,10 @@ func getOperandValueFromConstant(expr ast.Expression) *OperandValue {
 			Value: value,
 		}
 	case *ast.FloatLiteral:
-		bitSize := expr.Type.GetNumberBitSize()
+		bitSize := 64
+		if expr.Type == env.SymbolTypeFloat32 {
+			bitSize = 32
+		}
 		value, err := strconv.ParseFloat(expr.Value, bitSize)
 		if err != nil {
 			panic("getOperandValueFromConstant: invalid float literal")
@@ -439,7 +1391,35 @@ func getOperandValueFromConstant(expr ast.Expression) *OperandValue {
 	case *ast.StringLiteral:
 		return &OperandValue{
 			Kind:  OperandTypeString,
-			Value: expr.Value,
+			Value: parseStringLiteral(expr.Value),
+		}
+	case *ast.UnaryExpression:
+		operand := getOperandValueFromConstant(expr.Right)
+		switch expr.Operator.Type {
+		case token.PLUS:
+			return operand
+		case token.MINUS:
+			if operand.Kind.IsFloat() {
+				return &OperandValue{Kind: operand.Kind, Value: -operand.Value.(float64)}
+			}
+			return &OperandValue{Kind: OperandTypeInt64, Value: -operand.Value.(int64)}
+		case token.BANG:
+			return &OperandValue{Kind: OperandTypeBool, Value: !operand.Value.(bool)}
+		}
+	case *ast.BinaryExpression:
+		left := getOperandValueFromConstant(expr.Left)
+		right := getOperandValueFromConstant(expr.Right)
+		switch expr.Operator.Type {
+		case token.AND:
+			return &OperandValue{Kind: OperandTypeBool, Value: left.Value.(bool) && right.Value.(bool)}
+		case token.OR:
+			return &OperandValue{Kind: OperandTypeBool, Value: left.Value.(bool) || right.Value.(bool)}
+		}
+		if opcode, ok := mappedBinaryOperatorsToOpcodes[expr.Operator.Type]; ok {
+			// the operation is computed by the VM, so the constant has the same value as the expression at runtime
+			machine := &VM{stack: Stack{*left, *right, {}}, callRecord: &CallFrame{}}
+			machine.performBinaryOperation(opcode, 2, 0, 1)
+			return &machine.stack[2]
 		}
 	}
 
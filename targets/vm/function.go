@@ -28,6 +28,28 @@ type FunctionObject struct {
 	// The parent context of the function.
 	// If function declared inside another function, the parent is the outer function.
 	parent *FunctionObject
+
+	// Variables of the enclosing functions captured by the closures of the function.
+	upvalues []UpvalueInfo
+
+	// The first register and the number of captures at the start of every open scope, see leaveScope.
+	scopes []scopeState
+
+	// Number of the function variables captured by the nested closures.
+	captures int
+}
+
+// UpvalueInfo describes where the closure takes the captured variable from when it is created:
+// the register of the enclosing function (local is set) or the upvalue of the enclosing closure.
+type UpvalueInfo struct {
+	name  string
+	local bool
+	index int
+}
+
+type scopeState struct {
+	first    RegisterAddress
+	captures int
 }
 
 func (fn *FunctionObject) addConstant(name string, value *OperandValue) ConstantValueIdx {
@@ -69,9 +91,19 @@ func (fn *FunctionObject) emitConstantValue(value *OperandValue) ConstantValueId
 
 func (fn *FunctionObject) enterScope() {
 	fn.scopeDepth++
+	fn.scopes = append(fn.scopes, scopeState{first: RegisterAddress(len(fn.locals)), captures: fn.captures})
 }
 
+// leaveScope removes the locals of the current scope,
+// the upvalues are closed if the closures created in the scope captured some of the variables
 func (fn *FunctionObject) leaveScope() {
+	if count := len(fn.scopes); count > 0 {
+		scope := fn.scopes[count-1]
+		fn.scopes = fn.scopes[:count-1]
+		if fn.captures != scope.captures {
+			fn.emitABC(OpcodeClose, scope.first, 0, 0)
+		}
+	}
 	if len(fn.locals) == 0 {
 		fn.scopeDepth--
 		return
@@ -121,6 +153,18 @@ func (fn *FunctionObject) freeAllTempRegister() {
 	}
 	fn.locals = fn.locals[:idx+1]
 }
+
+// freeTempRegistersAfter frees the temp registers allocated after the given register
+func (fn *FunctionObject) freeTempRegistersAfter(register RegisterAddress) {
+	idx := len(fn.locals) - 1
+	for ; idx > int(register); idx-- {
+		if !fn.locals[idx].temp {
+			break
+		}
+	}
+	fn.locals = fn.locals[:idx+1]
+}
+
 func (fn *FunctionObject) popTempRegister() *LocalRegister {
 	if len(fn.locals) == 0 {
 		return nil
@@ -146,6 +190,30 @@ func (fn *FunctionObject) addLocal(name string) RegisterAddress {
 	return address
 }
 
+// resolveUpvalue returns the index of the upvalue capturing the variable of the enclosing functions, or -1 if there is none.
+// The variable is captured through the chain of the enclosing functions like in Lua.
+func (fn *FunctionObject) resolveUpvalue(name string) int {
+	for idx, upvalue := range fn.upvalues {
+		if upvalue.name == name {
+			return idx
+		}
+	}
+	if fn.parent == nil {
+		return -1
+	}
+	if local := fn.parent.lookupLocal(name); local != nil {
+		fn.parent.captures++
+		fn.upvalues = append(fn.upvalues, UpvalueInfo{name: name, local: true, index: int(local.address)})
+		return len(fn.upvalues) - 1
+	}
+	idx := fn.parent.resolveUpvalue(name)
+	if idx < 0 {
+		return -1
+	}
+	fn.upvalues = append(fn.upvalues, UpvalueInfo{name: name, index: idx})
+	return len(fn.upvalues) - 1
+}
+
 func (fn *FunctionObject) lookupLocal(name string) *LocalRegister {
 	for i := len(fn.locals) - 1; i >= 0; i-- {
 		if fn.locals[i].name == name {
@@ -155,21 +223,35 @@ func (fn *FunctionObject) lookupLocal(name string) *LocalRegister {
 	return nil
 }
 
-func (fn *FunctionObject) emit(opcode Opcode, operands ...any) int {
-	fn.instructions = append(fn.instructions, VMInstruction{
-		opcode:   opcode,
-		operands: operands,
-	})
+func (fn *FunctionObject) emit(instruction VMInstruction) int {
+	fn.instructions = append(fn.instructions, instruction)
 	return len(fn.instructions) - 1
 }
 
-func (fn *FunctionObject) PatchInstruction(opcodeAddress int, operands ...any) {
-	instruction := fn.instructions[opcodeAddress]
-	instruction.operands = operands
+// emitABC emits an instruction R(A), B, C, registers passed as B and C are converted by caller
+func (fn *FunctionObject) emitABC(opcode Opcode, a RegisterAddress, b, c int) int {
+	return fn.emit(newInstructionABC(opcode, a, b, c))
+}
+
+func (fn *FunctionObject) emitABx(opcode Opcode, a RegisterAddress, bx int) int {
+	return fn.emit(newInstructionABx(opcode, a, bx))
+}
+
+// emitPlaceholder emits an instruction without operands, they are set by PatchInstruction when known (e.g. jump addresses)
+func (fn *FunctionObject) emitPlaceholder(opcode Opcode) int {
+	return fn.emit(VMInstruction(opcode))
+}
+
+func (fn *FunctionObject) PatchInstruction(opcodeAddress int, instruction VMInstruction) {
 	fn.instructions[opcodeAddress] = instruction
 }
 
 func (fn *FunctionObject) Print() {
+	fn.print(map[*FunctionObject]bool{})
+}
+
+func (fn *FunctionObject) print(printed map[*FunctionObject]bool) {
+	printed[fn] = true
 	padding := strings.Repeat("-", 15)
 	fmt.Printf("%s function_object=%s %s\n", padding, fn.name, padding)
 
@@ -179,6 +261,8 @@ func (fn *FunctionObject) Print() {
 		value := constant.Value.Value
 		if constant.Value.Kind == OperandTypeFunctionObject {
 			value = fmt.Sprintf("%p", value)
+		} else if constant.Value.Kind == OperandTypeStruct || constant.Value.Kind == OperandTypeEnum {
+			value = stringifyOperandValue(constant.Value)
 		}
 		left := fmt.Sprintf("\t%v: \033[33m<%s>\033[0m%v", i, constant.Value.Kind, value)
 		offset := 0
@@ -189,7 +273,26 @@ func (fn *FunctionObject) Print() {
 	}
 
 	fn.printLocals()
+	if len(fn.upvalues) > 0 {
+		fmt.Printf("Upvalues (%d):\n", len(fn.upvalues))
+		for i, upvalue := range fn.upvalues {
+			fmt.Printf("\t%v: %s (local=%v, index=%d)\n", i, upvalue.name, upvalue.local, upvalue.index)
+		}
+	}
 	fn.printInstructions()
+
+	// nested functions are stored as constants of their parent,
+	// the linked packages have no parent and are stored in every module importing them
+	for _, constant := range fn.constants {
+		if constant.Value.Kind != OperandTypeFunctionObject {
+			continue
+		}
+		child := constant.Value.Value.(*FunctionObject)
+		if (child.parent == fn || child.parent == nil) && !printed[child] {
+			fmt.Println()
+			child.print(printed)
+		}
+	}
 }
 
 func (fn *FunctionObject) printLocals() {
@@ -201,18 +304,27 @@ func (fn *FunctionObject) printLocals() {
 
 func (fn *FunctionObject) printInstructions() {
 	fmt.Printf("Instructions (%d): \n", len(fn.instructions))
+	fn.PrintInstructions(0)
+}
+
+// PrintInstructions prints the instructions of the function starting from the given address
+func (fn *FunctionObject) PrintInstructions(from int) {
 	opcodeWidth := 10
 	operandWidth := 3
-	for i, instruction := range fn.instructions {
-		maxWidth := int(math.Max(0, float64(opcodeWidth-len(instruction.opcode.String()))))
-		fmt.Printf("\t%v: \033[36m%v\033[0m%s", i, instruction.opcode, strings.Repeat(" ", maxWidth))
-		lastOperandIdx := len(instruction.operands) - 1
-		for idx, operand := range instruction.operands {
+	for i := from; i < len(fn.instructions); i++ {
+		instruction := fn.instructions[i]
+		opcode, operands := instruction.Opcode(), instruction.operands()
+		maxWidth := int(math.Max(0, float64(opcodeWidth-len(opcode.String()))))
+		fmt.Printf("\t%v: \033[36m%v\033[0m%s", i, opcode, strings.Repeat(" ", maxWidth))
+		lastOperandIdx := len(operands) - 1
+		for idx, operand := range operands {
 			fmt.Printf("%s", strings.Repeat(" ", operandWidth))
 			if _, ok := operand.(RegisterAddress); ok {
 				fmt.Printf("\033[33mr%v\033[0m", operand)
 			} else if _, ok := operand.(ConstantValueIdx); ok {
 				fmt.Printf("\033[34mconst#%v\033[0m", operand)
+			} else if _, ok := operand.(UpvalueIdx); ok {
+				fmt.Printf("\033[35mupval#%v\033[0m", operand)
 			} else {
 				fmt.Printf("%v", operand)
 			}
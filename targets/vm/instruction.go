@@ -0,0 +1,216 @@
+package vm
+
+import "fmt"
+
+// VMInstruction is a 64-bit instruction word, fields are packed like in Lua VM:
+//
+//	bits:  63      56 55             40 39             24 23              8 7      0
+//	ABC:   | k flag  |        C        |        B        |        A        | opcode |
+//	ABx:   | k flag  |               Bx (sBx)            |        A        | opcode |
+//
+// A, B and C are 16-bit fields (registers, counts), Bx is a 32-bit field (constant index,
+// jump address, array size) and sBx is its signed variant (immediate values).
+// The k flag changes meaning of another field, e.g. B is an immediate index instead of register.
+type VMInstruction uint64
+
+const (
+	instructionOpcodeBits = 8
+	instructionABits      = 16
+	instructionBBits      = 16
+	instructionCBits      = 16
+	instructionBxBits     = instructionBBits + instructionCBits
+
+	instructionAShift  = instructionOpcodeBits
+	instructionBShift  = instructionAShift + instructionABits
+	instructionCShift  = instructionBShift + instructionBBits
+	instructionKShift  = instructionCShift + instructionCBits
+	instructionBxShift = instructionBShift
+
+	maxInstructionA  = 1<<instructionABits - 1
+	maxInstructionB  = 1<<instructionBBits - 1
+	maxInstructionC  = 1<<instructionCBits - 1
+	maxInstructionBx = 1<<instructionBxBits - 1
+)
+
+// Operand layout of the opcodes, it is used to encode and print instructions
+type instructionFormat uint8
+
+const (
+	formatNone      instructionFormat = iota
+	formatAB                          // Op R(A), R(B)
+	formatABC                         // Op R(A), R(B), R(C)
+	formatAConst                      // Op R(A), const#Bx
+	formatABool                       // Op R(A), k
+	formatASBx                        // Op R(A), sBx
+	formatABx                         // Op R(A), Bx
+	formatJump                        // Op Bx
+	formatJumpIf                      // Op R(A), k, Bx
+	formatArraySet                    // Op R(A), R(B) | B (if k), R(C)
+	formatGetField                    // Op R(A), R(B), C
+	formatSetField                    // Op R(A), B, R(C)
+	formatGetMethod                   // Op R(A), R(B), const#C
+	formatCall                        // Op R(A), B, C, k
+	formatReturn                      // Op R(A), B
+	formatUpval                       // Op R(A), upval#B
+	formatA                           // Op R(A)
+	formatTrunc                       // Op R(A), R(B), C, k
+)
+
+var opcodeFormats = map[Opcode]instructionFormat{
+	OpcodeHalt:       formatNone,
+	OpcodeNop:        formatNone,
+	OpcodeMove:       formatAB,
+	OpcodeNot:        formatAB,
+	OpcodeNeg:        formatAB,
+	OpcodeLoadConst:  formatAConst,
+	OpcodeLoadBool:   formatABool,
+	OpcodeLoadImm32:  formatASBx,
+	OpcodeAllocArray: formatABx,
+	OpcodeArraySet:   formatArraySet,
+	OpcodeArrayGet:   formatABC,
+	OpcodeArrayLen:   formatAB,
+	OpcodeNewStruct:  formatAConst,
+	OpcodeGetField:   formatGetField,
+	OpcodeSetField:   formatSetField,
+	OpcodeGetMethod:  formatGetMethod,
+	OpcodeNewEnum:    formatAConst,
+	OpcodeEnumTag:    formatAB,
+	OpcodeGetPayload: formatGetField,
+	OpcodeJump:       formatJump,
+	OpcodeJumpIf:     formatJumpIf,
+	OpcodeCall:       formatCall,
+	OpcodeReturn:     formatReturn,
+	OpcodeClosure:    formatAConst,
+	OpcodeGetUpval:   formatUpval,
+	OpcodeSetUpval:   formatUpval,
+	OpcodeClose:      formatA,
+	OpcodeConcat:     formatGetField,
+	OpcodeTrunc:      formatTrunc,
+	OpcodeToFloat:    formatGetField,
+}
+
+func (op Opcode) format() instructionFormat {
+	if format, ok := opcodeFormats[op]; ok {
+		return format
+	}
+	// arithmetic, bitwise and comparison operations: R(A) = R(B) op R(C)
+	return formatABC
+}
+
+func checkInstructionField(op Opcode, field string, value, max int) {
+	if value < 0 || value > max {
+		panic(fmt.Sprintf("vm: operand %s=%d of '%s' is out of range [0, %d]", field, value, op, max))
+	}
+}
+
+// newInstructionABC encodes an instruction with three 16-bit operands
+func newInstructionABC(op Opcode, a RegisterAddress, b, c int) VMInstruction {
+	checkInstructionField(op, "A", int(a), maxInstructionA)
+	checkInstructionField(op, "B", b, maxInstructionB)
+	checkInstructionField(op, "C", c, maxInstructionC)
+	return VMInstruction(op) |
+		VMInstruction(a)<<instructionAShift |
+		VMInstruction(b)<<instructionBShift |
+		VMInstruction(c)<<instructionCShift
+}
+
+// newInstructionABx encodes an instruction with 16-bit A operand and 32-bit unsigned Bx operand
+func newInstructionABx(op Opcode, a RegisterAddress, bx int) VMInstruction {
+	checkInstructionField(op, "A", int(a), maxInstructionA)
+	checkInstructionField(op, "Bx", bx, maxInstructionBx)
+	return VMInstruction(op) |
+		VMInstruction(a)<<instructionAShift |
+		VMInstruction(bx)<<instructionBxShift
+}
+
+// newInstructionASBx encodes an instruction with 16-bit A operand and 32-bit signed Bx operand
+func newInstructionASBx(op Opcode, a RegisterAddress, sbx int32) VMInstruction {
+	checkInstructionField(op, "A", int(a), maxInstructionA)
+	return VMInstruction(op) |
+		VMInstruction(a)<<instructionAShift |
+		VMInstruction(uint32(sbx))<<instructionBxShift
+}
+
+// WithK returns the instruction with the k flag set to the value
+func (i VMInstruction) WithK(k bool) VMInstruction {
+	if k {
+		return i | 1<<instructionKShift
+	}
+	return i &^ (1 << instructionKShift)
+}
+
+func (i VMInstruction) Opcode() Opcode {
+	return Opcode(i & (1<<instructionOpcodeBits - 1))
+}
+
+func (i VMInstruction) A() RegisterAddress {
+	return RegisterAddress(i >> instructionAShift & maxInstructionA)
+}
+
+func (i VMInstruction) B() int {
+	return int(i >> instructionBShift & maxInstructionB)
+}
+
+func (i VMInstruction) C() int {
+	return int(i >> instructionCShift & maxInstructionC)
+}
+
+func (i VMInstruction) Bx() int {
+	return int(i >> instructionBxShift & maxInstructionBx)
+}
+
+func (i VMInstruction) SBx() int32 {
+	return int32(uint32(i >> instructionBxShift & maxInstructionBx))
+}
+
+func (i VMInstruction) K() bool {
+	return i>>instructionKShift&1 == 1
+}
+
+// operands decodes the instruction fields according to the opcode format.
+// Registers are returned as RegisterAddress, constants as ConstantValueIdx and upvalues as UpvalueIdx.
+func (i VMInstruction) operands() []any {
+	switch i.Opcode().format() {
+	case formatAB:
+		return []any{i.A(), RegisterAddress(i.B())}
+	case formatABC:
+		return []any{i.A(), RegisterAddress(i.B()), RegisterAddress(i.C())}
+	case formatAConst:
+		return []any{i.A(), ConstantValueIdx(i.Bx())}
+	case formatABool:
+		return []any{i.A(), i.K()}
+	case formatASBx:
+		return []any{i.A(), i.SBx()}
+	case formatABx:
+		return []any{i.A(), i.Bx()}
+	case formatJump:
+		return []any{i.Bx()}
+	case formatJumpIf:
+		return []any{i.A(), i.K(), i.Bx()}
+	case formatArraySet:
+		if i.K() {
+			return []any{i.A(), i.B(), RegisterAddress(i.C())}
+		}
+		return []any{i.A(), RegisterAddress(i.B()), RegisterAddress(i.C())}
+	case formatGetField:
+		return []any{i.A(), RegisterAddress(i.B()), i.C()}
+	case formatSetField:
+		return []any{i.A(), i.B(), RegisterAddress(i.C())}
+	case formatGetMethod:
+		return []any{i.A(), RegisterAddress(i.B()), ConstantValueIdx(i.C())}
+	case formatCall:
+		if i.K() {
+			return []any{i.A(), i.B(), i.C(), i.K()}
+		}
+		return []any{i.A(), i.B(), i.C()}
+	case formatReturn:
+		return []any{i.A(), i.B()}
+	case formatUpval:
+		return []any{i.A(), UpvalueIdx(i.B())}
+	case formatA:
+		return []any{i.A()}
+	case formatTrunc:
+		return []any{i.A(), RegisterAddress(i.B()), i.C(), i.K()}
+	}
+	return nil
+}
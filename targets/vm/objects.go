@@ -15,6 +15,13 @@ const (
 	OperandTypeArray
 	OperandTypeFunctionObject
 	OperandTypeBuildInFunction
+	OperandTypeStruct
+	OperandTypeEnum
+	OperandTypeClosure
+
+	// unsigned 64-bit integer produced by the u64 conversion, the value is the int64 with the same bits.
+	// Smaller unsigned integers fit into int64 and use OperandTypeInt64
+	OperandTypeUint64
 )
 
 type OperandValue struct {
@@ -24,12 +31,16 @@ type OperandValue struct {
 
 func (ovt OperandValueType) IsNumeric() bool {
 	switch ovt {
-	case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64, OperandTypeFloat32, OperandTypeFloat64:
+	case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64, OperandTypeUint64, OperandTypeFloat32, OperandTypeFloat64:
 		return true
 	}
 	return false
 }
 
+func (ovt OperandValueType) IsFloat() bool {
+	return ovt == OperandTypeFloat32 || ovt == OperandTypeFloat64
+}
+
 func (ovt OperandValueType) String() string {
 	switch ovt {
 	case OperandTypeInt8:
@@ -40,6 +51,8 @@ func (ovt OperandValueType) String() string {
 		return "int32"
 	case OperandTypeInt64:
 		return "int64"
+	case OperandTypeUint64:
+		return "uint64"
 	case OperandTypeFloat32:
 		return "float32"
 	case OperandTypeFloat64:
@@ -54,11 +67,102 @@ func (ovt OperandValueType) String() string {
 		return "function"
 	case OperandTypeBuildInFunction:
 		return "build-in-function"
+	case OperandTypeStruct:
+		return "struct"
+	case OperandTypeEnum:
+		return "enum"
+	case OperandTypeClosure:
+		return "closure"
 	}
 	return "undefined"
 }
 
+// StructObject is a value of the struct type, fields are stored in the declaration order.
+// Field names are kept for printing and debugging.
+// Methods is the method table of the struct type, it is shared by all values of the type.
+type StructObject struct {
+	Name       string
+	FieldNames []string
+	Fields     []OperandValue
+	Methods    map[string]*FunctionObject
+}
+
+// clone returns a new struct object with the same fields values
+func (s *StructObject) clone() *StructObject {
+	fields := make([]OperandValue, len(s.Fields))
+	copy(fields, s.Fields)
+	return &StructObject{
+		Name:       s.Name,
+		FieldNames: s.FieldNames,
+		Fields:     fields,
+		Methods:    s.Methods,
+	}
+}
+
+// EnumObject is a value of the enum type: the tag of the variant and its payload values.
+// Enum values are immutable, so unit variants share the object of the variant constant.
+type EnumObject struct {
+	Name    string // enum name
+	Variant string // variant name
+	Tag     int
+	Payload []OperandValue
+}
+
+func (e *EnumObject) equals(other *EnumObject) bool {
+	if e.Name != other.Name || e.Tag != other.Tag || len(e.Payload) != len(other.Payload) {
+		return false
+	}
+	for i := range e.Payload {
+		if !operandValuesEqual(&e.Payload[i], &other.Payload[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// operandValuesEqual compares the values, arrays and enums are compared by elements, structs by reference
+func operandValuesEqual(a, b *OperandValue) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+	switch a.Kind {
+	case OperandTypeEnum:
+		return a.Value.(*EnumObject).equals(b.Value.(*EnumObject))
+	case OperandTypeArray:
+		x, y := a.Value.([]OperandValue), b.Value.([]OperandValue)
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !operandValuesEqual(&x[i], &y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Value == b.Value
+}
+
+// ClosureObject is the function value created by the Closure instruction from the function prototype,
+// it keeps the variables of the enclosing functions captured by the function
+type ClosureObject struct {
+	Function *FunctionObject
+	Upvalues []*Upvalue
+}
+
+// Upvalue is the variable captured by closures. While the variable is alive the upvalue is open
+// and refers to the register of the enclosing function, so the closures and the function share it.
+// When the variable goes out of scope the upvalue is closed: the value is moved into the upvalue.
+type Upvalue struct {
+	index RegisterAddress // stack index of the register while the upvalue is open
+	open  bool
+	value OperandValue // value of the closed upvalue
+}
+
 type ConstantValueIdx int
+
+// UpvalueIdx is the index of the upvalue in the closure
+type UpvalueIdx int
 type ConstantValue struct {
 	Name  string
 	Value *OperandValue
@@ -11,13 +11,10 @@ const (
 	OpcodeMove // MOV R(x), R(y)
 
 	// Loads constant to register R(x)
-	OpcodeLoadConst
+	OpcodeLoadConst // R(A) = const#Bx
 
 	// Loads boolean value to register R(x)
-	OpcodeLoadBool
-
-	// Loads string to register R(x)
-	OpcodeLoadString
+	OpcodeLoadBool // R(A) = k
 
 	// Loads immediate 32-bit signed integer to register R(x)
 	OpcodeLoadImm32 // R(x) = y, LoadImm4 x y
@@ -26,11 +23,36 @@ const (
 	OpcodeAllocArray // R(x) = [1, 2, 3, 4, 5]
 
 	// Sets the value of an array element
-	OpcodeArraySet // ArraySet R(array_reg), (R(index) | R(index_reg)), R(value_reg)
+	OpcodeArraySet // ArraySet R(array_reg), (index | R(index_reg)), R(value_reg), index is immediate if k is set
 
 	// Gets the value of an array element
 	OpcodeArrayGet // ArrayGet R(array_reg), R(index), R(value_reg)
 
+	// Gets the length of an array
+	OpcodeArrayLen // ArrayLen R(target_reg), R(array_reg)
+
+	// Allocates a new struct in register R(x) by copying the struct constant (field names and zero values)
+	OpcodeNewStruct // NewStruct R(x), const#Bx
+
+	// Gets the value of a struct field by its index in the declaration
+	OpcodeGetField // GetField R(target_reg), R(struct_reg), field_index
+
+	// Sets the value of a struct field by its index in the declaration
+	OpcodeSetField // SetField R(struct_reg), field_index, R(value_reg)
+
+	// Loads the method of the struct from its method table, the method name is a string constant.
+	// Used for calls on trait-typed values and methods inherited from trait defaults.
+	OpcodeGetMethod // GetMethod R(target_reg), R(struct_reg), const#C
+
+	// Allocates a value of the enum variant constant in R(x), payload values are taken from the registers R(x+1)..R(x+n)
+	OpcodeNewEnum // NewEnum R(x), const#Bx
+
+	// Gets the tag (variant index) of the enum value, used to match the value against the variant patterns
+	OpcodeEnumTag // EnumTag R(target_reg), R(enum_reg)
+
+	// Gets the payload value of the enum variant by its index
+	OpcodeGetPayload // GetPayload R(target_reg), R(enum_reg), payload_index
+
 	// Adds two registers and stores the result in register R(x)
 	OpcodeAdd // R(x) = R(y) + R(z), AddInt4 x y z
 
@@ -92,14 +114,37 @@ const (
 	OpcodeJump // Jump [address]
 
 	// Conditional jump
-	OpcodeJumpIf // JumpIf R(x), #bool, [address]
+	OpcodeJumpIf // JumpIf R(x), k, [address], jumps if R(x) == k
 
-	// OpcodeCall a function. This instruction accepts a 3 operand: address (function reference stored in register), number of arguments, and number of return values
+	// OpcodeCall a function. This instruction accepts a 3 operand: address (function reference stored in register), number of arguments, and number of return values.
+	// If k is set, the last argument is the array spread into the arguments of the built-in function: println(...arr)
 	OpcodeCall
 
 	// OpcodeReturn from a function
 	OpcodeReturn
 
+	// Creates the closure of the function constant, the upvalues are captured as described by the function prototype
+	OpcodeClosure // Closure R(A), const#Bx
+
+	// Loads the value of the upvalue of the current closure
+	OpcodeGetUpval // GetUpval R(A), upval#B
+
+	// Stores the value of the register to the upvalue of the current closure
+	OpcodeSetUpval // SetUpval R(A), upval#B
+
+	// Closes the upvalues of the registers R(A) and above, the variables are going out of scope
+	OpcodeClose // Close R(A)
+
+	// Concatenates the string representations of the registers R(B)...R(B+C-1) into the string
+	OpcodeConcat // Concat R(A), R(B), count
+
+	// Converts the number to the integer of C bits: floats are truncated toward zero,
+	// the value is wrapped to the bit size and sign-extended if k is set, zero-extended otherwise
+	OpcodeTrunc // Trunc R(A), R(B), C, k
+
+	// Converts the number to the float of C bits, f32 values are rounded to the float32 precision
+	OpcodeToFloat // ToFloat R(A), R(B), C
+
 	// No operation
 	OpcodeNop
 )
@@ -109,10 +154,17 @@ var opcodeNames = map[Opcode]string{
 	OpcodeLoadImm32:  "LoadImm32",
 	OpcodeLoadBool:   "LoadBool",
 	OpcodeLoadConst:  "LoadConst",
-	OpcodeLoadString: "LoadString",
 	OpcodeAllocArray: "AllocArray",
 	OpcodeArraySet:   "ArraySet",
 	OpcodeArrayGet:   "ArrayGet",
+	OpcodeArrayLen:   "ArrayLen",
+	OpcodeNewStruct:  "NewStruct",
+	OpcodeGetField:   "GetField",
+	OpcodeSetField:   "SetField",
+	OpcodeGetMethod:  "GetMethod",
+	OpcodeNewEnum:    "NewEnum",
+	OpcodeEnumTag:    "EnumTag",
+	OpcodeGetPayload: "GetPayload",
 	OpcodeAdd:        "Add",
 	OpcodeSub:        "Sub",
 	OpcodeMul:        "Mul",
@@ -136,6 +188,13 @@ var opcodeNames = map[Opcode]string{
 	OpcodeJumpIf:     "JumpIf",
 	OpcodeCall:       "Call",
 	OpcodeReturn:     "Return",
+	OpcodeClosure:    "Closure",
+	OpcodeGetUpval:   "GetUpval",
+	OpcodeSetUpval:   "SetUpval",
+	OpcodeClose:      "Close",
+	OpcodeConcat:     "Concat",
+	OpcodeTrunc:      "Trunc",
+	OpcodeToFloat:    "ToFloat",
 	OpcodeHalt:       "Halt",
 	OpcodeNop:        "Nop",
 }
@@ -4,6 +4,7 @@ package vm
 import (
 	"fmt"
 	"math"
+	"strings"
 )
 
 const (
@@ -17,6 +18,7 @@ type Stack []OperandValue
 type CallFrame struct {
 	parent        *CallFrame // parent call record (for nested calls)
 	function      *FunctionObject
+	closure       *ClosureObject  // closure being called, nil for the functions without upvalues
 	base          RegisterAddress // base pointer for the current stack frame
 	top           RegisterAddress // top pointer for the current stack frame (inclusive)
 	returnAddress RegisterAddress // instruction pointer in the parent call record
@@ -25,17 +27,13 @@ type CallFrame struct {
 	usedSize      uint64          // size of the used stack frame
 }
 
-type VMInstruction struct {
-	opcode   Opcode
-	operands []any
-}
-
 // A virtual machine's code based on Tree-Address Code like in RISC-V, V8, Lua VM assemblies
 type VM struct {
 	ip            uint32     // instruction pointer (program counter)
 	stack         Stack      // stack of 64-bit values (registers, parameters for function, locals, etc.)
 	stackCapacity int        // current stack capacity
 	callRecord    *CallFrame // current call record
+	openUpvalues  []*Upvalue // upvalues referring to the registers of the active call frames
 	options       *VMOptions // VM options (debug, etc.)
 }
 
@@ -97,91 +95,144 @@ func (vm *VM) Run() {
 main_loop:
 	for {
 		base := RegisterAddress(vm.callRecord.base)
-		instruction := vm.fetch()
-		if instruction == nil {
+		instruction, ok := vm.fetch()
+		if !ok {
 			break
 		}
-		opcode, operands := instruction.opcode, instruction.operands
-		switch opcode {
+		switch instruction.Opcode() {
 		case OpcodeHalt:
 			break main_loop
 		case OpcodeLoadImm32:
-			address := operands[0].(RegisterAddress)
-			operand := operands[1].(int64) // TODO: For now, keep it as int64
-			vm.setStackValue(base+address, &OperandValue{
+			vm.setStackValue(base+instruction.A(), &OperandValue{
 				Kind:  OperandTypeInt64,
-				Value: operand,
+				Value: int64(instruction.SBx()),
 			})
 		case OpcodeLoadBool:
-			address := operands[0].(RegisterAddress)
-			operand := operands[1].(bool)
-			vm.setStackValue(base+address, &OperandValue{
+			vm.setStackValue(base+instruction.A(), &OperandValue{
 				Kind:  OperandTypeBool,
-				Value: operand,
+				Value: instruction.K(),
 			})
 		case OpcodeLoadConst:
-			target := operands[0].(RegisterAddress)
-			value := operands[1].(ConstantValueIdx)
-			constant := vm.callRecord.function.constants[value]
-			vm.setStackValue(base+target, constant.Value)
+			constant := vm.callRecord.function.constants[instruction.Bx()]
+			vm.setStackValue(base+instruction.A(), constant.Value)
 		case OpcodeAllocArray:
-			target := operands[0].(RegisterAddress)
-			size := operands[1].(int)
-			array := make([]OperandValue, size)
-			vm.setStackValue(base+target, &OperandValue{
+			array := make([]OperandValue, instruction.Bx())
+			vm.setStackValue(base+instruction.A(), &OperandValue{
 				Kind:  OperandTypeArray,
 				Value: array,
 			})
 		case OpcodeArraySet:
-			arrayReg := operands[0].(RegisterAddress)
-			var position int
-			switch operands[1].(type) {
-			case RegisterAddress:
-				position = int(vm.stack[base+operands[1].(RegisterAddress)].Value.(int64))
-			case int:
-				position = operands[1].(int)
+			// k flag is set when B is an immediate index
+			position := instruction.B()
+			if !instruction.K() {
+				position = int(vm.stack[base+RegisterAddress(position)].Value.(int64))
 			}
-			valueReg := operands[2].(RegisterAddress)
-			arraySlot := vm.stack[base+arrayReg]
+			arraySlot := vm.stack[base+instruction.A()]
 			if arraySlot.Kind != OperandTypeArray {
 				panic(fmt.Sprintf("vm: invalid operand type '%s' for array set", arraySlot.Kind))
 			}
 			array := arraySlot.Value.([]OperandValue)
-			array[position] = vm.stack[base+valueReg]
+			array[position] = vm.stack[base+RegisterAddress(instruction.C())]
 		case OpcodeArrayGet:
-			targetReg := operands[0].(RegisterAddress)
-			arrayReg := operands[1].(RegisterAddress)
-			positionReg := operands[2].(RegisterAddress)
-			arraySlot := vm.stack[base+arrayReg]
+			arraySlot := vm.stack[base+RegisterAddress(instruction.B())]
 			if arraySlot.Kind != OperandTypeArray {
 				panic(fmt.Sprintf("vm: invalid operand type '%s' for array get", arraySlot.Kind))
 			}
 			array := arraySlot.Value.([]OperandValue)
-			pos := vm.stack[base+positionReg].Value.(int64)
-			vm.setStackValue(base+targetReg, &array[pos])
-		case OpcodeLoadString:
-			target := operands[0].(RegisterAddress)
-			value := operands[1].(string)
-			vm.setStackValue(base+target, &OperandValue{
-				Kind:  OperandTypeString,
-				Value: value,
+			pos := vm.stack[base+RegisterAddress(instruction.C())].Value.(int64)
+			vm.setStackValue(base+instruction.A(), &array[pos])
+		case OpcodeArrayLen:
+			arraySlot := vm.stack[base+RegisterAddress(instruction.B())]
+			if arraySlot.Kind != OperandTypeArray {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for array length", arraySlot.Kind))
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeInt64,
+				Value: int64(len(arraySlot.Value.([]OperandValue))),
+			})
+		case OpcodeNewStruct:
+			prototype := vm.callRecord.function.constants[instruction.Bx()].Value.Value.(*StructObject)
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeStruct,
+				Value: prototype.clone(),
+			})
+		case OpcodeGetField:
+			structSlot := vm.stack[base+RegisterAddress(instruction.B())]
+			if structSlot.Kind != OperandTypeStruct {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for field get", structSlot.Kind))
+			}
+			object := structSlot.Value.(*StructObject)
+			vm.setStackValue(base+instruction.A(), &object.Fields[instruction.C()])
+		case OpcodeSetField:
+			structSlot := vm.stack[base+instruction.A()]
+			if structSlot.Kind != OperandTypeStruct {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for field set", structSlot.Kind))
+			}
+			object := structSlot.Value.(*StructObject)
+			object.Fields[instruction.B()] = vm.stack[base+RegisterAddress(instruction.C())]
+		case OpcodeGetMethod:
+			structSlot := vm.stack[base+RegisterAddress(instruction.B())]
+			if structSlot.Kind != OperandTypeStruct {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for method get", structSlot.Kind))
+			}
+			object := structSlot.Value.(*StructObject)
+			name := vm.callRecord.function.constants[instruction.C()].Value.Value.(string)
+			method, ok := object.Methods[name]
+			if !ok {
+				panic(fmt.Sprintf("vm: method '%s' not found in struct '%s'", name, object.Name))
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeFunctionObject,
+				Value: method,
+			})
+		case OpcodeNewEnum:
+			prototype := vm.callRecord.function.constants[instruction.Bx()].Value.Value.(*EnumObject)
+			object := prototype
+			if len(prototype.Payload) > 0 {
+				payloadStart := base + instruction.A() + 1
+				object = &EnumObject{
+					Name:    prototype.Name,
+					Variant: prototype.Variant,
+					Tag:     prototype.Tag,
+					Payload: make([]OperandValue, len(prototype.Payload)),
+				}
+				copy(object.Payload, vm.stack[payloadStart:payloadStart+RegisterAddress(len(object.Payload))])
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeEnum,
+				Value: object,
 			})
+		case OpcodeEnumTag:
+			enumSlot := vm.stack[base+RegisterAddress(instruction.B())]
+			if enumSlot.Kind != OperandTypeEnum {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for enum tag", enumSlot.Kind))
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeInt64,
+				Value: int64(enumSlot.Value.(*EnumObject).Tag),
+			})
+		case OpcodeGetPayload:
+			enumSlot := vm.stack[base+RegisterAddress(instruction.B())]
+			if enumSlot.Kind != OperandTypeEnum {
+				panic(fmt.Sprintf("vm: invalid operand type '%s' for payload get", enumSlot.Kind))
+			}
+			object := enumSlot.Value.(*EnumObject)
+			vm.setStackValue(base+instruction.A(), &object.Payload[instruction.C()])
 		case OpcodeNot:
-			target := operands[0].(RegisterAddress)
-			operand := operands[1].(RegisterAddress)
-			vm.setStackValue(base+target, &OperandValue{
+			operand := RegisterAddress(instruction.B())
+			vm.setStackValue(base+instruction.A(), &OperandValue{
 				Kind:  OperandTypeBool,
 				Value: !vm.stack[base+operand].Value.(bool),
 			})
 		case OpcodeNeg:
-			target := operands[0].(RegisterAddress)
-			operand := operands[1].(RegisterAddress)
+			target := instruction.A()
+			operand := RegisterAddress(instruction.B())
 			stackValue := vm.stack[base+operand]
 			if !stackValue.Kind.IsNumeric() {
 				panic(fmt.Sprintf("vm: invalid operand type '%s' for negation", vm.stack[base+operand].Kind))
 			}
 			switch stackValue.Kind {
-			case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64:
+			case OperandTypeInt8, OperandTypeInt16, OperandTypeInt32, OperandTypeInt64, OperandTypeUint64:
 				vm.setStackValue(base+target, &OperandValue{
 					Kind:  OperandTypeInt64,
 					Value: -stackValue.Value.(int64),
@@ -196,47 +247,87 @@ main_loop:
 			OpcodeEq, OpcodeGt, OpcodeGte, OpcodeLt, OpcodeLte, OpcodeNeq,
 			OpcodeXor, OpcodeAnd, OpcodeOr, OpcodeShl, OpcodeShr,
 			OpcodeMod, OpcodePow:
-			target := operands[0].(RegisterAddress)
-			operand1 := operands[1].(RegisterAddress)
-			operand2 := operands[2].(RegisterAddress)
-			vm.performBinaryOperation(opcode, base+target, base+operand1, base+operand2)
+			target := instruction.A()
+			operand1 := RegisterAddress(instruction.B())
+			operand2 := RegisterAddress(instruction.C())
+			vm.performBinaryOperation(instruction.Opcode(), base+target, base+operand1, base+operand2)
 		case OpcodeMove:
-			dest := operands[0].(RegisterAddress)
-			source := operands[1].(RegisterAddress)
-			sourceSlot := vm.stack[base+source]
-			vm.setStackValue(base+dest, &OperandValue{
+			sourceSlot := vm.stack[base+RegisterAddress(instruction.B())]
+			vm.setStackValue(base+instruction.A(), &OperandValue{
 				Kind:  sourceSlot.Kind,
 				Value: sourceSlot.Value,
 			})
 		case OpcodeJump:
-			address := operands[0].(int)
-			vm.ip = uint32(address)
+			vm.ip = uint32(instruction.Bx())
 		case OpcodeJumpIf:
-			value := operands[0].(RegisterAddress)
-			condition := operands[1].(bool)
-			address := operands[2].(int)
-			if vm.stack[base+value].Value == condition {
-				vm.ip = uint32(address)
+			if vm.stack[base+instruction.A()].Value == instruction.K() {
+				vm.ip = uint32(instruction.Bx())
 			}
 		case OpcodeCall:
-			function := operands[0].(RegisterAddress)
-			args := operands[1].(int)
-			results := operands[2].(int)
-			vm.callFunc(function, args, results)
+			vm.callFunc(instruction.A(), instruction.B(), instruction.C(), instruction.K())
 		case OpcodeReturn:
-			from := operands[0].(RegisterAddress)
-			count := operands[1].(int)
+			from := instruction.A()
+			// the caller may expect fewer values, e.g. the results of the call statement 'f()' are dropped
+			count := min(instruction.B(), int(vm.callRecord.results))
 
-			// return values are stored after the arguments
+			// return values are stored in consecutive registers starting from the callee register
 			returnStartIdx := vm.callRecord.base - 1
+			vm.closeUpvalues(base)
 			for i := 0; i < count; i++ {
 				vm.setStackValue(returnStartIdx+RegisterAddress(i), &vm.stack[base+from+RegisterAddress(i)])
 			}
 
 			vm.ip = uint32(vm.callRecord.returnAddress) // go back to parent
 			vm.callRecord = vm.callRecord.parent
+		case OpcodeClosure:
+			prototype := vm.callRecord.function.constants[instruction.Bx()].Value.Value.(*FunctionObject)
+			closure := &ClosureObject{Function: prototype, Upvalues: make([]*Upvalue, len(prototype.upvalues))}
+			for idx, upvalue := range prototype.upvalues {
+				if upvalue.local {
+					closure.Upvalues[idx] = vm.captureUpvalue(base + RegisterAddress(upvalue.index))
+				} else {
+					closure.Upvalues[idx] = vm.callRecord.closure.Upvalues[upvalue.index]
+				}
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeClosure,
+				Value: closure,
+			})
+		case OpcodeGetUpval:
+			vm.setStackValue(base+instruction.A(), vm.upvalueSlot(vm.callRecord.closure.Upvalues[instruction.B()]))
+		case OpcodeSetUpval:
+			*vm.upvalueSlot(vm.callRecord.closure.Upvalues[instruction.B()]) = vm.stack[base+instruction.A()]
+		case OpcodeClose:
+			vm.closeUpvalues(base + instruction.A())
+		case OpcodeConcat:
+			var builder strings.Builder
+			from := base + RegisterAddress(instruction.B())
+			for idx := from; idx < from+RegisterAddress(instruction.C()); idx++ {
+				builder.WriteString(stringifyOperandValue(&vm.stack[idx]))
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeString,
+				Value: builder.String(),
+			})
+		case OpcodeTrunc:
+			value := vm.stack[base+RegisterAddress(instruction.B())]
+			bits, signed := instruction.C(), instruction.K()
+			kind := OperandTypeInt64
+			if !signed && bits >= 64 {
+				kind = OperandTypeUint64
+			}
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  kind,
+				Value: truncateInteger(value, bits, signed),
+			})
+		case OpcodeToFloat:
+			value := vm.stack[base+RegisterAddress(instruction.B())]
+			vm.setStackValue(base+instruction.A(), &OperandValue{
+				Kind:  OperandTypeFloat64,
+				Value: convertToFloat(value, instruction.C()),
+			})
 		default:
-			panic(fmt.Sprintf("error: unknown opcode: %v\n", opcode))
+			panic(fmt.Sprintf("error: unknown opcode: %v\n", instruction.Opcode()))
 		}
 	}
 
@@ -245,12 +336,35 @@ main_loop:
 	}
 }
 
+// Register returns the value of the register in the current call frame
+func (vm *VM) Register(address RegisterAddress) *OperandValue {
+	return &vm.stack[vm.callRecord.base+address]
+}
+
+// Unwind discards all active call frames after a runtime failure.
+// The execution continues from the end of the module, values of the module registers are kept.
+func (vm *VM) Unwind() {
+	for vm.callRecord.parent != nil {
+		vm.closeUpvalues(vm.callRecord.base)
+		vm.callRecord = vm.callRecord.parent
+	}
+	vm.ip = uint32(len(vm.callRecord.function.instructions))
+}
+
 func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddress) {
 	operandX := vm.stack[x]
 	operandY := vm.stack[y]
 
+	// the integer mixed with the float is converted to the float, the checker infers 'i32 * f64' as 'f64'
+	if operandX.Kind.IsNumeric() && operandY.Kind.IsNumeric() && operandX.Kind.IsFloat() != operandY.Kind.IsFloat() {
+		operandX = OperandValue{Kind: OperandTypeFloat64, Value: convertToFloat(operandX, 64)}
+		operandY = OperandValue{Kind: OperandTypeFloat64, Value: convertToFloat(operandY, 64)}
+	}
+	// the integers are compared and divided as unsigned if any of them is u64
+	unsigned := operandX.Kind == OperandTypeUint64 || operandY.Kind == OperandTypeUint64
+
 	if opcode.IsComparison() {
-		if operandX.Kind != operandY.Kind {
+		if operandX.Kind != operandY.Kind && !(operandX.Kind.IsNumeric() && operandY.Kind.IsNumeric()) {
 			panic(fmt.Sprintf("vm: invalid operand type '%s' and '%s'", operandX.Kind, operandY.Kind))
 		}
 
@@ -259,37 +373,30 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 		switch x := operandX.Value.(type) {
 		case int64:
 			y := operandY.Value.(int64)
-
+			if unsigned {
+				result = compareNumbers(opcode, uint64(x), uint64(y))
+			} else {
+				result = compareNumbers(opcode, x, y)
+			}
+		case float64:
+			result = compareNumbers(opcode, x, operandY.Value.(float64))
+		case *EnumObject:
 			switch opcode {
 			case OpcodeEq:
-				result = x == y
-			case OpcodeGt:
-				result = x > y
-			case OpcodeGte:
-				result = x >= y
-			case OpcodeLt:
-				result = x < y
-			case OpcodeLte:
-				result = x <= y
+				result = x.equals(operandY.Value.(*EnumObject))
 			case OpcodeNeq:
-				result = x != y
+				result = !x.equals(operandY.Value.(*EnumObject))
+			default:
+				panic(fmt.Sprintf("vm: unsupported enum comparison '%s'", opcode))
 			}
-		case float64:
-			y := operandY.Value.(float64)
-
+		case bool, string:
 			switch opcode {
 			case OpcodeEq:
-				result = x == y
-			case OpcodeGt:
-				result = x > y
-			case OpcodeGte:
-				result = x >= y
-			case OpcodeLt:
-				result = x < y
-			case OpcodeLte:
-				result = x <= y
+				result = operandX.Value == operandY.Value
 			case OpcodeNeq:
-				result = x != y
+				result = operandX.Value != operandY.Value
+			default:
+				panic(fmt.Sprintf("vm: unsupported %T comparison '%s'", x, opcode))
 			}
 
 		default:
@@ -308,12 +415,7 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 
 		switch x := operandX.Value.(type) {
 		case int64:
-			var y int64
-			if _, ok := operandY.Value.(int64); !ok {
-				y = int64(operandY.Value.(float64))
-			} else {
-				y = operandY.Value.(int64)
-			}
+			y := operandY.Value.(int64)
 
 			switch opcode {
 			case OpcodeAdd:
@@ -326,15 +428,27 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 				if y == 0 {
 					panic("vm: division by zero")
 				}
-				result = x / y
+				if unsigned {
+					result = int64(uint64(x) / uint64(y))
+				} else {
+					result = x / y
+				}
 			case OpcodePow:
 				result = int64(math.Pow(float64(x), float64(y)))
 			case OpcodeMod:
-				result = x % y
+				if unsigned {
+					result = int64(uint64(x) % uint64(y))
+				} else {
+					result = x % y
+				}
 			case OpcodeShl:
 				result = x << y
 			case OpcodeShr:
-				result = x >> y
+				if unsigned {
+					result = int64(uint64(x) >> y)
+				} else {
+					result = x >> y
+				}
 			case OpcodeAnd:
 				result = x & y
 			case OpcodeOr:
@@ -346,12 +460,7 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 			}
 
 		case float64:
-			var y float64
-			if _, ok := operandY.Value.(float64); !ok {
-				y = float64(operandY.Value.(int64))
-			} else {
-				y = operandY.Value.(float64)
-			}
+			y := operandY.Value.(float64)
 
 			switch opcode {
 			case OpcodeAdd:
@@ -380,6 +489,9 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 		switch result.(type) {
 		case int64:
 			kind = OperandTypeInt64
+			if unsigned {
+				kind = OperandTypeUint64
+			}
 		case float64:
 			kind = OperandTypeFloat64
 		}
@@ -411,6 +523,75 @@ func (vm *VM) performBinaryOperation(opcode Opcode, register, x, y RegisterAddre
 	}
 }
 
+// truncateInteger converts the number to the integer of the bit size, the bits above the size are dropped
+// and the value is sign-extended or zero-extended back to int64 like in the two's complement arithmetic
+func truncateInteger(value OperandValue, bits int, signed bool) int64 {
+	var x int64
+	switch v := value.Value.(type) {
+	case int64:
+		x = v
+	case float64:
+		// the float is truncated toward zero and wrapped to 64 bits first, Go leaves out of range conversions undefined
+		t := math.Mod(math.Trunc(v), 1<<64)
+		if t >= 1<<63 {
+			t -= 1 << 64
+		} else if t < -(1 << 63) {
+			t += 1 << 64
+		}
+		x = int64(t)
+	default:
+		panic(fmt.Sprintf("vm: cannot convert '%s' to integer", value.Kind))
+	}
+	if bits >= 64 {
+		return x
+	}
+	shift := 64 - bits
+	if signed {
+		return x << shift >> shift
+	}
+	return int64(uint64(x) << shift >> shift)
+}
+
+// compareNumbers performs the comparison opcode on the numbers of the same type
+func compareNumbers[T int64 | uint64 | float64](opcode Opcode, x, y T) bool {
+	switch opcode {
+	case OpcodeEq:
+		return x == y
+	case OpcodeGt:
+		return x > y
+	case OpcodeGte:
+		return x >= y
+	case OpcodeLt:
+		return x < y
+	case OpcodeLte:
+		return x <= y
+	case OpcodeNeq:
+		return x != y
+	}
+	return false
+}
+
+// convertToFloat converts the number to the float of the bit size, f32 values are kept as float64 with the float32 precision
+func convertToFloat(value OperandValue, bits int) float64 {
+	var x float64
+	switch v := value.Value.(type) {
+	case int64:
+		if value.Kind == OperandTypeUint64 {
+			x = float64(uint64(v))
+		} else {
+			x = float64(v)
+		}
+	case float64:
+		x = v
+	default:
+		panic(fmt.Sprintf("vm: cannot convert '%s' to float", value.Kind))
+	}
+	if bits == 32 {
+		return float64(float32(x))
+	}
+	return x
+}
+
 func (vm *VM) setStackNullValue(index uint64) {
 	vm.stack[index] = OperandValue{
 		Kind:  OperandTypeUndefined,
@@ -426,6 +607,41 @@ func (vm *VM) setStackValue(index RegisterAddress, slot *OperandValue) {
 	vm.stack[index] = *slot
 }
 
+// captureUpvalue returns the open upvalue of the register, the closures capturing the same variable share the upvalue
+func (vm *VM) captureUpvalue(index RegisterAddress) *Upvalue {
+	for _, upvalue := range vm.openUpvalues {
+		if upvalue.index == index {
+			return upvalue
+		}
+	}
+	upvalue := &Upvalue{index: index, open: true}
+	vm.openUpvalues = append(vm.openUpvalues, upvalue)
+	return upvalue
+}
+
+// closeUpvalues moves the values of the registers starting from the index into their upvalues
+func (vm *VM) closeUpvalues(from RegisterAddress) {
+	open := vm.openUpvalues[:0]
+	for _, upvalue := range vm.openUpvalues {
+		if upvalue.index < from {
+			open = append(open, upvalue)
+			continue
+		}
+		upvalue.value = vm.stack[upvalue.index]
+		upvalue.open = false
+	}
+	vm.openUpvalues = open
+}
+
+// upvalueSlot returns the register of the open upvalue or the value of the closed one.
+// The register is addressed by the index because the stack may be reallocated.
+func (vm *VM) upvalueSlot(upvalue *Upvalue) *OperandValue {
+	if upvalue.open {
+		return &vm.stack[upvalue.index]
+	}
+	return &upvalue.value
+}
+
 // Perform a function call. First argument is the address register R(Addr) of the function.
 // This address register should contain the address of the function to be called.
 // The method will create a new call record where 'base' starts from reference register R(Addr)
@@ -433,7 +649,8 @@ func (vm *VM) setStackValue(index RegisterAddress, slot *OperandValue) {
 // LoadImm 1, 10	; Load value 10 to the register 1
 // LoadImm 2, 20	; Load value 20 to the register 2
 // Call 0, 2, 1 	; Call sum function with 2 arguments and 1 return value
-func (vm *VM) callFunc(function RegisterAddress, args int, results int) {
+// If spread is set, the elements of the last argument are passed to the built-in function instead of the array.
+func (vm *VM) callFunc(function RegisterAddress, args int, results int, spread bool) {
 	vm.debug("[func call]: Calling a function at address %d (args=%d, rets=%d, used=%d)\n", function, args, results, vm.callRecord.usedSize)
 	parentFrame := vm.callRecord
 	functionBasePointer := parentFrame.base + function + 1 // base starts at the first argument if exists (addressReg + 1)
@@ -447,10 +664,29 @@ func (vm *VM) callFunc(function RegisterAddress, args int, results int) {
 		for i := 0; i < args; i++ {
 			operands = append(operands, &vm.stack[functionBasePointer+RegisterAddress(i)])
 		}
-		builtinFunction(operands)
-		vm.setStackNullValue(uint64(functionBasePointer) - 1)
+		if spread {
+			array := operands[args-1].Value.([]OperandValue)
+			operands = operands[:args-1]
+			for i := range array {
+				operands = append(operands, &array[i])
+			}
+		}
+		if result := builtinFunction(operands); result != nil {
+			vm.setStackValue(functionBasePointer-1, result)
+		} else {
+			vm.setStackNullValue(uint64(functionBasePointer) - 1)
+		}
 		return
-	} else if functionObj.Kind != OperandTypeFunctionObject {
+	}
+	var callee *FunctionObject
+	var closure *ClosureObject
+	switch functionObj.Kind {
+	case OperandTypeFunctionObject:
+		callee = functionObj.Value.(*FunctionObject)
+	case OperandTypeClosure:
+		closure = functionObj.Value.(*ClosureObject)
+		callee = closure.Function
+	default:
 		panic(fmt.Sprintf("Invalid function object to perform call: %T (ip=%d, caller=%s)", functionObj, vm.ip-1, vm.callRecord.function.name))
 	}
 	vm.callRecord = &CallFrame{
@@ -459,7 +695,8 @@ func (vm *VM) callFunc(function RegisterAddress, args int, results int) {
 		parent:        parentFrame,
 		base:          functionBasePointer,
 		top:           functionBasePointer + minStackFrameSize,
-		function:      functionObj.Value.(*FunctionObject),
+		function:      callee,
+		closure:       closure,
 		returnAddress: RegisterAddress(vm.ip),
 	}
 	vm.ip = 0
@@ -477,11 +714,12 @@ func (vm *VM) callFunc(function RegisterAddress, args int, results int) {
 	}
 }
 
-func (vm *VM) fetch() *VMInstruction {
-	if vm.ip >= uint32(len(vm.callRecord.function.instructions)) {
-		return nil
+func (vm *VM) fetch() (VMInstruction, bool) {
+	instructions := vm.callRecord.function.instructions
+	if vm.ip >= uint32(len(instructions)) {
+		return 0, false
 	}
-	instruction := vm.callRecord.function.instructions[vm.ip]
+	instruction := instructions[vm.ip]
 	vm.ip++
-	return &instruction
+	return instruction, true
 }